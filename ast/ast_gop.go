@@ -63,10 +63,11 @@ func (*OverloadFuncDecl) declNode() {}
 // https://github.com/goplus/gop/issues/2143
 //
 //	tpl`...`
+//	json"..."
 type DomainTextLit struct {
 	Domain   *Ident    // domain name
 	ValuePos token.Pos // literal position
-	Value    string    // literal string; e.g. `\m\n\o`
+	Value    string    // literal string; e.g. `\m\n\o` or "\\d+"
 	Extra    any       // *ast.StringLitEx or *gop/tpl/ast.File, optional
 }
 
@@ -92,6 +93,22 @@ type StringLitEx struct {
 	Parts []any // can be (val string) or (xval Expr)
 }
 
+// A FormatExpr node represents a formatted part `${X:Format}` of a string
+// literal, eg. "${price:%.2f}" or "${n:08x}".
+type FormatExpr struct {
+	X      Expr      // expression
+	Colon  token.Pos // position of ":"
+	Format string    // format spec; e.g. %.2f or 08x
+}
+
+// Pos returns position of first character belonging to the node.
+func (x *FormatExpr) Pos() token.Pos { return x.X.Pos() }
+
+// End returns position of first character immediately after the node.
+func (x *FormatExpr) End() token.Pos { return x.Colon + 1 + token.Pos(len(x.Format)) }
+
+func (*FormatExpr) exprNode() {}
+
 // NextPartPos - position of first character of next part.
 // pos - position of this part (not including quote character).
 func NextPartPos(pos token.Pos, part any) (nextPos token.Pos) {
@@ -134,12 +151,14 @@ func (x *NumberUnitLit) End() token.Pos {
 
 // -----------------------------------------------------------------------------
 
-// A EnvExpr node represents a ${name} expression.
+// A EnvExpr node represents a ${name} or ${name:-default} expression.
 type EnvExpr struct {
-	TokPos token.Pos // position of "$"
-	Lbrace token.Pos // position of "{"
-	Name   *Ident    // name
-	Rbrace token.Pos // position of "}"
+	TokPos  token.Pos // position of "$"
+	Lbrace  token.Pos // position of "{"
+	Name    *Ident    // name
+	Colon   token.Pos // position of ":-"; or token.NoPos if Default == nil
+	Default Expr      // default value; or nil
+	Rbrace  token.Pos // position of "}"
 }
 
 // Pos - position of first character belonging to the node.
@@ -150,7 +169,7 @@ func (p *EnvExpr) Pos() token.Pos {
 // End - position of first character immediately after the node.
 func (p *EnvExpr) End() token.Pos {
 	if p.Rbrace != token.NoPos {
-		return p.Rbrace
+		return p.Rbrace + 1
 	}
 	return p.Name.End()
 }
@@ -88,6 +88,9 @@ func Walk(v Visitor, node Node) {
 			}
 		}
 
+	case *FormatExpr:
+		Walk(v, n.X)
+
 	case *DomainTextLit:
 		Walk(v, n.Domain)
 		if e := n.Extra; e != nil {
@@ -451,6 +454,9 @@ func Walk(v Visitor, node Node) {
 
 	case *EnvExpr:
 		Walk(v, n.Name)
+		if n.Default != nil {
+			Walk(v, n.Default)
+		}
 
 	default:
 		panic(fmt.Sprintf("ast.Walk: unexpected node type %T", n))
@@ -0,0 +1,92 @@
+/*
+ * Copyright (c) 2025 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package envx implements the typed environment variable expressions
+// ${NAME} and ${NAME:-default} of Go+ files, eg. ${PORT:-8080}.
+//
+// Each function gets the value of the variable name by getenv (os.Getenv or
+// the Gop_Env function of a package), and returns defval if the value is
+// empty. It panics if the value can't be converted to the result type.
+package envx
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// String returns the value of the environment variable name, or defval if
+// it is empty.
+func String(getenv func(name string) string, name string, defval string) string {
+	if val := getenv(name); val != "" {
+		return val
+	}
+	return defval
+}
+
+// Int returns the value of the environment variable name as an int.
+func Int(getenv func(name string) string, name string, defval int) int {
+	if val := getenv(name); val != "" {
+		v, err := strconv.Atoi(val)
+		if err != nil {
+			panic(invalidValue(name, err))
+		}
+		return v
+	}
+	return defval
+}
+
+// Float64 returns the value of the environment variable name as a float64.
+func Float64(getenv func(name string) string, name string, defval float64) float64 {
+	if val := getenv(name); val != "" {
+		v, err := strconv.ParseFloat(val, 64)
+		if err != nil {
+			panic(invalidValue(name, err))
+		}
+		return v
+	}
+	return defval
+}
+
+// Bool returns the value of the environment variable name as a bool. See
+// strconv.ParseBool for the accepted values.
+func Bool(getenv func(name string) string, name string, defval bool) bool {
+	if val := getenv(name); val != "" {
+		v, err := strconv.ParseBool(val)
+		if err != nil {
+			panic(invalidValue(name, err))
+		}
+		return v
+	}
+	return defval
+}
+
+// Duration returns the value of the environment variable name as a
+// time.Duration, eg. 300ms or 1h30m. See time.ParseDuration.
+func Duration(getenv func(name string) string, name string, defval time.Duration) time.Duration {
+	if val := getenv(name); val != "" {
+		v, err := time.ParseDuration(val)
+		if err != nil {
+			panic(invalidValue(name, err))
+		}
+		return v
+	}
+	return defval
+}
+
+func invalidValue(name string, err error) error {
+	return fmt.Errorf("invalid value of ${%s}: %w", name, err)
+}
@@ -0,0 +1,65 @@
+/*
+ * Copyright (c) 2025 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package envx
+
+import (
+	"testing"
+	"time"
+)
+
+func getenv(name string) string {
+	return map[string]string{
+		"HOST": "example.com", "PORT": "80", "RATE": "0.5", "DEBUG": "true", "TIMEOUT": "1m30s", "BAD": "x",
+	}[name]
+}
+
+func TestEnv(t *testing.T) {
+	if v := String(getenv, "HOST", "localhost"); v != "example.com" {
+		t.Fatal("String:", v)
+	}
+	if v := String(getenv, "NONE", "localhost"); v != "localhost" {
+		t.Fatal("String default:", v)
+	}
+	if v := Int(getenv, "PORT", 8080); v != 80 {
+		t.Fatal("Int:", v)
+	}
+	if v := Int(getenv, "NONE", 8080); v != 8080 {
+		t.Fatal("Int default:", v)
+	}
+	if v := Float64(getenv, "RATE", 1); v != 0.5 {
+		t.Fatal("Float64:", v)
+	}
+	if v := Bool(getenv, "DEBUG", false); !v {
+		t.Fatal("Bool:", v)
+	}
+	if v := Duration(getenv, "TIMEOUT", time.Second); v != 90*time.Second {
+		t.Fatal("Duration:", v)
+	}
+	if v := Duration(getenv, "NONE", time.Second); v != time.Second {
+		t.Fatal("Duration default:", v)
+	}
+}
+
+func TestInvalidValue(t *testing.T) {
+	defer func() {
+		e := recover()
+		if err, ok := e.(error); !ok || err.Error() != `invalid value of ${BAD}: strconv.Atoi: parsing "x": invalid syntax` {
+			t.Fatal("TestInvalidValue:", e)
+		}
+	}()
+	Int(getenv, "BAD", 0)
+}
@@ -0,0 +1,59 @@
+/*
+ * Copyright (c) 2025 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package strfmt implements the padding of formatted parts of string
+// literals, eg. "${n:08x}" or "${name:-10s}".
+package strfmt
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Pad right-justifies s by padding it with spaces on the left to width runes.
+func Pad(s string, width int) string {
+	if n := width - utf8.RuneCountInString(s); n > 0 {
+		return strings.Repeat(" ", n) + s
+	}
+	return s
+}
+
+// PadRight left-justifies s by padding it with spaces on the right to width
+// runes.
+func PadRight(s string, width int) string {
+	if n := width - utf8.RuneCountInString(s); n > 0 {
+		return s + strings.Repeat(" ", n)
+	}
+	return s
+}
+
+// ZeroPad pads s with leading zeros to width runes. The zeros follow the sign
+// of a number. A number which isn't finite (eg. NaN or -Inf) is padded with
+// spaces.
+func ZeroPad(s string, width int) string {
+	n := width - utf8.RuneCountInString(s)
+	if n <= 0 {
+		return s
+	}
+	sign := ""
+	if s != "" && (s[0] == '-' || s[0] == '+') {
+		sign, s = s[:1], s[1:]
+	}
+	if s == "NaN" || s == "Inf" {
+		return strings.Repeat(" ", n) + sign + s
+	}
+	return sign + strings.Repeat("0", n) + s
+}
@@ -0,0 +1,47 @@
+/*
+ * Copyright (c) 2025 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package strfmt
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestPad(t *testing.T) {
+	cases := []struct {
+		format string
+		got    string
+		val    any
+	}{
+		{"%5s", Pad("ab", 5), "ab"},
+		{"%-5s|", PadRight("ab", 5) + "|", "ab"},
+		{"%1s", Pad("abc", 1), "abc"},
+		{"%4s", Pad("中文", 4), "中文"},
+		{"%05d", ZeroPad("42", 5), 42},
+		{"%05d", ZeroPad("-42", 5), -42},
+		{"%08.2f", ZeroPad("-3.14", 8), -3.14},
+		{"%06f", ZeroPad("NaN", 6), math.NaN()},
+		{"%07f", ZeroPad("-Inf", 7), math.Inf(-1)},
+		{"%02d", ZeroPad("123", 2), 123},
+	}
+	for _, c := range cases {
+		if want := fmt.Sprintf(c.format, c.val); c.got != want {
+			t.Errorf("%s: got %q, want %q", c.format, c.got, want)
+		}
+	}
+}
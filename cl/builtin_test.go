@@ -132,13 +132,16 @@ func TestCompileLambda2(t *testing.T) {
 }
 
 func TestCompileExpr(t *testing.T) {
+	old := enableRecover
 	defer func() {
+		enableRecover = old
 		if e := recover(); e != nil {
 			if ce := e.(*gogen.CodeError); ce.Msg != "compileExpr failed: unknown - *ast.Ellipsis" {
 				t.Fatal("compileExpr:", ce.Msg)
 			}
 		}
 	}()
+	enableRecover = false
 	ctx := &blockCtx{pkgCtx: &pkgCtx{}}
 	compileExpr(ctx, &ast.Ellipsis{})
 }
@@ -402,17 +405,6 @@ func TestErrPanicToRecv(t *testing.T) {
 	}()
 }
 
-func TestCompileErrWrapExpr(t *testing.T) {
-	defer func() {
-		if e := recover(); e != "TODO: can't use expr? in global" {
-			t.Fatal("TestCompileErrWrapExpr failed")
-		}
-	}()
-	pkg := gogen.NewPackage("", "foo", goxConf)
-	ctx := &blockCtx{pkg: pkg, cb: pkg.CB()}
-	compileErrWrapExpr(ctx, &ast.ErrWrapExpr{Tok: token.QUESTION}, 0)
-}
-
 func TestToString(t *testing.T) {
 	defer func() {
 		if e := recover(); e == nil {
@@ -453,6 +445,11 @@ func TestCheckCommandWithoutArgs(t *testing.T) {
 }
 
 func TestClRangeStmt(t *testing.T) {
+	old := enableRecover
+	defer func() {
+		enableRecover = old
+	}()
+	enableRecover = false
 	ctx := &blockCtx{
 		cb: &gogen.CodeBuilder{},
 	}
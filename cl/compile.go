@@ -203,6 +203,80 @@ type Config struct {
 
 	// Outline = true means to skip compiling function bodies.
 	Outline bool
+
+	// Domains maps the name of a text domain to the path of its package
+	// (optional). A domain text literal name`text` is compiled to a call to
+	// the New or NewEx function of the domain package:
+	//
+	//	func New(text string, ...) T
+	//	func NewEx(text string, file string, line, col int, ...) T
+	//
+	// NewEx is preferred when provided, to get the position of the text. An
+	// imported package takes precedence over Domains, which take precedence
+	// over the builtin domains (tpl, json, xml, csv, regexp and regexposix).
+	// See ModDomains.
+	Domains map[string]string
+
+	// DomainCheckers maps the package path of a text domain to the function
+	// which checks its texts at compile time (optional). They take precedence
+	// over the builtin checkers of the tpl/encoding packages, and a nil
+	// function disables the builtin checker of its package. If a checker
+	// returns an *encoding.SyntaxError (of package
+	// github.com/goplus/gop/tpl/encoding), the error is reported at its
+	// offset in the text.
+	DomainCheckers map[string]func(text string) error
+
+	// MaxErrors is the maximum number of errors to report (optional, 0 means
+	// no limit). Compiling stops when it is reached. An error is reported once
+	// even if it occurs several times, and errors derived from an expression
+	// which failed to compile (eg. an undefined identifier) are not reported.
+	MaxErrors int
+
+	// OnWarning is called to report a warning (optional). code is one of
+	// the Warn* constants, eg. WarnUnusedVar.
+	OnWarning func(pos token.Pos, code, msg string)
+}
+
+// ModDomains returns the text domains declared by the domain directives of a
+// gop.mod file:
+//
+//	domain sql github.com/foo/sqltext
+//	domain (
+//		yaml github.com/foo/yamltext
+//	)
+func ModDomains(opt *modfile.File) map[string]string {
+	if opt == nil || opt.Syntax == nil {
+		return nil
+	}
+	var ret map[string]string
+	add := func(args []string) {
+		if len(args) != 2 {
+			return
+		}
+		path := args[1]
+		if v, err := strconv.Unquote(path); err == nil {
+			path = v
+		}
+		if ret == nil {
+			ret = make(map[string]string)
+		}
+		ret[args[0]] = path
+	}
+	for _, stmt := range opt.Syntax.Stmt {
+		switch x := stmt.(type) {
+		case *modfile.Line:
+			if x.Token[0] == "domain" {
+				add(x.Token[1:])
+			}
+		case *modfile.LineBlock:
+			if x.Token[0] == "domain" {
+				for _, line := range x.Line {
+					add(line.Token)
+				}
+			}
+		}
+	}
+	return ret
 }
 
 type nodeInterp struct {
@@ -349,6 +423,15 @@ type pkgCtx struct {
 
 	goxMain      int // normal gox files with main func
 	goxMainClass string
+
+	domains  map[string]string                  // domain name => package path
+	checkers map[string]func(text string) error // package path => checker, see Config.DomainCheckers
+
+	warnCtx
+
+	maxErrors int
+	npoison   int // number of poisoned values used, see recoverExpr
+	ntrial    int // > 0 when compiling a call to try an overload
 }
 
 type pkgImp struct {
@@ -407,6 +490,9 @@ func (p *blockCtx) findImport(name string) (pi pkgImp, ok bool) {
 	if !ok && p.autoimps != nil {
 		pi, ok = p.autoimps[name]
 	}
+	if ok && pi.pkgName != nil {
+		p.use(pi.pkgName)
+	}
 	return
 }
 
@@ -423,9 +509,28 @@ func (p *pkgCtx) handleErrorf(pos token.Pos, format string, args ...any) {
 }
 
 func (p *pkgCtx) handleErr(err error) {
+	msg := err.Error()
+	for _, e := range p.errs {
+		if e.Error() == msg {
+			return
+		}
+	}
 	p.errs = append(p.errs, err)
+	if p.maxErrors > 0 && len(p.errs) >= p.maxErrors {
+		panic(errTooManyErrors)
+	}
 }
 
+type tooManyErrors struct{}
+
+func (tooManyErrors) Error() string {
+	return "too many errors"
+}
+
+// errTooManyErrors is raised when Config.MaxErrors is reached, to stop
+// compiling.
+var errTooManyErrors error = tooManyErrors{}
+
 func (p *pkgCtx) loadNamed(at *gogen.Package, t *types.Named) {
 	o := t.Obj()
 	if o.Pkg() == at.Types {
@@ -471,6 +576,9 @@ func (p *pkgCtx) handleRecover(e any, src ast.Node) {
 }
 
 func (p *pkgCtx) recoverErr(e any, src ast.Node) error {
+	if e == errTooManyErrors {
+		panic(e)
+	}
 	err, ok := e.(error)
 	if !ok {
 		if src != nil {
@@ -509,6 +617,12 @@ func NewPackage(pkgPath string, pkg *ast.Package, conf *Config) (p *gogen.Packag
 		overpos:    make(map[string]token.Pos),
 		syms:       make(map[string]loader),
 		generics:   make(map[string]bool),
+		domains:    conf.Domains,
+		checkers:   conf.DomainCheckers,
+		maxErrors:  conf.MaxErrors,
+	}
+	if !conf.Outline {
+		ctx.warnCtx.init(conf.OnWarning)
 	}
 	confGox := &gogen.Config{
 		Types:           conf.Types,
@@ -534,7 +648,10 @@ func NewPackage(pkgPath string, pkg *ast.Package, conf *Config) (p *gogen.Packag
 	if enableRecover {
 		defer func() {
 			if e := recover(); e != nil {
-				ctx.handleRecover(e, nil)
+				if e != errTooManyErrors {
+					e = ctx.recoverErr(e, nil)
+				}
+				ctx.errs = append(ctx.errs, e.(error))
 				err = ctx.errs.ToError()
 			}
 		}()
@@ -641,6 +758,7 @@ func NewPackage(pkgPath string, pkg *ast.Package, conf *Config) (p *gogen.Packag
 	for _, load := range ctx.inits {
 		load()
 	}
+	ctx.warnUnused()
 	err = ctx.complete()
 
 	if mainClass != "" { // generate classfile main func
@@ -1497,6 +1615,9 @@ func loadImport(ctx *blockCtx, spec *ast.ImportSpec) {
 			return
 		}
 	}
+	if ctx.isGopFile {
+		ctx.defImport(pkgName)
+	}
 	ctx.imports[name] = pkgImp{pkg, pkgName}
 }
 
@@ -1579,8 +1700,12 @@ func loadVars(ctx *blockCtx, v *ast.ValueSpec, doc *ast.CommentGroup, global boo
 					}
 				case *ast.SliceLit:
 					compileSliceLit(ctx, e, typ)
+				case *ast.MatrixLit:
+					compileMatrixLit(ctx, e, typ)
 				case *ast.CompositeLit:
 					compileCompositeLit(ctx, e, typ, false)
+				case *ast.EnvExpr:
+					compileEnvExpr(ctx, e, typ)
 				default:
 					compileExpr(ctx, val)
 				}
@@ -1589,6 +1714,9 @@ func loadVars(ctx *blockCtx, v *ast.ValueSpec, doc *ast.CommentGroup, global boo
 		cb.EndInit(nv)
 	}
 	defNames(ctx, v.Names, scope)
+	if !global {
+		ctx.defLocals(v.Names, scope)
+	}
 }
 
 func defNames(ctx *blockCtx, names []*ast.Ident, scope *types.Scope) {
@@ -19,6 +19,10 @@ package cl_test
 import (
 	"strings"
 	"testing"
+
+	"github.com/goplus/gop/cl"
+	"github.com/goplus/gop/cl/cltest"
+	"github.com/goplus/mod/modfile"
 )
 
 func TestArrowOp(t *testing.T) {
@@ -1041,6 +1045,151 @@ func main() {
 `)
 }
 
+func TestStringLitFormat(t *testing.T) {
+	gopClTest(t, `
+type T struct{}
+
+price, n, name, ok := 3.14159, 255, "gop", true
+var f float32
+var u uint8
+var v T
+println "${price:%.2f}|${n:08x}|${u:X}|${name:-10s}|${ok:5t}|${f:g}|${n:c}|${name:q}|${n:+d}|${v:v}"
+`, `package main
+
+import (
+	"fmt"
+	"github.com/goplus/gop/builtin/strfmt"
+	"github.com/qiniu/x/stringutil"
+	"strconv"
+	"strings"
+)
+
+type T struct {
+}
+
+func main() {
+	price, n, name, ok := 3.14159, 255, "gop", true
+	var f float32
+	var u uint8
+	var v T
+	fmt.Println(stringutil.Concat(strconv.FormatFloat(price, 'f', 2, 64), "|", strfmt.ZeroPad(strconv.FormatInt(int64(n), 16), 8), "|", strings.ToUpper(strconv.FormatUint(uint64(u), 16)), "|", strfmt.PadRight(name, 10), "|", strfmt.Pad(strconv.FormatBool(ok), 5), "|", strconv.FormatFloat(float64(f), 'g', -1, 32), "|", string(int32(n)), "|", strconv.Quote(name), "|", fmt.Sprintf("%+d", n), "|", fmt.Sprintf("%v", v)))
+}
+`)
+}
+
+func TestStringLitFormatNamedBool(t *testing.T) {
+	gopClTest(t, `
+type B bool
+
+var b B
+echo "${b:t}"
+`, `package main
+
+import (
+	"fmt"
+	"strconv"
+)
+
+type B bool
+
+var b B
+
+func main() {
+	fmt.Println(strconv.FormatBool(bool(b)))
+}
+`)
+}
+
+func TestStringLitFormatStringer(t *testing.T) {
+	gopClTest(t, `
+import "time"
+
+type Celsius float64
+
+func (c *Celsius) String() string {
+	return "C"
+}
+
+d := 1500 * time.Millisecond
+c := new(Celsius)
+println "${d:v}|${d:10s}|${d:d}|${*c:.1f}|${c:s}"
+`, `package main
+
+import (
+	"fmt"
+	"github.com/qiniu/x/stringutil"
+	"strconv"
+	"time"
+)
+
+type Celsius float64
+
+func (c *Celsius) String() string {
+	return "C"
+}
+func main() {
+	d := 1500 * time.Millisecond
+	c := new(Celsius)
+	fmt.Println(stringutil.Concat(fmt.Sprintf("%v", d), "|", fmt.Sprintf("%10s", d), "|", strconv.FormatInt(int64(d), 10), "|", strconv.FormatFloat(float64(*c), 'f', 1, 64), "|", fmt.Sprintf("%s", c)))
+}
+`)
+}
+
+func TestEnvExpr(t *testing.T) {
+	gopClTest(t, `
+import "time"
+
+port := ${PORT:-8080}
+var debug bool = ${DEBUG}
+var timeout time.Duration
+timeout = ${TIMEOUT:-5 * time.Second}
+echo ${HOST:-"localhost"}, $HOME, port, debug, timeout
+`, `package main
+
+import (
+	"fmt"
+	"github.com/goplus/gop/builtin/envx"
+	"os"
+	"time"
+)
+
+func main() {
+	port := envx.Int(os.Getenv, "PORT", 8080)
+	var debug bool = envx.Bool(os.Getenv, "DEBUG", false)
+	var timeout time.Duration
+	timeout = envx.Duration(os.Getenv, "TIMEOUT", 5*time.Second)
+	fmt.Println(envx.String(os.Getenv, "HOST", "localhost"), os.Getenv("HOME"), port, debug, timeout)
+}
+`)
+	gopClTest(t, `
+func Gop_Env(name string) string {
+	return name
+}
+
+func rate() float64 {
+	return ${RATE}
+}
+
+echo ${USER}, ${PI:-3.14}, rate()
+`, `package main
+
+import (
+	"fmt"
+	"github.com/goplus/gop/builtin/envx"
+)
+
+func Gop_Env(name string) string {
+	return name
+}
+func rate() float64 {
+	return envx.Float64(Gop_Env, "RATE", 0)
+}
+func main() {
+	fmt.Println(Gop_Env("USER"), envx.Float64(Gop_Env, "PI", 3.14), rate())
+}
+`)
+}
+
 func TestFileOpen(t *testing.T) {
 	gopClTest(t, `
 for line <- open("foo.txt")! {
@@ -1742,3 +1891,58 @@ func main() {
 }
 `)
 }
+
+func TestDomainTextLit(t *testing.T) {
+	conf := *cltest.Conf
+	conf.Domains = map[string]string{"e": "errors"}
+	cltest.DoExt(t, &conf, "main", `
+echo e`+"`oops`"+`
+`, `package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+func main() {
+	fmt.Println(errors.New(`+"`oops`"+`))
+}
+`)
+	cltest.DoExt(t, &conf, "main", `
+echo e"oops\n"
+`, `package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+func main() {
+	fmt.Println(errors.New("oops\n"))
+}
+`)
+}
+
+func TestModDomains(t *testing.T) {
+	f, err := modfile.ParseLax("gop.mod", []byte(`
+gop 1.2
+
+domain sql github.com/foo/sqltext
+
+domain (
+	yaml "github.com/foo/yamltext"
+	graphql github.com/foo/graphql
+)
+`), nil)
+	if err != nil {
+		t.Fatal("modfile.ParseLax:", err)
+	}
+	domains := cl.ModDomains(f)
+	if len(domains) != 3 || domains["sql"] != "github.com/foo/sqltext" ||
+		domains["yaml"] != "github.com/foo/yamltext" || domains["graphql"] != "github.com/foo/graphql" {
+		t.Fatal("ModDomains:", domains)
+	}
+	if cl.ModDomains(nil) != nil {
+		t.Fatal("ModDomains(nil) != nil")
+	}
+}
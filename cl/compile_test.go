@@ -1383,6 +1383,78 @@ var ret int = func() (_gop_ret int) {
 `)
 }
 
+func TestErrWrapFatal(t *testing.T) {
+	gopClTest(t, `
+import "strconv"
+
+var x = strconv.Atoi("1")?
+
+a := strconv.Atoi("2")?
+echo a, x
+`, `package main
+
+import (
+	"fmt"
+	"github.com/qiniu/x/errors"
+	"log"
+	"strconv"
+)
+
+var x = func() (_gop_ret int) {
+	var _gop_err error
+	_gop_ret, _gop_err = strconv.Atoi("1")
+	if _gop_err != nil {
+		_gop_err = errors.NewFrame(_gop_err, "strconv.Atoi(\"1\")", "/foo/bar.gop", 4, "main.init")
+		log.Fatalln(_gop_err)
+	}
+	return
+}()
+
+func main() {
+	var _autoGo_1 int
+	{
+		var _gop_err error
+		_autoGo_1, _gop_err = strconv.Atoi("2")
+		if _gop_err != nil {
+			_gop_err = errors.NewFrame(_gop_err, "strconv.Atoi(\"2\")", "/foo/bar.gop", 6, "main.main")
+			log.Fatalln(_gop_err)
+		}
+		goto _autoGo_2
+	_autoGo_2:
+	}
+	a := _autoGo_1
+	fmt.Println(a, x)
+}
+`)
+	gopClTest(t, `
+import "os"
+
+func init() {
+	os.Chdir("/")?
+}
+`, `package main
+
+import (
+	"github.com/qiniu/x/errors"
+	"log"
+	"os"
+)
+
+func init() {
+	{
+		var _gop_err error
+		_gop_err = os.Chdir("/")
+		if _gop_err != nil {
+			_gop_err = errors.NewFrame(_gop_err, "os.Chdir(\"/\")", "/foo/bar.gop", 5, "main.init")
+			log.Fatalln(_gop_err)
+		}
+		goto _autoGo_1
+	_autoGo_1:
+	}
+}
+`)
+}
+
 func TestErrWrapCommand(t *testing.T) {
 	gopClTest(t, `
 func mkdir(name string) error {
@@ -1894,6 +1966,77 @@ var z vector = vector{1, 2, 3}
 `)
 }
 
+func TestMatrixLit(t *testing.T) {
+	gopClTest(t, `
+a := [1, 2; 3, 4.5]
+b := [1, 2
+	3, 4]
+var c [][]float32 = [1, 2; 3, 4]
+`, `package main
+
+func main() {
+	a := [][]float64{[]float64{1, 2}, []float64{3, 4.5}}
+	b := [][]int{[]int{1, 2}, []int{3, 4}}
+	var c [][]float32 = [][]float32{[]float32{1, 2}, []float32{3, 4}}
+}
+`)
+	gopClTest(t, `
+row := []int{4, 5, 6}
+rows := [][]int{{7, 8, 9}}
+a := [1, 2, 3; row...]
+b := [1, 2, 3; rows...; rows...; 4, 5, 6; 7, 8, 9]
+`, `package main
+
+func main() {
+	row := []int{4, 5, 6}
+	rows := [][]int{[]int{7, 8, 9}}
+	a := [][]int{[]int{1, 2, 3}, row}
+	b := append(append(append([][]int{[]int{1, 2, 3}}, rows...), rows...), []int{4, 5, 6}, []int{7, 8, 9})
+}
+`)
+}
+
+func TestMatrixLitCtor(t *testing.T) {
+	gopClTest(t, `
+type Matrix struct {
+	data [][]float64
+}
+
+func Gop_Matrix_Matrix(rows [][]float64) Matrix {
+	return Matrix{rows}
+}
+
+func trace(m Matrix) {}
+
+func newMatrix() Matrix {
+	return [1, 2; 3, 4]
+}
+
+var m Matrix = [1, 2; 3, 4]
+trace [5, 6; 7, 8]
+`, `package main
+
+type Matrix struct {
+	data [][]float64
+}
+
+func Gop_Matrix_Matrix(rows [][]float64) Matrix {
+	return Matrix{rows}
+}
+func trace(m Matrix) {
+}
+func newMatrix() Matrix {
+	return Gop_Matrix_Matrix([][]float64{[]float64{1, 2}, []float64{3, 4}})
+}
+
+var m Matrix = Gop_Matrix_Matrix([][]float64{[]float64{1, 2}, []float64{3, 4}})
+
+func main() {
+	trace(Gop_Matrix_Matrix([][]float64{[]float64{5, 6}, []float64{7, 8}}))
+}
+`)
+}
+
 func TestChan(t *testing.T) {
 	gopClTest(t, `
 a := make(chan int, 10)
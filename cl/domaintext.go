@@ -0,0 +1,116 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cl
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/goplus/gop/ast"
+	"github.com/goplus/gop/token"
+	"github.com/goplus/gop/tpl/encoding"
+	"github.com/goplus/gop/tpl/encoding/csv"
+	"github.com/goplus/gop/tpl/encoding/json"
+	"github.com/goplus/gop/tpl/encoding/regexp"
+	"github.com/goplus/gop/tpl/encoding/regexposix"
+	"github.com/goplus/gop/tpl/encoding/xml"
+)
+
+// -----------------------------------------------------------------------------
+
+// domainCheckers maps the package path of a text domain to the function which
+// checks its texts at compile time, unless overridden by Config.DomainCheckers.
+var domainCheckers = map[string]func(text string) error{
+	tplPkgPath + "/encoding/csv":        csv.Check,
+	tplPkgPath + "/encoding/json":       json.Check,
+	tplPkgPath + "/encoding/regexp":     regexp.Check,
+	tplPkgPath + "/encoding/regexposix": regexposix.Check,
+	tplPkgPath + "/encoding/xml":        xml.Check,
+}
+
+// checkDomainText checks the text of v by the checker of its domain package,
+// see Config.DomainCheckers.
+func checkDomainText(ctx *blockCtx, v *ast.DomainTextLit, pkgPath string) {
+	check, ok := ctx.checkers[pkgPath]
+	if !ok {
+		check = domainCheckers[pkgPath]
+	}
+	if check == nil {
+		return
+	}
+	text, err := strconv.Unquote(v.Value)
+	if err != nil {
+		return
+	}
+	if err = check(text); err != nil {
+		off := 0
+		if e, ok := err.(*encoding.SyntaxError); ok {
+			off = e.Offset
+		}
+		ctx.handleErrorf(litPos(ctx.fset, v, off), "%s: %v", v.Domain.Name, err)
+	}
+}
+
+// litPos returns the position of the byte at offset off of the unquoted text
+// of v.
+func litPos(fset *token.FileSet, v *ast.DomainTextLit, off int) token.Pos {
+	if v.Value[0] != '`' {
+		return v.ValuePos + token.Pos(quotedOffset(v.Value, off))
+	}
+	// The carriage returns of the raw string are discarded by the scanner, so
+	// the position is computed from the start of its line in the source.
+	off++ // skip the opening quote
+	if off > len(v.Value) {
+		off = len(v.Value)
+	}
+	nl := strings.LastIndexByte(v.Value[:off], '\n')
+	if nl < 0 {
+		return v.ValuePos + token.Pos(off)
+	}
+	f := fset.File(v.ValuePos)
+	if f == nil {
+		return v.ValuePos + token.Pos(off)
+	}
+	line := f.Line(v.ValuePos) + strings.Count(v.Value[:nl], "\n") + 1
+	if line > f.LineCount() {
+		return v.ValuePos + token.Pos(off)
+	}
+	return f.LineStart(line) + token.Pos(off-nl-1)
+}
+
+// quotedOffset returns the offset in the interpreted string literal lit of
+// the byte at offset off of its unquoted text.
+func quotedOffset(lit string, off int) int {
+	s, i := lit[1:len(lit)-1], 1
+	for n := 0; n < off && s != ""; {
+		value, multibyte, tail, err := strconv.UnquoteChar(s, '"')
+		if err != nil {
+			break
+		}
+		if value < utf8.RuneSelf || !multibyte {
+			n++
+		} else {
+			n += utf8.RuneLen(value)
+		}
+		i += len(s) - len(tail)
+		s = tail
+	}
+	return i
+}
+
+// -----------------------------------------------------------------------------
@@ -17,12 +17,17 @@
 package cl_test
 
 import (
+	"errors"
 	"fmt"
 	"path/filepath"
 	"runtime"
 	"testing"
 
+	"github.com/goplus/gop/cl"
 	"github.com/goplus/gop/cl/cltest"
+	"github.com/goplus/gop/parser"
+	"github.com/goplus/gop/parser/fsx/memfs"
+	"github.com/goplus/gop/tpl/encoding"
 )
 
 func codeErrorTest(t *testing.T, msg, src string) {
@@ -488,11 +493,26 @@ func (p *[]byte) foo() {
 }
 
 func TestErrEnvOp(t *testing.T) {
-	codeErrorTest(t, `bar.gop:2:6: operator $name undefined`, `
-echo ${name}
+	codeErrorTest(t, `bar.gop:2:12: cannot use 'a' (type untyped rune) as default value of $id, want string, int, float64, bool or time.Duration`, `
+echo ${id:-'a'}
+`)
+	codeErrorTest(t, `bar.gop:2:24: cannot use "x" (type untyped string) as default value of $PORT, want int`, `
+var port int = ${PORT:-"x"}
+`)
+	codeErrorTest(t, `bar.gop:2:26: cannot use 1.5 (type untyped float) as default value of $RETRY, want int`, `
+var retry int = ${RETRY:-1.5}
+`)
+	codeErrorTest(t, `bar.gop:5:28: cannot use s (type string) as default value of $D, want time.Duration`, `
+import "time"
+
+s := "1s"
+var d time.Duration = ${D:-s}
 `)
-	codeErrorTest(t, `bar.gop:2:1: operator $id undefined`, `
-$id
+	codeErrorTest(t, `bar.gop:5:6: cannot use Gop_Env (type func(name string) int) to get $name, want func(name string) string`, `
+func Gop_Env(name string) int {
+	return 0
+}
+echo ${name}
 `)
 }
 
@@ -502,6 +522,17 @@ echo "${[]}"
 `)
 }
 
+func TestErrStringLitFormat(t *testing.T) {
+	codeErrorTest(t, `bar.gop:3:14: format %d has arg name of wrong type string`, `
+name := "gop"
+echo "${name:d}"
+`)
+	codeErrorTest(t, `bar.gop:3:11: format %.2f has arg n of wrong type int`, `
+n := 1
+echo "${n:%.2f}"
+`)
+}
+
 func TestErrStructLit(t *testing.T) {
 	codeErrorTest(t,
 		`bar.gop:3:39: too many values in struct{x int; y string}{...}`, `
@@ -592,6 +623,105 @@ b := []int{2: a}
 `)
 }
 
+func TestErrMatrixLit(t *testing.T) {
+	codeErrorTest(t,
+		`bar.gop:3:2: inconsistent matrix column count: got 2, want 3`,
+		`
+a := [1, 2, 3
+	4, 5]
+`)
+	codeErrorTest(t,
+		`bar.gop:4:2: cannot spread a (type int) as matrix rows`,
+		`
+a := 1
+b := [1, 2
+	a...]
+`)
+	codeErrorTest(t,
+		`bar.gop:4:5: cannot use a... with other elements in a matrix row`,
+		`
+a := []int{1}
+b := [1, 2
+	0, a...]
+`)
+}
+
+func TestErrDomainTextLit(t *testing.T) {
+	codeErrorTest(t,
+		`bar.gop:2:6: unknown domain: yaml`, `
+echo yaml`+"`a: 1`"+`
+`)
+	codeErrorTest(t,
+		`bar.gop:4:6: fmt is not a text domain: package fmt has no New or NewEx function`, `
+import "fmt"
+
+echo fmt`+"`a`"+`
+`)
+}
+
+func TestErrDomainTextCheck(t *testing.T) {
+	codeErrorTest(t,
+		`bar.gop:3:2: json: invalid character '}' looking for beginning of object key string`, `
+echo json`+"`{\"a\": 1,"+`
+	}`+"`"+`
+`)
+	codeErrorTest(t,
+		`bar.gop:2:20: regexp: error parsing regexp: missing closing ]: `+"`[0-9`", `
+echo regexp`+"`^[a-z]+[0-9`"+`
+`)
+	codeErrorTest(t,
+		`bar.gop:2:17: regexp: error parsing regexp: invalid character class range: `+"`c-a`", `
+echo regexp`+"`c-a[c-a]`"+`
+`)
+	codeErrorTest(t, // the carriage returns of a raw string are discarded by the scanner
+		`bar.gop:3:2: json: invalid character '}' looking for beginning of object key string`,
+		"\r\necho json`{\"a\": 1,\r\n\t}`\r\n")
+	codeErrorTest(t,
+		`bar.gop:3:1: csv: record on line 2: wrong number of fields`, `
+echo csv`+"`a,b\nc`"+`
+`)
+	codeErrorTest(t,
+		`bar.gop:2:16: xml: XML syntax error on line 1: element <a> closed by </b>`, `
+echo xml`+"`<a></b>`"+`
+`)
+	codeErrorTest(t,
+		`bar.gop:4:1: xml: XML syntax error on line 3: expected attribute name in element`, `
+echo xml`+"`<a>\n<b\n</a>`"+`
+`)
+	codeErrorTest(t, // an interpreted string literal
+		`bar.gop:2:15: regexp: error parsing regexp: missing closing ]: `+"`[0-9`", `
+echo regexp"\t[0-9"
+`)
+	codeErrorTest(t,
+		`bar.gop:2:26: json: invalid character '}' looking for beginning of object key string`, `
+echo json"{\"\u00e9\": 1,}"
+`)
+}
+
+func TestDomainCheckers(t *testing.T) {
+	fs := memfs.SingleFile("/foo", "bar.gop", `
+echo json`+"`{}`"+`
+echo xml`+"`<a></b>`"+`
+`)
+	pkgs, err := parser.ParseFSDir(cltest.Conf.Fset, fs, "/foo", parser.Config{})
+	if err != nil {
+		t.Fatal("parser.ParseFSDir:", err)
+	}
+	conf := *cltest.Conf
+	conf.NoFileLine = false
+	conf.RelativeBase = "/foo"
+	conf.DomainCheckers = map[string]func(string) error{
+		"github.com/goplus/gop/tpl/encoding/json": func(text string) error {
+			return &encoding.SyntaxError{Offset: 1, Err: errors.New("no json")}
+		},
+		"github.com/goplus/gop/tpl/encoding/xml": nil, // disables the builtin checker
+	}
+	_, err = cl.NewPackage("", pkgs["main"], &conf)
+	if err == nil || err.Error() != "bar.gop:2:12: json: no json" {
+		t.Fatal("cl.NewPackage:", err)
+	}
+}
+
 func TestErrMapLit(t *testing.T) {
 	codeErrorTest(t, `bar.gop:4:6: cannot use 1 (type untyped int) as type string in map key`, `
 func foo(map[string]string) {}
@@ -974,8 +1104,7 @@ println "hello"
 func TestErrVarInFunc(t *testing.T) {
 	codeErrorTest(t, `bar.gop:6:10: not enough arguments in call to set
 	have (untyped string)
-	want (name string, v int)
-bar.gop:7:10: undefined: a`, `
+	want (name string, v int)`, `
 func set(name string, v int) string {
 	return name
 }
@@ -1117,3 +1246,39 @@ var a = struct{v int}{(x => x)}
 var a = struct{v int}{v: (x => x)}
 `)
 }
+
+func TestErrPoisoned(t *testing.T) {
+	codeErrorTest(t, `bar.gop:2:6: undefined: undefinedFn
+bar.gop:5:6: undefined: foo
+bar.gop:5:14: undefined: bar
+bar.gop:6:13: cannot use "hi" (type untyped string) as type int in assignment`, `
+x := undefinedFn(1)
+y := x + 1
+echo y, x.foo
+echo foo(1), bar(2)
+var z int = "hi"
+var w = z + x
+echo w, "${x}"
+`)
+}
+
+func TestErrMaxErrors(t *testing.T) {
+	fs := memfs.SingleFile("/foo", "bar.gop", `
+echo a
+echo b
+echo c
+`)
+	pkgs, err := parser.ParseFSDir(cltest.Conf.Fset, fs, "/foo", parser.Config{})
+	if err != nil {
+		t.Fatal("parser.ParseFSDir:", err)
+	}
+	conf := *cltest.Conf
+	conf.RelativeBase = "/foo"
+	conf.MaxErrors = 2
+	_, err = cl.NewPackage("", pkgs["main"], &conf)
+	if err == nil || err.Error() != `bar.gop:2:6: undefined: a
+bar.gop:3:6: undefined: b
+too many errors` {
+		t.Fatal("MaxErrors:", err)
+	}
+}
@@ -168,7 +168,11 @@ func compileIdent(ctx *blockCtx, ident *ast.Ident, flags int) (pkg gogen.PkgRef,
 	}
 
 find:
+	if v, ok := o.(*types.Var); ok && isPoisoned(v.Type()) {
+		ctx.npoison++
+	}
 	if fvalue {
+		ctx.use(o)
 		cb.Val(o, ident)
 	} else {
 		cb.VarRef(o, ident)
@@ -184,47 +188,311 @@ find:
 	return
 }
 
-/*
-func compileMatrixLit(ctx *blockCtx, v *ast.MatrixLit) {
-	cb := ctx.cb
-	ncol := -1
+// compileMatrixLit compiles a matrix literal `[a, b; c, d]` to a [][]T value.
+// If typ has a matrix constructor (see matrixCtor), the matrix literal is
+// passed to it. A row `x...` spreads x: a slice x of elements is used as a
+// row, and a slice x of rows is inserted as rows.
+func compileMatrixLit(ctx *blockCtx, v *ast.MatrixLit, typ types.Type, noPanic ...bool) (err error) {
+	if noPanic != nil {
+		defer func() {
+			if e := recover(); e != nil { // TODO: don't use defer to capture error
+				err = ctx.recoverErr(e, v)
+			}
+		}()
+	}
+	pkg, cb := ctx.pkg, ctx.cb
+	if typ != nil {
+		if fn := matrixCtor(ctx, typ); fn != nil {
+			cb.Val(fn, v)
+			compileMatrixLit(ctx, v, fn.Type().(*types.Signature).Params().At(0).Type())
+			cb.CallWith(1, 0, v)
+			return
+		}
+	}
+	var tyRow types.Type
+	if t, ok := matrixType(ctx, typ); ok {
+		tyRow = t.Elem()
+	} else {
+		typ = nil
+	}
+
+	// compile the elements and the spread rows
+	ncol, n := -1, 0
 	for _, elts := range v.Elts {
-		switch n := len(elts); n {
-		case 1:
-			elt := elts[0]
-			if e, ok := elt.(*ast.Ellipsis); ok {
+		if len(elts) == 1 {
+			if e, ok := elts[0].(*ast.ElemEllipsis); ok {
 				compileExpr(ctx, e.Elt)
-				panic("TODO") // TODO(xsw): matrixLit with ellipsis
+				n++
+				continue
 			}
-			fallthrough
-		default:
-			if ncol < 0 {
-				ncol = n
-			} else if ncol != n {
-				ctx.handleErrorf(elts[0].Pos(), "inconsistent matrix column count: got %v, want %v", n, ncol)
+		}
+		if ncol < 0 {
+			ncol = len(elts)
+		} else if ncol != len(elts) {
+			ctx.handleErrorf(elts[0].Pos(), "inconsistent matrix column count: got %v, want %v", len(elts), ncol)
+		}
+		for _, elt := range elts {
+			if e, ok := elt.(*ast.ElemEllipsis); ok {
+				panic(ctx.newCodeErrorf(e.Pos(), "cannot use %v... with other elements in a matrix row", ctx.LoadExpr(e.Elt)))
+			}
+			compileExpr(ctx, elt)
+		}
+		n += len(elts)
+	}
+	stk := cb.InternalStack()
+	args := append([]*gogen.Element(nil), stk.GetArgs(n)...)
+	stk.PopN(n)
+
+	// classify the spread rows, and infer the element type
+	const (
+		matrixRowElts = iota
+		matrixRowSpread
+		matrixRowsSpread
+	)
+	kinds := make([]int, len(v.Elts))
+	elemTypes := make([]types.Type, 0, n)
+	i := 0
+	for row, elts := range v.Elts {
+		if e, ok := elts[0].(*ast.ElemEllipsis); ok && len(elts) == 1 {
+			arg := args[i]
+			t, ok := getUnderlying(ctx, arg.Type).(*types.Slice)
+			if !ok {
+				panic(ctx.newCodeErrorf(e.Pos(), "cannot spread %v (type %v) as matrix rows", ctx.LoadExpr(e.Elt), arg.Type))
+			}
+			elem := t.Elem()
+			kinds[row] = matrixRowSpread
+			if rows, ok := getUnderlying(ctx, elem).(*types.Slice); ok && !isMatrixElem(ctx, tyRow, elem) {
+				elem = rows.Elem()
+				kinds[row] = matrixRowsSpread
 			}
-			for _, elt := range elts {
-				compileExpr(ctx, elt)
+			elemTypes = append(elemTypes, elem)
+			i++
+			continue
+		}
+		for range elts {
+			elemTypes = append(elemTypes, args[i].Type)
+			i++
+		}
+	}
+	if typ == nil {
+		tyRow = types.NewSlice(gogen.Default(pkg, boundMatrixElem(pkg, elemTypes)))
+		typ = types.NewSlice(tyRow)
+	}
+
+	// a [][]T literal, with the spread rows of rows appended to it
+	nappend, spread := 0, false
+	for _, kind := range kinds {
+		if kind == matrixRowsSpread {
+			nappend++
+			spread = true
+		} else if spread { // rows appended after a spread of rows
+			nappend++
+			spread = false
+		}
+	}
+	for j := 0; j < nappend; j++ {
+		cb.Val(pkg.Builtin().Ref("append"), v)
+	}
+	i = 0
+	nrow, lit := 0, true
+	flush := func() {
+		if lit {
+			cb.SliceLitEx(typ, nrow, false, v)
+		} else if nrow > 0 {
+			cb.CallWith(1+nrow, 0, v)
+		}
+		nrow, lit = 0, false
+	}
+	for row, elts := range v.Elts {
+		switch kinds[row] {
+		case matrixRowsSpread:
+			flush()
+			stk.Push(args[i])
+			cb.CallWith(2, gogen.InstrFlagEllipsis, v)
+			i++
+			continue
+		case matrixRowSpread:
+			stk.Push(args[i])
+			i++
+		default:
+			for range elts {
+				stk.Push(args[i])
+				i++
 			}
-			cb.SliceLitEx(...)
+			cb.SliceLitEx(tyRow, len(elts), false, v)
+		}
+		nrow++
+	}
+	flush()
+	return
+}
+
+// matrixCtor returns the matrix constructor `func Gop_T_Matrix(rows [][]E) T`
+// of a named type T, which is declared in the package of T.
+func matrixCtor(ctx *blockCtx, typ types.Type) *types.Func {
+	t, ok := typ.(*types.Named)
+	if !ok {
+		return nil
+	}
+	obj := t.Obj()
+	if obj.Pkg() == nil {
+		return nil
+	}
+	name := "Gop_" + obj.Name() + "_Matrix"
+	if obj.Pkg() == ctx.pkg.Types {
+		ctx.loadSymbol(name)
+	}
+	fn, ok := obj.Pkg().Scope().Lookup(name).(*types.Func)
+	if !ok {
+		return nil
+	}
+	sig := fn.Type().(*types.Signature)
+	if sig.Params().Len() != 1 || sig.Results().Len() != 1 || sig.TypeParams() != nil {
+		return nil
+	}
+	if _, ok := matrixType(ctx, sig.Params().At(0).Type()); !ok {
+		return nil
+	}
+	return fn
+}
+
+// matrixType checks if typ is a [][]T type (or a named type of it).
+func matrixType(ctx *blockCtx, typ types.Type) (t *types.Slice, ok bool) {
+	if typ == nil {
+		return
+	}
+	if t, ok = getUnderlying(ctx, typ).(*types.Slice); ok {
+		if row, isRow := getUnderlying(ctx, t.Elem()).(*types.Slice); isRow {
+			return t, !isTypeParam(row.Elem())
+		}
+	}
+	return nil, false
+}
+
+// isMatrixElem checks if elem is the element type of the matrix rows tyRow.
+func isMatrixElem(ctx *blockCtx, tyRow, elem types.Type) bool {
+	if tyRow == nil {
+		return false
+	}
+	return types.Identical(getUnderlying(ctx, tyRow).(*types.Slice).Elem(), elem)
+}
+
+// boundMatrixElem returns the type to which all the elements of a matrix
+// literal are assignable.
+func boundMatrixElem(pkg *gogen.Package, elemTypes []types.Type) types.Type {
+	var bound types.Type
+	for _, t := range elemTypes {
+		if bound == t {
+			// nothing to do
+		} else if bound == nil || gogen.AssignableTo(pkg, bound, t) {
+			bound = t
+		} else if !gogen.AssignableTo(pkg, t, bound) {
+			return gogen.TyEmptyInterface
 		}
 	}
+	return bound
 }
-*/
 
-func compileEnvExpr(ctx *blockCtx, v *ast.EnvExpr) {
+const (
+	envxPkgPath = "github.com/goplus/gop/builtin/envx"
+)
+
+// compileEnvExpr compiles ${name} and ${name:-default}. In a classfile whose
+// receiver has a Gop_Env method, ${name} is recv.Gop_Env(name). Otherwise the
+// value of name is got by the Gop_Env function of the package (if any) or
+// os.Getenv, and converted to the type of default (or the expected type) if
+// it is int, float64, bool or time.Duration.
+func compileEnvExpr(ctx *blockCtx, v *ast.EnvExpr, expected types.Type) {
 	cb := ctx.cb
+	name := v.Name
 	if ctx.isClass { // in a Go+ class file
 		if recv := classRecv(cb); recv != nil {
 			if gopMember(cb, recv, "Gop_Env", v) == nil {
-				name := v.Name
+				if v.Default != nil {
+					panic(ctx.newCodeErrorf(v.Colon, "default value of $%v unsupported by %v.Gop_Env", name, recv.Type()))
+				}
 				cb.Val(name.Name, name).CallWith(1, 0, v)
 				return
 			}
+			cb.InternalStack().Pop()
+		}
+	}
+
+	pkg := ctx.pkg
+	var getenv any = pkg.Import("os").Ref("Getenv")
+	ctx.loadSymbol("Gop_Env")
+	if o := pkg.Types.Scope().Lookup("Gop_Env"); o != nil {
+		if !isGetenvFunc(o) {
+			panic(ctx.newCodeErrorf(
+				v.Pos(), "cannot use Gop_Env (type %v) to get $%v, want func(name string) string", o.Type(), name))
 		}
+		getenv = o
+	}
+	typ, fn := expected, envxFunc(expected)
+	if v.Default == nil {
+		if fn == "" || fn == "String" { // getenv(name)
+			cb.Val(getenv).Val(name.Name, name).CallWith(1, 0, v)
+			return
+		}
+		cb.Val(pkg.Import(envxPkgPath).Ref(fn)).Val(getenv).Val(name.Name, name).ZeroLit(typ)
+		cb.CallWith(3, 0, v)
+		return
+	}
+
+	compileExpr(ctx, v.Default)
+	stk := cb.InternalStack()
+	defval := stk.Pop()
+	if isPoisoned(defval.Type) {
+		stk.Push(defval)
+		return
 	}
-	invalidVal(cb)
-	ctx.handleErrorf(v.Pos(), "operator $%v undefined", v.Name)
+	if fn == "" {
+		typ = types.Default(defval.Type)
+		if fn = envxFunc(typ); fn == "" {
+			panic(ctx.newCodeErrorf(v.Default.Pos(),
+				"cannot use %s (type %v) as default value of $%v, want string, int, float64, bool or time.Duration",
+				ctx.LoadExpr(v.Default), defval.Type, name))
+		}
+	} else if !gogen.AssignableConv(pkg, defval.Type, typ, defval) {
+		panic(ctx.newCodeErrorf(v.Default.Pos(), "cannot use %s (type %v) as default value of $%v, want %v",
+			ctx.LoadExpr(v.Default), defval.Type, name, typ))
+	}
+	cb.Val(pkg.Import(envxPkgPath).Ref(fn)).Val(getenv).Val(name.Name, name)
+	stk.Push(defval)
+	cb.CallWith(3, 0, v)
+}
+
+// envxFunc returns the function of package envx which gets an environment
+// variable of type typ.
+func envxFunc(typ types.Type) string {
+	switch t := typ.(type) {
+	case *types.Basic:
+		switch t.Kind() {
+		case types.String:
+			return "String"
+		case types.Int:
+			return "Int"
+		case types.Float64:
+			return "Float64"
+		case types.Bool:
+			return "Bool"
+		}
+	case *types.Named:
+		if o := t.Obj(); o.Name() == "Duration" && o.Pkg() != nil && o.Pkg().Path() == "time" {
+			return "Duration"
+		}
+	}
+	return ""
+}
+
+func isGetenvFunc(o types.Object) bool {
+	if fn, ok := o.(*types.Func); ok {
+		sig := fn.Type().(*types.Signature)
+		return sig.Recv() == nil && sig.Params().Len() == 1 && sig.Results().Len() == 1 && !sig.Variadic() &&
+			types.Identical(sig.Params().At(0).Type(), types.Typ[types.String]) &&
+			types.Identical(sig.Results().At(0).Type(), types.Typ[types.String])
+	}
+	return false
 }
 
 func classRecv(cb *gogen.CodeBuilder) *types.Var {
@@ -310,7 +578,38 @@ func callCmdNoArgs(ctx *blockCtx, src ast.Node, panicErr bool) (err error) {
 	return
 }
 
+// isPoisoned reports whether t is the type of a poisoned value, which is an
+// expression failed to compile, or an object defined by it.
+func isPoisoned(t types.Type) bool {
+	return t == types.Typ[types.Invalid]
+}
+
+// recoverExpr recovers from an error of compiling expr. The error is reported
+// unless it's derived from a poisoned value, and expr is replaced by a poisoned
+// value to go on compiling.
+func recoverExpr(ctx *blockCtx, expr ast.Expr, scope *types.Scope, base, npoison int) {
+	if e := recover(); e != nil {
+		cb := ctx.cb
+		stk := cb.InternalStack()
+		if e == errTooManyErrors || cb.Scope() != scope || stk.Len() < base {
+			panic(e)
+		}
+		if ctx.npoison == npoison {
+			ctx.handleRecover(e, expr)
+		}
+		ctx.npoison++
+		stk.SetLen(base)
+		stk.Push(&gogen.Element{
+			Val: &goast.BadExpr{}, Type: types.Typ[types.Invalid], Src: expr,
+		})
+	}
+}
+
 func compileExpr(ctx *blockCtx, expr ast.Expr, inFlags ...int) {
+	if enableRecover && ctx.ntrial == 0 {
+		cb := ctx.cb
+		defer recoverExpr(ctx, expr, cb.Scope(), cb.InternalStack().Len(), ctx.npoison)
+	}
 	switch v := expr.(type) {
 	case *ast.Ident:
 		flags, cmdNoArgs := identOrSelectorFlags(inFlags)
@@ -389,9 +688,9 @@ func compileExpr(ctx *blockCtx, expr ast.Expr, inFlags ...int) {
 	case *ast.FuncType:
 		ctx.cb.Typ(toFuncType(ctx, v, nil, nil), v)
 	case *ast.EnvExpr:
-		compileEnvExpr(ctx, v)
-	/* case *ast.MatrixLit:
-	compileMatrixLit(ctx, v) */
+		compileEnvExpr(ctx, v, nil)
+	case *ast.MatrixLit:
+		compileMatrixLit(ctx, v, nil)
 	case *ast.DomainTextLit:
 		compileDomainTextLit(ctx, v)
 	default:
@@ -703,6 +1002,10 @@ func compileCallExpr(ctx *blockCtx, v *ast.CallExpr, inFlags int) {
 	fnt := pfn.Type
 	fn := &fnType{}
 	fn.load(fnt)
+	if fn.next != nil || ifn != nil && mayBuiltinOrGopExec(ctx, ifn, v) {
+		ctx.ntrial++ // the errors of args are needed to try the next candidate
+		defer func() { ctx.ntrial-- }()
+	}
 	for fn != nil {
 		if err = compileCallArgs(ctx, pfn, fn, v, ellipsis, flags); err == nil {
 			if rec := ctx.recorder(); rec != nil {
@@ -723,6 +1026,14 @@ func toBasicLit(fn *ast.Ident) *ast.BasicLit {
 	return &ast.BasicLit{ValuePos: fn.NamePos, Kind: token.STRING, Value: strconv.Quote(fn.Name)}
 }
 
+func mayBuiltinOrGopExec(ctx *blockCtx, ifn *ast.Ident, v *ast.CallExpr) bool {
+	switch ifn.Name {
+	case "new", "delete":
+		return true
+	}
+	return v.IsCommand() && ctx.isClass
+}
+
 // maybe builtin new/delete: see TestSpxNewObj, TestMayBuiltinDelete
 // maybe Gop_Exec: see TestSpxGopExec
 func builtinOrGopExec(ctx *blockCtx, ifn *ast.Ident, v *ast.CallExpr, flags gogen.InstrFlags) error {
@@ -820,8 +1131,14 @@ func compileCallArgs(ctx *blockCtx, pfn *gogen.Element, fn *fnType, v *ast.CallE
 			if typetype {
 				return
 			}
+		case *ast.MatrixLit:
+			if err = compileMatrixLit(ctx, expr, fn.arg(i, ellipsis), true); err != nil {
+				return
+			}
 		case *ast.NumberUnitLit:
 			compileNumberUnitLit(ctx, expr, fn.arg(i, ellipsis))
+		case *ast.EnvExpr:
+			compileEnvExpr(ctx, expr, fn.arg(i, ellipsis))
 		default:
 			compileExpr(ctx, arg)
 		}
@@ -937,6 +1254,7 @@ func compileLambdaExpr(ctx *blockCtx, v *ast.LambdaExpr, sig *types.Signature) e
 		return err
 	}
 	results := makeLambdaResults(pkg, sig.Results())
+	warnShadowedParams(ctx, v.Lhs)
 	ctx.cb.NewClosure(params, results, false).BodyStart(pkg)
 	if len(v.Lhs) > 0 {
 		defNames(ctx, v.Lhs, ctx.cb.Scope())
@@ -958,6 +1276,7 @@ func compileLambdaExpr2(ctx *blockCtx, v *ast.LambdaExpr2, sig *types.Signature)
 		return err
 	}
 	results := makeLambdaResults(pkg, sig.Results())
+	warnShadowedParams(ctx, v.Lhs)
 	comments, once := ctx.cb.BackupComments()
 	fn := ctx.cb.NewClosure(params, results, false)
 	cb := fn.BodyStart(ctx.pkg, v.Body)
@@ -1051,6 +1370,9 @@ func compileStringLitEx(ctx *blockCtx, cb *gogen.CodeBuilder, lit *ast.BasicLit)
 			}
 			basicLit(cb, &ast.BasicLit{ValuePos: pos - 1, Value: quote + v + quote, Kind: token.STRING})
 			pos = next
+		case *ast.FormatExpr:
+			compileFormatExpr(ctx, v)
+			pos = v.End()
 		case ast.Expr:
 			flags := 0
 			if _, ok := v.(*ast.Ident); ok {
@@ -1058,7 +1380,7 @@ func compileStringLitEx(ctx *blockCtx, cb *gogen.CodeBuilder, lit *ast.BasicLit)
 			}
 			compileExpr(ctx, v, flags)
 			t := cb.Get(-1).Type
-			if t.Underlying() != types.Typ[types.String] {
+			if t.Underlying() != types.Typ[types.String] && !isPoisoned(t) {
 				if _, err := cb.Member("string", gogen.MemberFlagAutoProperty); err != nil {
 					if _, e2 := cb.Member("error", gogen.MemberFlagAutoProperty); e2 != nil {
 						if e, ok := err.(*gogen.CodeError); ok {
@@ -1082,17 +1404,36 @@ const (
 	tplPkgPath = "github.com/goplus/gop/tpl"
 )
 
+// builtinDomains maps the name of a builtin text domain to its package path.
+var builtinDomains = map[string]string{
+	"tpl":        tplPkgPath,
+	"csv":        tplPkgPath + "/encoding/csv",
+	"json":       tplPkgPath + "/encoding/json",
+	"regexp":     tplPkgPath + "/encoding/regexp",
+	"regexposix": tplPkgPath + "/encoding/regexposix",
+	"xml":        tplPkgPath + "/encoding/xml",
+}
+
+// DomainPath returns the package path of the text domain name, which is
+// looked up in domains (see Config.Domains) and then in the builtin domains.
+// It doesn't check the imported packages, which take precedence over both.
+func DomainPath(domains map[string]string, name string) (path string, ok bool) {
+	if path, ok = domains[name]; !ok {
+		path, ok = builtinDomains[name]
+	}
+	return
+}
+
 // https://github.com/goplus/gop/issues/2143
-// domainTag`...` => domainTag.new(`...`)
+// domainTag`...` => domainTag.New(`...`)
+// domainTag`...` => domainTag.NewEx(`...`, file, line, col, ...)
 func compileDomainTextLit(ctx *blockCtx, v *ast.DomainTextLit) {
 	var cb = ctx.cb
 	var imp gogen.PkgRef
 	var name = v.Domain.Name
-	var path string
 	if pi, ok := ctx.findImport(name); ok {
 		imp = pi.PkgRef
-		path = pi.Path()
-		if path == "golang.org/x/net/html" {
+		if imp.Path() == "golang.org/x/net/html" {
 			// html`...` => html.Parse(strings.NewReader(`...`))
 			cb.Val(imp.Ref("Parse")).
 				Val(ctx.pkg.Import("strings").Ref("NewReader")).
@@ -1102,24 +1443,19 @@ func compileDomainTextLit(ctx *blockCtx, v *ast.DomainTextLit) {
 			return
 		}
 	} else {
-		if name == "tpl" {
-			path = tplPkgPath
-		} else {
-			path = tplPkgPath + "/encoding/" + name
-		}
-		imp = ctx.pkg.Import(path)
-		/* TODO(xsw):
-		if imp = ctx.pkg.TryImport(path); imp.Types == nil {
-			panic("compileDomainTextLit TODO: unknown domain: " + name)
+		path, ok := DomainPath(ctx.domains, name)
+		if !ok {
+			panic(ctx.newCodeErrorf(v.Pos(), "unknown domain: %s", name))
 		}
-		*/
+		imp = ctx.pkg.Import(path, v.Domain)
 	}
+	checkDomainText(ctx, v, imp.Path())
 
 	n := 1
-	if path == tplPkgPath {
+	if fn := imp.TryRef("NewEx"); fn != nil {
 		pos := ctx.fset.Position(v.ValuePos)
 		filename := relFile(ctx.relBaseDir, pos.Filename)
-		cb.Val(imp.Ref("NewEx")).
+		cb.Val(fn, v.Domain).
 			Val(&goast.BasicLit{Kind: gotoken.STRING, Value: v.Value}, v).
 			Val(filename).Val(pos.Line).Val(pos.Column)
 		n += 3
@@ -1136,9 +1472,11 @@ func compileDomainTextLit(ctx *blockCtx, v *ast.DomainTextLit) {
 				}
 			}
 		}
-	} else {
-		cb.Val(imp.Ref("New")).
+	} else if fn := imp.TryRef("New"); fn != nil {
+		cb.Val(fn, v.Domain).
 			Val(&goast.BasicLit{Kind: gotoken.STRING, Value: v.Value}, v)
+	} else {
+		panic(ctx.newCodeErrorf(v.Pos(), "%s is not a text domain: package %s has no New or NewEx function", name, imp.Path()))
 	}
 	cb.CallWith(n, 0, v)
 }
@@ -1219,6 +1557,8 @@ func compileCompositeLitElt(ctx *blockCtx, e ast.Expr, typ types.Type, flag clLa
 		compileLambda(ctx, v, sig)
 	case *ast.SliceLit:
 		compileSliceLit(ctx, v, typ)
+	case *ast.MatrixLit:
+		compileMatrixLit(ctx, v, typ)
 	case *ast.CompositeLit:
 		compileCompositeLit(ctx, v, typ, false)
 	default:
@@ -1573,10 +1913,9 @@ var (
 
 func compileErrWrapExpr(ctx *blockCtx, v *ast.ErrWrapExpr, inFlags int) {
 	pkg, cb := ctx.pkg, ctx.cb
-	useClosure := v.Tok == token.NOT || v.Default != nil
-	if !useClosure && (cb.Scope().Parent() == types.Universe) {
-		panic("TODO: can't use expr? in global")
-	}
+	global := cb.Scope().Parent() == types.Universe
+	fatal := v.Tok != token.NOT && v.Default == nil && (global || isMainOrInit(ctx))
+	useClosure := v.Tok == token.NOT || v.Default != nil || global
 	expr := v.X
 	switch expr.(type) {
 	case *ast.Ident, *ast.SelectorExpr:
@@ -1627,7 +1966,9 @@ func compileErrWrapExpr(ctx *blockCtx, v *ast.ErrWrapExpr, inFlags int) {
 		const newFrameArgs = 5
 
 		currentFuncName := currentFunc.Name()
-		if currentFuncName == "" {
+		if fatal && global {
+			currentFuncName = "init"
+		} else if currentFuncName == "" {
 			currentFuncName = "main"
 		}
 
@@ -1647,6 +1988,8 @@ func compileErrWrapExpr(ctx *blockCtx, v *ast.ErrWrapExpr, inFlags int) {
 
 	if v.Tok == token.NOT { // expr!
 		cb.Val(pkg.Builtin().Ref("panic")).Val(err).Call(1).EndStmt()
+	} else if fatal { // expr? where the error can't be returned
+		cb.Val(pkg.Import("log").Ref("Fatalln")).Val(err).Call(1).EndStmt()
 	} else if v.Default == nil { // expr?
 		cb.Val(err).ReturnErr(true)
 	} else { // expr?:val
@@ -1659,6 +2002,25 @@ func compileErrWrapExpr(ctx *blockCtx, v *ast.ErrWrapExpr, inFlags int) {
 	}
 }
 
+// isMainOrInit reports whether the current function is the main function of
+// a main package (where the statements of a script are), or an init function.
+func isMainOrInit(ctx *blockCtx) bool {
+	fn := ctx.cb.Func()
+	if fn == nil || fn.Ancestor() != fn {
+		return false
+	}
+	if sig := fn.Type().(*types.Signature); sig.Recv() != nil {
+		return false
+	}
+	switch fn.Name() {
+	case "init":
+		return true
+	case "main":
+		return fn.Pkg().Name() == "main"
+	}
+	return false
+}
+
 func sprintAst(fset *token.FileSet, x ast.Node) string {
 	var buf bytes.Buffer
 	err := printer.Fprint(&buf, fset, x)
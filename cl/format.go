@@ -0,0 +1,325 @@
+/*
+ * Copyright (c) 2025 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cl
+
+import (
+	goast "go/ast"
+	gotoken "go/token"
+	"go/types"
+	"strconv"
+	"strings"
+
+	"github.com/goplus/gop/ast"
+)
+
+const (
+	strfmtPkgPath = "github.com/goplus/gop/builtin/strfmt"
+)
+
+// formatSpec is the format spec of a formatted part `${x:format}` of a string
+// literal, eg. %.2f or 08x.
+type formatSpec struct {
+	flags string // of "-+# 0"
+	width int    // 0 means no width
+	prec  int    // -1 means no precision
+	verb  byte
+}
+
+// parseFormat parses a format spec checked by the parser.
+func parseFormat(format string) (spec formatSpec) {
+	format = strings.TrimPrefix(format, "%")
+	i := 0
+	for i < len(format) && strings.IndexByte("-+# 0", format[i]) >= 0 {
+		i++
+	}
+	spec.flags, format = format[:i], format[i:]
+	spec.width, format = parseDigits(format)
+	spec.prec = -1
+	if strings.HasPrefix(format, ".") {
+		spec.prec, format = parseDigits(format[1:])
+	}
+	spec.verb = format[0]
+	return
+}
+
+func parseDigits(s string) (n int, left string) {
+	i := 0
+	for i < len(s) && '0' <= s[i] && s[i] <= '9' {
+		n = n*10 + int(s[i]-'0')
+		i++
+	}
+	return n, s[i:]
+}
+
+func (p *formatSpec) String() string {
+	var b strings.Builder
+	b.WriteByte('%')
+	b.WriteString(p.flags)
+	if p.width > 0 {
+		b.WriteString(strconv.Itoa(p.width))
+	}
+	if p.prec >= 0 {
+		b.WriteByte('.')
+		b.WriteString(strconv.Itoa(p.prec))
+	}
+	b.WriteByte(p.verb)
+	return b.String()
+}
+
+const (
+	formatInt = iota
+	formatUint
+	formatFloat
+	formatComplex
+	formatString
+	formatBytes
+	formatBool
+	formatPointer
+	formatInterface
+	formatOther
+)
+
+// formatVerbs are the verbs of fmt allowed for each kind of operands.
+var formatVerbs = [...]string{
+	formatInt:       "bcdoOqxXUv",
+	formatUint:      "bcdoOqxXUv",
+	formatFloat:     "beEfFgGxXv",
+	formatComplex:   "beEfFgGxXv",
+	formatString:    "sqxXv",
+	formatBytes:     "sqxXv",
+	formatBool:      "tv",
+	formatPointer:   "pv",
+	formatOther:     "v",
+	formatInterface: "", // any verb
+}
+
+// formatKindOf returns the kind of operands of type t, by its underlying type.
+func formatKindOf(t types.Type) int {
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		info := u.Info()
+		switch {
+		case info&types.IsUnsigned != 0:
+			return formatUint
+		case info&types.IsInteger != 0:
+			return formatInt
+		case info&types.IsFloat != 0:
+			return formatFloat
+		case info&types.IsComplex != 0:
+			return formatComplex
+		case info&types.IsString != 0:
+			return formatString
+		case info&types.IsBoolean != 0:
+			return formatBool
+		case u.Kind() == types.UnsafePointer:
+			return formatPointer
+		}
+	case *types.Slice:
+		if e, ok := u.Elem().(*types.Basic); ok && e.Kind() == types.Byte {
+			return formatBytes
+		}
+	case *types.Pointer, *types.Map, *types.Chan, *types.Signature:
+		return formatPointer
+	case *types.Interface:
+		return formatInterface
+	}
+	return formatOther
+}
+
+// formatMethods reports whether fmt formats an operand of type t by its
+// Format method (for all verbs), or by its Error or String method (for the
+// verbs v, s, x, X and q), see handleMethods of fmt.
+func formatMethods(t types.Type) (formatter, stringer bool) {
+	method := func(name string) *types.Signature {
+		if o, _, _ := types.LookupFieldOrMethod(t, false, nil, name); o != nil {
+			if fn, ok := o.(*types.Func); ok {
+				return fn.Type().(*types.Signature)
+			}
+		}
+		return nil
+	}
+	if sig := method("Format"); sig != nil && sig.Params().Len() == 2 && sig.Results().Len() == 0 {
+		return true, false
+	}
+	for _, name := range []string{"Error", "String"} {
+		if sig := method(name); sig != nil && sig.Params().Len() == 0 && sig.Results().Len() == 1 &&
+			types.Identical(sig.Results().At(0).Type(), types.Typ[types.String]) {
+			return false, true
+		}
+	}
+	return
+}
+
+// compileFormatExpr compiles a formatted part `${x:format}` of a string
+// literal to a string. Common formats of basic types are compiled to strconv
+// calls (and padded by the strfmt package), others to a fmt.Sprintf call.
+func compileFormatExpr(ctx *blockCtx, v *ast.FormatExpr) {
+	pkg, cb := ctx.pkg, ctx.cb
+	flags := 0
+	if _, ok := v.X.(*ast.Ident); ok {
+		flags = clIdentInStringLitEx
+	}
+	compileExpr(ctx, v.X, flags)
+	stk := cb.InternalStack()
+	x := stk.Get(-1)
+	if isPoisoned(x.Type) {
+		return
+	}
+	t := types.Default(x.Type)
+	spec := parseFormat(v.Format)
+	kind := formatKindOf(t)
+	formatter, stringer := formatMethods(t)
+	byMethod := formatter || stringer && strings.IndexByte("vsxXq", spec.verb) >= 0
+	if verbs := formatVerbs[kind]; !byMethod && verbs != "" && strings.IndexByte(verbs, spec.verb) < 0 {
+		panic(ctx.newCodeErrorf(
+			v.Colon+1, "format %s has arg %s of wrong type %v", spec.String(), ctx.LoadExpr(v.X), t))
+	}
+	stk.Pop()
+
+	pushArg := func(typ types.Type) { // typ(x)
+		if typ == nil || types.Identical(t, typ) {
+			stk.Push(x)
+		} else {
+			cb.Typ(typ)
+			stk.Push(x)
+			cb.Call(1)
+		}
+	}
+	strconvRef := func(name string) {
+		cb.Val(pkg.Import("strconv").Ref(name))
+	}
+	fast := strings.Trim(spec.flags, "-0") == ""
+	upper, numeric := false, false
+	var core func()
+	switch kind {
+	case formatInt, formatUint:
+		if spec.prec >= 0 {
+			break
+		}
+		switch verb := spec.verb; verb {
+		case 'd', 'v', 'b', 'o', 'x', 'X':
+			base := 10
+			switch verb {
+			case 'b':
+				base = 2
+			case 'o':
+				base = 8
+			case 'x', 'X':
+				base = 16
+			}
+			upper, numeric = verb == 'X', true
+			core = func() {
+				if kind == formatInt {
+					strconvRef("FormatInt")
+					pushArg(types.Typ[types.Int64])
+				} else {
+					strconvRef("FormatUint")
+					pushArg(types.Typ[types.Uint64])
+				}
+				cb.Val(base).Call(2)
+			}
+		case 'c':
+			core = func() {
+				cb.Typ(types.Typ[types.String])
+				pushArg(types.Typ[types.Rune])
+				cb.Call(1)
+			}
+		case 'q':
+			core = func() {
+				strconvRef("QuoteRune")
+				pushArg(types.Typ[types.Rune])
+				cb.Call(1)
+			}
+		}
+	case formatFloat:
+		switch verb := spec.verb; verb {
+		case 'e', 'E', 'f', 'F', 'g', 'G':
+			if verb == 'F' {
+				verb = 'f'
+			}
+			prec := spec.prec
+			if prec < 0 && verb != 'g' && verb != 'G' { // same as fmt
+				prec = 6
+			}
+			bitSize := 64
+			if b, ok := t.Underlying().(*types.Basic); ok && b.Kind() == types.Float32 {
+				bitSize = 32
+			}
+			core = func() {
+				strconvRef("FormatFloat")
+				pushArg(types.Typ[types.Float64])
+				cb.Val(&goast.BasicLit{Kind: gotoken.CHAR, Value: strconv.QuoteRune(rune(verb))})
+				cb.Val(prec).Val(bitSize).Call(4)
+			}
+			numeric = true
+		}
+	case formatString, formatBytes:
+		if spec.prec >= 0 {
+			break
+		}
+		switch spec.verb {
+		case 's', 'v':
+			core = func() {
+				pushArg(types.Typ[types.String])
+			}
+		case 'q':
+			core = func() {
+				strconvRef("Quote")
+				pushArg(types.Typ[types.String])
+				cb.Call(1)
+			}
+		}
+	case formatBool:
+		core = func() {
+			strconvRef("FormatBool")
+			pushArg(types.Typ[types.Bool])
+			cb.Call(1)
+		}
+	}
+	if strings.IndexByte(spec.flags, '0') >= 0 && strings.IndexByte(spec.flags, '-') < 0 && !numeric {
+		fast = false // leave zero padding of strings to fmt
+	}
+	if !fast || core == nil || byMethod { // fmt.Sprintf(format, x)
+		cb.Val(pkg.Import("fmt").Ref("Sprintf")).Val(spec.String())
+		stk.Push(x)
+		cb.CallWith(2, 0, v)
+		return
+	}
+
+	pad := ""
+	if spec.width > 0 {
+		switch {
+		case strings.IndexByte(spec.flags, '-') >= 0:
+			pad = "PadRight"
+		case strings.IndexByte(spec.flags, '0') >= 0:
+			pad = "ZeroPad"
+		default:
+			pad = "Pad"
+		}
+		cb.Val(pkg.Import(strfmtPkgPath).Ref(pad))
+	}
+	if upper {
+		cb.Val(pkg.Import("strings").Ref("ToUpper"))
+	}
+	core()
+	if upper {
+		cb.Call(1)
+	}
+	if pad != "" {
+		cb.Val(spec.width).CallWith(2, 0, v)
+	}
+}
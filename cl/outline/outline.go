@@ -49,7 +49,9 @@ type Package struct {
 	docs gogen.ObjectDocs
 }
 
-// NewPackage creates a Go/Go+ outline package.
+// NewPackage creates a Go/Go+ outline package. If there are compile errors,
+// the returned package is still valid and holds the declarations which are
+// loaded successfully.
 func NewPackage(pkgPath string, pkg *ast.Package, conf *Config) (_ Package, err error) {
 	ret, err := cl.NewPackage(pkgPath, pkg, &cl.Config{
 		Fset:           conf.Fset,
@@ -60,10 +62,10 @@ func NewPackage(pkgPath string, pkg *ast.Package, conf *Config) (_ Package, err
 		NoSkipConstant: true,
 		Outline:        true,
 	})
-	if err != nil {
+	if ret == nil {
 		return
 	}
-	return Package{ret.Types, ret.Docs}, nil
+	return Package{ret.Types, ret.Docs}, err
 }
 
 func (p Package) Pkg() *types.Package {
@@ -274,6 +274,7 @@ func (rec *goxRecorder) recordExpr(ctx *blockCtx, expr ast.Expr, _ bool) {
 	case *ast.FuncLit:
 	case *ast.CompositeLit:
 	case *ast.SliceLit:
+	case *ast.MatrixLit:
 	case *ast.RangeExpr:
 	case *ast.IndexExpr:
 		rec.indexExpr(ctx, v)
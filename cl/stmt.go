@@ -107,6 +107,35 @@ func commentFunc(ctx *blockCtx, fn *gogen.Func, decl *ast.FuncDecl) {
 	}
 }
 
+// poisonDefs defines the variables of stmt which failed to compile as poisoned
+// values, so that the errors derived from them are not reported.
+func poisonDefs(ctx *blockCtx, stmt ast.Stmt) {
+	var names []*ast.Ident
+	switch v := stmt.(type) {
+	case *ast.AssignStmt:
+		if v.Tok != token.DEFINE {
+			return
+		}
+		for _, lhs := range v.Lhs {
+			if name, ok := lhs.(*ast.Ident); ok {
+				names = append(names, name)
+			}
+		}
+	case *ast.DeclStmt:
+		if d, ok := v.Decl.(*ast.GenDecl); ok && d.Tok == token.VAR {
+			for _, spec := range d.Specs {
+				names = append(names, spec.(*ast.ValueSpec).Names...)
+			}
+		}
+	}
+	scope := ctx.cb.Scope()
+	for _, name := range names {
+		if name.Name != "_" && scope.Lookup(name.Name) == nil {
+			scope.Insert(types.NewVar(name.Pos(), ctx.pkg.Types, name.Name, types.Typ[types.Invalid]))
+		}
+	}
+}
+
 func compileStmts(ctx *blockCtx, body []ast.Stmt) {
 	for _, stmt := range body {
 		if v, ok := stmt.(*ast.LabeledStmt); ok {
@@ -114,17 +143,24 @@ func compileStmts(ctx *blockCtx, body []ast.Stmt) {
 			ctx.cb.NewLabel(expr.Pos(), expr.Name)
 		}
 	}
-	for _, stmt := range body {
+	for i, stmt := range body {
 		compileStmt(ctx, stmt)
+		if i+1 < len(body) {
+			warnUnreachable(ctx, stmt, body[i+1])
+		}
 	}
 }
 
 func compileStmt(ctx *blockCtx, stmt ast.Stmt) {
 	if enableRecover {
+		npoison := ctx.npoison
 		defer func() {
 			if e := recover(); e != nil {
-				ctx.handleRecover(e, stmt)
+				if ctx.npoison == npoison || e == errTooManyErrors {
+					ctx.handleRecover(e, stmt)
+				}
 				ctx.cb.ResetStmt()
+				poisonDefs(ctx, stmt)
 			}
 		}()
 	}
@@ -134,6 +170,9 @@ func compileStmt(ctx *blockCtx, stmt ast.Stmt) {
 		x := v.X
 		inFlags := checkCommandWithoutArgs(x)
 		compileExpr(ctx, x, inFlags)
+		if v, ok := x.(*ast.ErrWrapExpr); ok {
+			warnIgnoredResult(ctx, v)
+		}
 	case *ast.AssignStmt:
 		compileAssignStmt(ctx, v)
 	case *ast.ReturnStmt:
@@ -230,6 +269,12 @@ func compileReturnStmt(ctx *blockCtx, expr *ast.ReturnStmt) {
 			case *ast.SliceLit:
 				rtyp := ctx.cb.Func().Type().(*types.Signature).Results().At(i).Type()
 				compileSliceLit(ctx, v, rtyp)
+			case *ast.MatrixLit:
+				rtyp := ctx.cb.Func().Type().(*types.Signature).Results().At(i).Type()
+				compileMatrixLit(ctx, v, rtyp)
+			case *ast.EnvExpr:
+				rtyp := ctx.cb.Func().Type().(*types.Signature).Results().At(i).Type()
+				compileEnvExpr(ctx, v, rtyp)
 			default:
 				compileExpr(ctx, ret, inFlags)
 			}
@@ -309,9 +354,10 @@ func compileAssignStmt(ctx *blockCtx, expr *ast.AssignStmt) {
 				log.Panicln("TODO: non-name $v on left side of :=")
 			}
 		}
-		if rec := ctx.recorder(); rec != nil {
-			newNames := make([]*ast.Ident, 0, len(names))
-			scope := ctx.cb.Scope()
+		var newNames []*ast.Ident
+		scope := ctx.cb.Scope()
+		if ctx.recorder() != nil || ctx.onWarning != nil {
+			newNames = make([]*ast.Ident, 0, len(names))
 			for _, lhs := range expr.Lhs {
 				v := lhs.(*ast.Ident)
 				if scope.Lookup(v.Name) == nil {
@@ -333,6 +379,7 @@ func compileAssignStmt(ctx *blockCtx, expr *ast.AssignStmt) {
 			compileExpr(ctx, rhs, inFlags)
 		}
 		ctx.cb.EndInit(len(expr.Rhs))
+		ctx.defLocals(newNames, scope)
 		return
 	}
 	for _, lhs := range expr.Lhs {
@@ -357,12 +404,24 @@ func compileAssignStmt(ctx *blockCtx, expr *ast.AssignStmt) {
 				typ, _ = gogen.DerefType(ctx.cb.Get(-1 - i).Type)
 			}
 			compileSliceLit(ctx, e, typ)
+		case *ast.MatrixLit:
+			var typ types.Type
+			if len(expr.Lhs) == len(expr.Rhs) {
+				typ, _ = gogen.DerefType(ctx.cb.Get(-1 - i).Type)
+			}
+			compileMatrixLit(ctx, e, typ)
 		case *ast.CompositeLit:
 			var typ types.Type
 			if len(expr.Lhs) == len(expr.Rhs) {
 				typ, _ = gogen.DerefType(ctx.cb.Get(-1 - i).Type)
 			}
 			compileCompositeLit(ctx, e, typ, false)
+		case *ast.EnvExpr:
+			var typ types.Type
+			if len(expr.Lhs) == len(expr.Rhs) {
+				typ, _ = gogen.DerefType(ctx.cb.Get(-1 - i).Type)
+			}
+			compileEnvExpr(ctx, e, typ)
 		default:
 			compileExpr(ctx, rhs, inFlags)
 		}
@@ -440,6 +499,7 @@ func compileRangeStmt(ctx *blockCtx, v *ast.RangeStmt) {
 	cb.RangeAssignThen(pos)
 	if len(defineNames) > 0 {
 		defNames(ctx, defineNames, cb.Scope())
+		ctx.defLocals(defineNames, cb.Scope())
 	}
 	if rec := ctx.recorder(); rec != nil {
 		rec.Scope(v, cb.Scope())
@@ -479,6 +539,7 @@ func compileForPhraseStmt(ctx *blockCtx, v *ast.ForPhraseStmt) {
 	cb.RangeAssignThen(v.TokPos)
 	if len(defineNames) > 0 {
 		defNames(ctx, defineNames, cb.Scope())
+		ctx.defLocals(defineNames, cb.Scope())
 	}
 	if rec := ctx.recorder(); rec != nil {
 		rec.Scope(v, cb.Scope())
@@ -678,13 +739,15 @@ func compileTypeSwitchStmt(ctx *blockCtx, v *ast.TypeSwitchStmt) {
 	var cb = ctx.cb
 	comments, once := cb.BackupComments()
 	var name string
+	var ident *ast.Ident
 	var ta *ast.TypeAssertExpr
 	switch stmt := v.Assign.(type) {
 	case *ast.AssignStmt:
 		if stmt.Tok != token.DEFINE || len(stmt.Lhs) != 1 || len(stmt.Rhs) != 1 {
 			panic("TODO: type switch syntax error")
 		}
-		name = stmt.Lhs[0].(*ast.Ident).Name
+		ident = stmt.Lhs[0].(*ast.Ident)
+		name = ident.Name
 		ta = stmt.Rhs[0].(*ast.TypeAssertExpr)
 	case *ast.ExprStmt:
 		ta = stmt.X.(*ast.TypeAssertExpr)
@@ -703,6 +766,7 @@ func compileTypeSwitchStmt(ctx *blockCtx, v *ast.TypeSwitchStmt) {
 	cb.TypeAssertThen()
 	seen := make(map[types.Type]ast.Expr)
 	var firstDefault ast.Stmt
+	var caseVars []*types.Var // name declared in each clause
 	for _, stmt := range v.Body.List {
 		c, ok := stmt.(*ast.CaseClause)
 		if !ok {
@@ -744,6 +808,9 @@ func compileTypeSwitchStmt(ctx *blockCtx, v *ast.TypeSwitchStmt) {
 			}
 		}
 		cb.Then()
+		if v, ok := cb.Scope().Lookup(name).(*types.Var); ok {
+			caseVars = append(caseVars, v)
+		}
 		compileStmts(ctx, c.Body)
 		commentStmt(ctx, stmt)
 		if rec := ctx.recorder(); rec != nil {
@@ -751,6 +818,9 @@ func compileTypeSwitchStmt(ctx *blockCtx, v *ast.TypeSwitchStmt) {
 		}
 		cb.End(c)
 	}
+	if ident != nil {
+		ctx.defCaseVars(ident, caseVars)
+	}
 	cb.SetComments(comments, once)
 	cb.End(v)
 }
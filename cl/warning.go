@@ -0,0 +1,230 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cl
+
+import (
+	"fmt"
+	"go/types"
+	"sort"
+	"strconv"
+
+	"github.com/goplus/gop/ast"
+	"github.com/goplus/gop/token"
+)
+
+// Warning codes reported to Config.OnWarning.
+const (
+	// WarnUnusedVar: a local variable is declared and not used.
+	WarnUnusedVar = "unused-var"
+
+	// WarnUnusedImport: a package imported by a Go+ file is not used.
+	WarnUnusedImport = "unused-import"
+
+	// WarnShadowedParam: a lambda parameter shadows a local variable.
+	WarnShadowedParam = "shadowed-param"
+
+	// WarnIgnoredResult: the results of `expr!` are ignored.
+	WarnIgnoredResult = "ignored-result"
+
+	// WarnUnreachable: a statement follows a call to panic.
+	WarnUnreachable = "unreachable"
+)
+
+type warnKey struct {
+	pos  token.Pos
+	code string
+}
+
+type warnCtx struct {
+	onWarning func(pos token.Pos, code, msg string)
+	warned    map[warnKey]bool
+	used      map[types.Object]bool
+	locals    []localVar       // local variables, see defLocals
+	imports   []*types.PkgName // imports of Go+ files, see loadImport
+}
+
+// localVar is a local variable declared by name. The variable x of
+// `switch x := y.(type)` is declared in each clause of the type switch, so it
+// has several vars.
+type localVar struct {
+	name *ast.Ident
+	vars []*types.Var
+}
+
+func (p *warnCtx) init(onWarning func(pos token.Pos, code, msg string)) {
+	if onWarning != nil {
+		p.onWarning = onWarning
+		p.warned = make(map[warnKey]bool)
+		p.used = make(map[types.Object]bool)
+	}
+}
+
+// warnf reports a warning. A warning is reported once even if it occurs
+// several times (eg. when trying overloads).
+func (p *warnCtx) warnf(pos token.Pos, code, format string, args ...any) {
+	if p.onWarning == nil {
+		return
+	}
+	key := warnKey{pos, code}
+	if p.warned[key] {
+		return
+	}
+	p.warned[key] = true
+	p.onWarning(pos, code, fmt.Sprintf(format, args...))
+}
+
+func (p *warnCtx) use(o types.Object) {
+	if p.used != nil {
+		p.used[o] = true
+	}
+}
+
+// defLocals records the local variables names defined in scope.
+func (p *warnCtx) defLocals(names []*ast.Ident, scope *types.Scope) {
+	if p.onWarning == nil {
+		return
+	}
+	for _, name := range names {
+		if name.Name == "_" {
+			continue
+		}
+		if v, ok := scope.Lookup(name.Name).(*types.Var); ok {
+			p.locals = append(p.locals, localVar{name, []*types.Var{v}})
+		}
+	}
+}
+
+// defCaseVars records the variable name of a type switch, which is declared
+// as vars in its clauses. It is unused if it is unused in all clauses.
+func (p *warnCtx) defCaseVars(name *ast.Ident, vars []*types.Var) {
+	if p.onWarning != nil && name.Name != "_" {
+		p.locals = append(p.locals, localVar{name, vars})
+	}
+}
+
+func (p *warnCtx) defImport(pkgName *types.PkgName) {
+	if p.onWarning != nil {
+		p.imports = append(p.imports, pkgName)
+	}
+}
+
+// warnUnused reports the unused local variables and imports, in the order
+// of their positions.
+func (p *warnCtx) warnUnused() {
+	if p.onWarning == nil {
+		return
+	}
+	type unused struct {
+		pos  token.Pos
+		code string
+		msg  string
+	}
+	var list []unused
+	for _, v := range p.locals {
+		if !p.usedLocal(v) {
+			list = append(list, unused{v.name.Pos(), WarnUnusedVar, "declared and not used: " + v.name.Name})
+		}
+	}
+	for _, o := range p.imports {
+		if p.used[o] {
+			continue
+		}
+		path := o.Imported().Path()
+		msg := strconv.Quote(path) + " imported and not used"
+		if o.Name() != o.Imported().Name() {
+			msg = strconv.Quote(path) + " imported as " + o.Name() + " and not used"
+		}
+		list = append(list, unused{o.Pos(), WarnUnusedImport, msg})
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].pos < list[j].pos
+	})
+	for _, u := range list {
+		p.warnf(u.pos, u.code, "%s", u.msg)
+	}
+}
+
+func (p *warnCtx) usedLocal(v localVar) bool {
+	for _, o := range v.vars {
+		if p.used[o] || isPoisoned(o.Type()) {
+			return true
+		}
+	}
+	return false
+}
+
+// -----------------------------------------------------------------------------
+
+// warnShadowedParams reports the lambda parameters which shadow local variables.
+func warnShadowedParams(ctx *blockCtx, lhs []*ast.Ident) {
+	if ctx.onWarning == nil {
+		return
+	}
+	scope := ctx.pkg.Types.Scope()
+	for _, name := range lhs {
+		if name.Name == "_" {
+			continue
+		}
+		at, o := ctx.cb.Scope().LookupParent(name.Name, token.NoPos)
+		if o != nil && at != scope && at != types.Universe {
+			ctx.warnf(name.Pos(), WarnShadowedParam, "lambda parameter %s shadows a local variable", name.Name)
+		}
+	}
+}
+
+// warnIgnoredResult reports the results of `expr!` ignored by an expression
+// statement.
+func warnIgnoredResult(ctx *blockCtx, v *ast.ErrWrapExpr) {
+	if ctx.onWarning == nil || v.Tok != token.NOT || v.Default != nil {
+		return
+	}
+	t := ctx.cb.Get(-1).Type
+	if t == nil || isPoisoned(t) {
+		return
+	}
+	if tuple, ok := t.(*types.Tuple); ok && tuple.Len() == 0 {
+		return
+	}
+	ctx.warnf(v.Pos(), WarnIgnoredResult, "result of %v is not used", ctx.LoadExpr(v))
+}
+
+// warnUnreachable reports the statement following stmt if stmt calls panic.
+func warnUnreachable(ctx *blockCtx, stmt ast.Stmt, next ast.Stmt) {
+	if ctx.onWarning == nil {
+		return
+	}
+	if _, ok := next.(*ast.LabeledStmt); ok { // may be reached by goto
+		return
+	}
+	v, ok := stmt.(*ast.ExprStmt)
+	if !ok {
+		return
+	}
+	call, ok := v.X.(*ast.CallExpr)
+	if !ok {
+		return
+	}
+	if fn, ok := call.Fun.(*ast.Ident); !ok || fn.Name != "panic" {
+		return
+	}
+	if at, o := ctx.cb.Scope().LookupParent("panic", token.NoPos); o != nil && at != types.Universe {
+		return
+	}
+	ctx.warnf(next.Pos(), WarnUnreachable, "unreachable code")
+}
+
+// -----------------------------------------------------------------------------
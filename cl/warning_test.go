@@ -0,0 +1,122 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cl_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/goplus/gop/cl"
+	"github.com/goplus/gop/cl/cltest"
+	"github.com/goplus/gop/parser"
+	"github.com/goplus/gop/parser/fsx/memfs"
+	"github.com/goplus/gop/token"
+)
+
+func warningTest(t *testing.T, expected, src string) {
+	t.Helper()
+	fs := memfs.SingleFile("/foo", "bar.gop", src)
+	pkgs, err := parser.ParseFSDir(cltest.Conf.Fset, fs, "/foo", parser.Config{})
+	if err != nil {
+		t.Fatal("parser.ParseFSDir:", err)
+	}
+	var ret []string
+	conf := *cltest.Conf
+	conf.RelativeBase = "/foo"
+	conf.OnWarning = func(pos token.Pos, code, msg string) {
+		ret = append(ret, fmt.Sprintf("%v: %s [%s]", conf.Fset.Position(pos), msg, code))
+	}
+	if _, err = cl.NewPackage("", pkgs["main"], &conf); err != nil {
+		t.Fatal("cl.NewPackage:", err)
+	}
+	if got := strings.Join(ret, "\n"); got != expected {
+		t.Fatalf("\nWarnings: \"%s\"\nExpected: \"%s\"\n", got, expected)
+	}
+}
+
+func TestWarnings(t *testing.T) {
+	warningTest(t, `/foo/bar.gop:21:2: result of f()! is not used [ignored-result]
+/foo/bar.gop:23:13: lambda parameter x shadows a local variable [shadowed-param]
+/foo/bar.gop:25:2: unreachable code [unreachable]
+/foo/bar.gop:3:2: "fmt" imported and not used [unused-import]
+/foo/bar.gop:5:2: "strings" imported as str and not used [unused-import]
+/foo/bar.gop:17:2: declared and not used: a [unused-var]
+/foo/bar.gop:19:6: declared and not used: c [unused-var]`, `
+import (
+	"fmt"
+	"os"
+	str "strings"
+)
+
+func f() (int, error) {
+	return 0, nil
+}
+
+func apply(fn func(int) int) int {
+	return fn(1)
+}
+
+func g() {
+	a := 1
+	b, _ := 2, 3
+	var c int
+	c = b
+	f()!
+	x := 1
+	echo apply(x => x+1), x, os.Args
+	panic "x"
+	echo "never"
+}
+`)
+	warningTest(t, `/foo/bar.gop:3:6: declared and not used: k [unused-var]
+/foo/bar.gop:6:9: declared and not used: v [unused-var]
+/foo/bar.gop:9:5: declared and not used: n [unused-var]
+/foo/bar.gop:11:9: declared and not used: x [unused-var]`, `
+func h(v any, m map[string]int) {
+	for k, v := range m {
+		echo v
+	}
+	for k, v <- m {
+		echo k
+	}
+	if n := len(m); v != nil {
+	}
+	switch x := v.(type) {
+	case int:
+	case string:
+	}
+	switch y := v.(type) {
+	case int:
+		echo y
+	case string:
+	}
+}
+`)
+	warningTest(t, ``, `
+func g() (err error) {
+	x, err := 1, nil
+	for i := 0; i < x; i++ {
+		panic("x")
+	}
+	goto end
+	panic("y")
+end:
+	return
+}
+`)
+}
@@ -37,10 +37,12 @@ import (
 	"github.com/goplus/gop/cmd/internal/help"
 	"github.com/goplus/gop/cmd/internal/install"
 	"github.com/goplus/gop/cmd/internal/mod"
+	"github.com/goplus/gop/cmd/internal/rename"
 	"github.com/goplus/gop/cmd/internal/run"
 	"github.com/goplus/gop/cmd/internal/serve"
 	"github.com/goplus/gop/cmd/internal/test"
 	"github.com/goplus/gop/cmd/internal/version"
+	"github.com/goplus/gop/cmd/internal/vet"
 	"github.com/goplus/gop/cmd/internal/watch"
 )
 
@@ -56,7 +58,9 @@ func init() {
 		install.Cmd,
 		build.Cmd,
 		test.Cmd,
+		vet.Cmd,
 		gopfmt.Cmd,
+		rename.Cmd,
 		gopget.Cmd,
 		gengo.Cmd,
 		mod.Cmd,
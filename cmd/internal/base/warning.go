@@ -0,0 +1,42 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package base
+
+import (
+	"fmt"
+	"go/token"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// WarningPrinter returns a callback printing the compiler warnings to w, in
+// the form `file:line:col: msg (code)`. n, if not nil, counts the warnings.
+func WarningPrinter(w io.Writer, fset *token.FileSet, n *int) func(pos token.Pos, code, msg string) {
+	cwd, _ := os.Getwd()
+	return func(pos token.Pos, code, msg string) {
+		position := fset.Position(pos)
+		if rel, err := filepath.Rel(cwd, position.Filename); err == nil && !strings.HasPrefix(rel, "..") {
+			position.Filename = rel
+		}
+		fmt.Fprintf(w, "%v: %s (%s)\n", position, msg, code)
+		if n != nil {
+			*n++
+		}
+	}
+}
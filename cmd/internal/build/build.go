@@ -34,7 +34,7 @@ import (
 
 // gop build
 var Cmd = &base.Command{
-	UsageLine: "gop build [-debug -o output] [packages]",
+	UsageLine: "gop build [-debug -W -maxerrors n -o output] [packages]",
 	Short:     "Build Go+ files",
 }
 
@@ -42,6 +42,8 @@ var (
 	flag       = &Cmd.Flag
 	flagDebug  = flag.Bool("debug", false, "print debug information")
 	flagOutput = flag.String("o", "", "gop build output file")
+	flagWarn   = flag.Bool("W", false, "print the warnings of compiling Go+ files")
+	flagErrors = flag.Int("maxerrors", 0, "maximum number of errors to report of a Go+ package (0 means no limit)")
 )
 
 func init() {
@@ -79,6 +81,10 @@ func runCmd(cmd *base.Command, args []string) {
 		log.Panicln("tool.NewDefaultConf:", err)
 	}
 	defer conf.UpdateCache()
+	conf.MaxErrors = *flagErrors
+	if *flagWarn {
+		conf.OnWarning = base.WarningPrinter(os.Stderr, conf.Fset, nil)
+	}
 
 	confCmd := conf.NewGoCmdConf()
 	if *flagOutput != "" {
@@ -33,7 +33,7 @@ import (
 
 // gop go
 var Cmd = &base.Command{
-	UsageLine: "gop go [-v] [packages|files]",
+	UsageLine: "gop go [-v] [-sourcemap] [packages|files]",
 	Short:     "Convert Go+ code into Go code",
 }
 
@@ -44,7 +44,8 @@ var (
 	flagSingleMode       = flag.Bool("s", false, "run in single file mode for package")
 	flagIgnoreNotatedErr = flag.Bool(
 		"ignore-notated-error", false, "ignore notated errors, only available together with -t (check mode)")
-	flagTags = flag.String("tags", "", "a comma-separated list of additional build tags to consider satisfied")
+	flagTags      = flag.String("tags", "", "a comma-separated list of additional build tags to consider satisfied")
+	flagSourceMap = flag.Bool("sourcemap", false, "also write a JSON source map next to each gop_autogen.go")
 )
 
 func init() {
@@ -88,6 +89,9 @@ func runCmd(cmd *base.Command, args []string) {
 	if *flagSingleMode {
 		flags |= tool.GenFlagSingleFile
 	}
+	if *flagSourceMap {
+		flags |= tool.GenFlagSourceMap
+	}
 	for _, proj := range projs {
 		switch v := proj.(type) {
 		case *gopprojs.DirProj:
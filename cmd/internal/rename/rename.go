@@ -0,0 +1,122 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package rename implements the “gop rename” command.
+package rename
+
+import (
+	"fmt"
+	goformat "go/format"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/goplus/gop/cmd/internal/base"
+	"github.com/goplus/gop/format"
+	"github.com/goplus/gop/x/langserver"
+	"github.com/qiniu/x/log"
+)
+
+// gop rename
+var Cmd = &base.Command{
+	UsageLine: "gop rename [-w] file:line:column newName",
+	Short:     "Rename a Go+ identifier and all references to it in the module",
+}
+
+var (
+	flag      = &Cmd.Flag
+	flagWrite = flag.Bool("w", false, "write result to source files instead of listing the changes")
+)
+
+func init() {
+	Cmd.Run = runCmd
+}
+
+func runCmd(cmd *base.Command, args []string) {
+	err := flag.Parse(args)
+	if err != nil {
+		log.Fatalln("parse input arguments failed:", err)
+	}
+	args = flag.Args()
+	if len(args) != 2 {
+		cmd.Usage(os.Stderr)
+		os.Exit(2)
+	}
+	file, line, column, err := parsePos(args[0])
+	if err != nil {
+		log.Fatalln(err)
+	}
+	ret, err := langserver.Rename(file, line, column, args[1])
+	if err != nil {
+		log.Fatalln(err)
+	}
+	uris := make([]string, 0, len(ret.Changes))
+	for uri := range ret.Changes {
+		uris = append(uris, string(uri))
+	}
+	sort.Strings(uris)
+	for _, uri := range uris {
+		edits := ret.Changes[langserver.DocumentURI(uri)]
+		file := langserver.FilenameOf(langserver.DocumentURI(uri))
+		if !*flagWrite {
+			for _, e := range edits {
+				start := e.Range.Start
+				fmt.Printf("%s:%d:%d: %s\n", file, start.Line+1, start.Character+1, e.NewText)
+			}
+			continue
+		}
+		if err = writeFile(file, edits); err != nil {
+			log.Fatalln(err)
+		}
+	}
+}
+
+// parsePos parses a position in the form of file:line:column.
+func parsePos(pos string) (file string, line, column int, err error) {
+	parts := strings.Split(pos, ":")
+	if n := len(parts); n >= 3 {
+		line, err = strconv.Atoi(parts[n-2])
+		if err == nil {
+			column, err = strconv.Atoi(parts[n-1])
+		}
+		if err == nil {
+			return strings.Join(parts[:n-2], ":"), line, column, nil
+		}
+	}
+	return "", 0, 0, fmt.Errorf("invalid position %q: expect file:line:column", pos)
+}
+
+func writeFile(file string, edits []langserver.TextEdit) (err error) {
+	text, err := os.ReadFile(file)
+	if err != nil {
+		return
+	}
+	text = langserver.ApplyEdits(text, edits)
+	switch ext := filepath.Ext(file); ext {
+	case ".go":
+		text, err = goformat.Source(text)
+	default:
+		text, err = format.Source(text, ext != ".gop", file)
+	}
+	if err != nil {
+		return
+	}
+	return os.WriteFile(file, text, 0644)
+}
+
+// -----------------------------------------------------------------------------
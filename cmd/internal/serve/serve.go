@@ -19,23 +19,32 @@ package serve
 
 import (
 	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/goplus/gop/cmd/internal/base"
 	"github.com/goplus/gop/x/jsonrpc2"
-	"github.com/goplus/gop/x/jsonrpc2/stdio"
 	"github.com/goplus/gop/x/langserver"
 	"github.com/qiniu/x/log"
 )
 
 // gop serve
 var Cmd = &base.Command{
-	UsageLine: "gop serve [flags]",
+	UsageLine: "gop serve [-v -listen addr -idle duration -framing name | -status | -stop]",
 	Short:     "Serve as a Go+ LangServer",
 }
 
 var (
 	flag        = &Cmd.Flag
 	flagVerbose = flag.Bool("v", false, "print verbose information")
+	flagListen  = flag.String("listen", "stdio", "address to listen on: stdio, unix:<path> or tcp:<host:port>")
+	flagIdle    = flag.Duration("idle", 0, "exit after there are no clients for the duration (0 means never)")
+	flagFraming = flag.String("framing", "header", "message framing: header, ndjson or length")
+	flagStatus  = flag.Bool("status", false, "print the status of running LangServers")
+	flagStop    = flag.Bool("stop", false, "stop running LangServers")
 )
 
 func init() {
@@ -48,15 +57,95 @@ func runCmd(cmd *base.Command, args []string) {
 		log.Fatalln("parse input arguments failed:", err)
 	}
 
+	framer, err := jsonrpc2.FramerByName(*flagFraming)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	switch {
+	case *flagStatus:
+		showStatus()
+		return
+	case *flagStop:
+		stopDaemons()
+		return
+	}
+
 	if *flagVerbose {
 		jsonrpc2.SetDebug(jsonrpc2.DbgFlagCall)
 	}
 
-	listener := stdio.Listener(false)
+	ctx := context.Background()
+	listener, err := langserver.Listen(ctx, *flagListen, *flagIdle)
+	if err != nil {
+		log.Fatalln("listen failed:", err)
+	}
 	defer listener.Close()
 
-	server := langserver.NewServer(context.Background(), listener, nil)
+	if addr := *flagListen; addr != "stdio" {
+		unregister, err := langserver.RegisterDaemon(addr, *flagFraming)
+		if err != nil {
+			log.Fatalln("register LangServer failed:", err)
+		}
+		defer unregister()
+		go func() {
+			sig := make(chan os.Signal, 1)
+			signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+			<-sig
+			listener.Close()
+		}()
+	}
+
+	server := langserver.NewServer(ctx, listener, &langserver.Config{Framer: framer})
 	server.Wait()
 }
 
+func daemons() []langserver.Daemon {
+	ret, err := langserver.Daemons()
+	if err != nil {
+		log.Fatalln("find LangServers failed:", err)
+	}
+	if len(ret) == 0 {
+		fmt.Fprintln(os.Stderr, "no LangServer is running")
+	}
+	return ret
+}
+
+func showStatus() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for _, d := range daemons() {
+		fmt.Printf("pid %d: %s (%s)\n", d.Pid, d.Addr, d.Framing)
+		framer, err := jsonrpc2.FramerByName(d.Framing) // the framing of the LangServer
+		if err != nil {
+			fmt.Println("  error:", err)
+			continue
+		}
+		c, err := langserver.DialFramer(ctx, d.Addr, framer, nil)
+		if err != nil {
+			fmt.Println("  error:", err)
+			continue
+		}
+		st, err := c.Status(ctx)
+		c.Close()
+		if err != nil {
+			fmt.Println("  error:", err)
+			continue
+		}
+		for _, t := range st.Running {
+			fmt.Printf("  running %s (%v)\n", t.Dir, time.Since(t.Start).Round(time.Millisecond))
+		}
+		for _, t := range st.Queued {
+			fmt.Printf("  queued  %s (%v)\n", t.Dir, time.Since(t.Since).Round(time.Millisecond))
+		}
+	}
+}
+
+func stopDaemons() {
+	for _, d := range daemons() {
+		d.Stop()
+		fmt.Printf("pid %d: %s stopped\n", d.Pid, d.Addr)
+	}
+}
+
 // -----------------------------------------------------------------------------
@@ -0,0 +1,94 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package vet implements the “gop vet” command.
+package vet
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+
+	"github.com/goplus/gop/cmd/internal/base"
+	"github.com/goplus/gop/tool"
+	"github.com/goplus/gop/x/gopprojs"
+)
+
+// gop vet
+var Cmd = &base.Command{
+	UsageLine: "gop vet [-tags taglist] [packages|files]",
+	Short:     "Report the compiler warnings and errors of Go+ files",
+}
+
+var (
+	flag     = &Cmd.Flag
+	flagTags = flag.String("tags", "", "a comma-separated list of additional build tags to consider satisfied")
+)
+
+func init() {
+	Cmd.Run = runCmd
+}
+
+func runCmd(cmd *base.Command, args []string) {
+	err := flag.Parse(args)
+	if err != nil {
+		log.Panicln("parse input arguments failed:", err)
+	}
+	pattern := flag.Args()
+	if len(pattern) == 0 {
+		pattern = []string{"."}
+	}
+
+	projs, err := gopprojs.ParseAll(pattern...)
+	if err != nil {
+		log.Panicln("gopprojs.ParseAll:", err)
+	}
+
+	conf, err := tool.NewDefaultConf(".", 0, *flagTags)
+	if err != nil {
+		log.Panicln("tool.NewDefaultConf:", err)
+	}
+	defer conf.UpdateCache()
+
+	var nwarn int
+	conf.OnWarning = base.WarningPrinter(os.Stderr, conf.Fset, &nwarn)
+
+	const flags = tool.GenFlagCheckOnly | tool.GenFlagPrintError
+	failed := false
+	for _, proj := range projs {
+		switch v := proj.(type) {
+		case *gopprojs.DirProj:
+			_, _, err = tool.GenGoEx(v.Dir, conf, true, flags)
+		case *gopprojs.PkgPathProj:
+			_, _, err = tool.GenGoPkgPathEx("", v.Path, conf, true, flags)
+		case *gopprojs.FilesProj:
+			if _, err = tool.LoadFiles(".", v.Files, conf); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			}
+		default:
+			log.Panicln("`gop vet` doesn't support", reflect.TypeOf(v))
+		}
+		if err != nil {
+			failed = true
+		}
+	}
+	if failed || nwarn > 0 {
+		os.Exit(1)
+	}
+}
+
+// -----------------------------------------------------------------------------
@@ -17,12 +17,14 @@
 package watch
 
 import (
+	"context"
 	"log"
 	"path/filepath"
 
 	"github.com/goplus/gop/cmd/internal/base"
 	"github.com/goplus/gop/tool"
 	"github.com/goplus/gop/x/fsnotify"
+	"github.com/goplus/gop/x/langserver"
 	"github.com/goplus/gop/x/watcher"
 )
 
@@ -30,7 +32,7 @@ import (
 
 // gop watch
 var Cmd = &base.Command{
-	UsageLine: "gop watch [-v -gentest] [dir]",
+	UsageLine: "gop watch [-v -gentest -connect addr] [dir]",
 	Short:     "Monitor code changes in a Go+ workspace to generate Go files",
 }
 
@@ -39,6 +41,7 @@ var (
 	verbose    = flag.Bool("v", false, "print verbose information.")
 	debug      = flag.Bool("debug", false, "show all debug information.")
 	genTestPkg = flag.Bool("gentest", false, "generate test package.")
+	connect    = flag.String("connect", "", "let the LangServer listening on addr (unix:<path> or tcp:<host:port>) generate Go files.")
 )
 
 func init() {
@@ -63,6 +66,22 @@ func runCmd(cmd *base.Command, args []string) {
 		args = []string{"."}
 	}
 
+	genGo := func(dir string) (err error) {
+		_, _, err = tool.GenGo(dir, nil, *genTestPkg)
+		return
+	}
+	if addr := *connect; addr != "" {
+		ctx := context.Background()
+		c, err := langserver.DialDaemon(ctx, addr, nil)
+		if err != nil {
+			log.Fatalln("connect to LangServer failed:", err)
+		}
+		defer c.Close()
+		genGo = func(dir string) error {
+			return c.GenGoEx(ctx, *genTestPkg, dir)
+		}
+	}
+
 	root, _ := filepath.Abs(args[0])
 	log.Println("Watch", root)
 	w := watcher.New(root)
@@ -70,8 +89,7 @@ func runCmd(cmd *base.Command, args []string) {
 	for {
 		dir := w.Fetch(true)
 		log.Println("GenGo", dir)
-		_, _, err := tool.GenGo(dir, nil, *genTestPkg)
-		if err != nil {
+		if err := genGo(dir); err != nil {
 			log.Println(err)
 		}
 	}
@@ -54,6 +54,10 @@ const (
 	ParseGoPlusClass Mode = 1 << 17
 	// SaveAbsFile - parse and save absolute path to pkg.Files
 	SaveAbsFile Mode = 1 << 18
+	// RecoverErrors - always return a full ast.File for IDE use: broken regions
+	// become ast.Bad* nodes and unbalanced brackets are closed at the end of
+	// statements. It never stops parsing because of too many errors.
+	RecoverErrors Mode = 1 << 19
 
 	goReservedFlags Mode = ((1 << 16) - 1)
 )
@@ -75,8 +75,9 @@ type parser struct {
 	// (used to limit the number of calls to parser.advance
 	// w/o making scanning progress - avoids potential endless
 	// loops across multiple parser functions during error recovery)
-	syncPos token.Pos // last synchronization position
-	syncCnt int       // number of parser.advance calls without progress
+	syncPos  token.Pos // last synchronization position
+	syncCnt  int       // number of parser.advance calls without progress
+	blockLev int       // nesting level of blocks, used by RecoverErrors mode
 
 	varDeclCnt int // number of var decl
 
@@ -406,7 +407,7 @@ func (p *parser) error(pos token.Pos, msg string) {
 		if n > 0 && p.errors[n-1].Pos.Line == epos.Line {
 			return // discard - likely a spurious error
 		}
-		if n > 10 {
+		if n > 10 && p.mode&RecoverErrors == 0 {
 			panic(bailout{})
 		}
 	}
@@ -439,6 +440,9 @@ func (p *parser) expect(tok token.Token) token.Pos {
 	pos := p.pos
 	if p.tok != tok {
 		p.errorExpected(pos, "'"+tok.String()+"'", 3)
+		if p.unclosed(tok) {
+			return pos
+		}
 	}
 	p.next() // make progress
 	return pos
@@ -451,6 +455,9 @@ func (p *parser) expect2(tok token.Token) (pos token.Pos) {
 		pos = p.pos
 	} else {
 		p.errorExpected(p.pos, "'"+tok.String()+"'", 3)
+		if p.unclosed(tok) {
+			return
+		}
 	}
 	p.next() // make progress
 	return
@@ -475,6 +482,11 @@ func (p *parser) expectIn() token.Pos {
 // expectClosing is like expect but provides a better error message
 // for the common case of a missing comma before a newline.
 func (p *parser) expectClosing(tok token.Token, context string) token.Pos {
+	if p.tok != tok && p.unclosed(tok) {
+		pos := p.pos
+		p.errorExpected(pos, "'"+tok.String()+"'", 2)
+		return pos
+	}
 	if p.tok != tok && p.tok == token.SEMICOLON && p.lit == "\n" {
 		p.error(p.pos, "missing ',' before newline in "+context)
 		p.next()
@@ -494,7 +506,11 @@ func (p *parser) expectSemi() {
 			p.next()
 		default:
 			p.errorExpected(p.pos, "';'", 3)
-			p.advance(stmtStart)
+			if p.mode&RecoverErrors != 0 {
+				p.skipStmt()
+			} else {
+				p.advance(stmtStart)
+			}
 		}
 	}
 }
@@ -504,6 +520,9 @@ func (p *parser) atComma(context string, follow token.Token) bool {
 		return true
 	}
 	if p.tok != follow {
+		if p.mode&RecoverErrors != 0 && p.atStmtEnd() {
+			return false // the list isn't closed: stop at the end of the statement
+		}
 		msg := "missing ','"
 		if p.tok == token.SEMICOLON && p.lit == "\n" {
 			msg += " before newline"
@@ -554,6 +573,78 @@ func (p *parser) advance(to map[token.Token]bool) {
 	}
 }
 
+// atStmtEnd reports whether the current token ends a statement. It is used
+// by RecoverErrors mode to synchronize unbalanced brackets.
+func (p *parser) atStmtEnd() bool {
+	switch p.tok {
+	case token.SEMICOLON, token.RBRACE, token.EOF:
+		return true
+	}
+	return p.atDeclStart()
+}
+
+// atDeclStart reports whether the current token is a declaration keyword at
+// the beginning of a line. In RecoverErrors mode, it ends unclosed blocks.
+func (p *parser) atDeclStart() bool {
+	if p.mode&RecoverErrors != 0 {
+		switch p.tok {
+		case token.FUNC, token.TYPE, token.IMPORT, token.CONST, token.VAR:
+			return p.file.Position(p.pos).Column == 1
+		}
+	}
+	return false
+}
+
+// unclosed reports whether the closing bracket tok is missing, that is, the
+// current token ends a statement. It is used by RecoverErrors mode to not
+// consume the tokens of the next statement.
+func (p *parser) unclosed(tok token.Token) bool {
+	if p.mode&RecoverErrors != 0 {
+		switch tok {
+		case token.RPAREN, token.RBRACK, token.RBRACE:
+			return p.atStmtEnd()
+		}
+	}
+	return false
+}
+
+// continued reports whether the line after the newline at pos continues the
+// line of start, that is, it is indented deeper. It is used by RecoverErrors
+// mode to find where an unclosed bracket ends if newlines are allowed in it.
+func (p *parser) continued(start, pos token.Pos) bool {
+	f := p.file
+	src := p.scanner.CodeTo(f.Size())
+	indent := func(off int) int {
+		n := 0
+		for off+n < len(src) && (src[off+n] == ' ' || src[off+n] == '\t') {
+			n++
+		}
+		return n
+	}
+	off := f.Offset(pos) + 1
+	for { // skip blank lines
+		n := indent(off)
+		if off+n >= len(src) {
+			return false
+		}
+		if c := src[off+n]; c != '\n' && c != '\r' {
+			return n > indent(f.Offset(f.LineStart(f.Line(start))))
+		}
+		off += n + 1
+	}
+}
+
+// skipStmt consumes tokens up to the end of the current statement. For
+// error recovery in RecoverErrors mode.
+func (p *parser) skipStmt() {
+	for !p.atStmtEnd() {
+		p.next()
+	}
+	if p.tok == token.SEMICOLON {
+		p.next()
+	}
+}
+
 var stmtStart = map[token.Token]bool{
 	token.BREAK:       true,
 	token.CONST:       true,
@@ -914,6 +1005,9 @@ func (p *parser) parseSliceOrMatrixLit(lbrack token.Pos, first ast.Expr) ast.Exp
 		switch p.tok {
 		case token.COMMA:
 		case token.SEMICOLON:
+			if p.mode&RecoverErrors != 0 && p.lit == "\n" && !p.continued(lbrack, p.pos) {
+				goto done // unclosed '['
+			}
 			mat = append(mat, elts)
 			elts = make([]ast.Expr, 0, len(elts))
 		case token.ELLIPSIS:
@@ -1584,6 +1678,9 @@ func (p *parser) parseStmtList() (list []ast.Stmt) {
 	}
 
 	for p.tok != token.CASE && p.tok != token.DEFAULT && p.tok != token.RBRACE && p.tok != token.EOF {
+		if p.blockLev > 0 && p.atDeclStart() { // unclosed block
+			break
+		}
 		list = append(list, p.parseStmt(true))
 	}
 
@@ -1598,7 +1695,9 @@ func (p *parser) parseBody(scope *ast.Scope) *ast.BlockStmt {
 	lbrace := p.expect(token.LBRACE)
 	p.topScope = scope // open function scope
 	p.openLabelScope()
+	p.blockLev++
 	list := p.parseStmtList()
+	p.blockLev--
 	p.closeLabelScope()
 	p.closeScope()
 	rbrace := p.expect2(token.RBRACE)
@@ -1613,7 +1712,9 @@ func (p *parser) parseBlockStmt() *ast.BlockStmt {
 
 	lbrace := p.expect(token.LBRACE)
 	p.openScope()
+	p.blockLev++
 	list := p.parseStmtList()
+	p.blockLev--
 	p.closeScope()
 	rbrace := p.expect2(token.RBRACE)
 
@@ -1675,7 +1776,14 @@ loop:
 			parts = append(parts, text[:at])
 		}
 		to := pos + token.Pos(from+end)
-		parts = p.stringLitExpr(parts, pos+token.Pos(from), to)
+		if i := formatColon(left[:end]); i >= 0 { // ${expr:format}
+			colon := pos + token.Pos(from+i)
+			parts = p.stringLitExpr(parts, pos+token.Pos(from), colon)
+			n := len(parts) - 1
+			parts[n] = &ast.FormatExpr{X: parts[n].(ast.Expr), Colon: colon, Format: left[i+1 : end]}
+		} else {
+			parts = p.stringLitExpr(parts, pos+token.Pos(from), to)
+		}
 		pos = to + 1
 		text = left[end+1:]
 	case '$': // $$
@@ -1698,6 +1806,36 @@ normal:
 	return parts
 }
 
+// formatColon returns the index of ':' in `expr:format` (the text of a
+// ${...} part), or -1 if there is no format. A format is a fmt verb with
+// optional flags, width and precision, eg. %.2f or 08x (the '%' is optional).
+// `expr?:val` is not a format.
+func formatColon(text string) int {
+	i := strings.LastIndexByte(text, ':')
+	if i < 0 || !isFormatSpec(text[i+1:]) {
+		return -1
+	}
+	x := strings.TrimRight(text[:i], " \t")
+	if x == "" || x[len(x)-1] == '?' {
+		return -1
+	}
+	return i
+}
+
+func isFormatSpec(spec string) bool {
+	spec = strings.TrimPrefix(spec, "%")
+	spec = strings.TrimLeft(spec, "-+# 0")
+	spec = strings.TrimLeft(spec, "0123456789")
+	if strings.HasPrefix(spec, ".") {
+		spec = strings.TrimLeft(spec[1:], "0123456789")
+	}
+	if len(spec) != 1 {
+		return false
+	}
+	c := spec[0]
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
 func hasExtra(text string) bool {
 	for {
 		at := strings.IndexByte(text, '$')
@@ -1754,8 +1892,8 @@ func (p *parser) parseOperand(lhs, allowTuple, allowCmd bool) (x ast.Expr, isTup
 	switch p.tok {
 	case token.IDENT:
 		ident := p.parseIdent()
-		if p.tok == token.STRING && p.pos == ident.End() && p.lit[0] == '`' {
-			// domain text: tpl`...`
+		if p.tok == token.STRING && p.pos == ident.End() && (p.lit[0] == '`' || ident.Name != "tpl") {
+			// domain text: tpl`...`, json"..."
 			var pos, lit = p.pos, p.lit
 			var extra any
 			if ident.Name == "tpl" {
@@ -1893,10 +2031,20 @@ func (p *parser) parseEnvExpr() (ret *ast.EnvExpr) {
 	}
 	ret = &ast.EnvExpr{TokPos: p.pos}
 	p.next()
-	if p.tok == token.LBRACE { // ${name}
+	if p.tok == token.LBRACE { // ${name} or ${name:-default}
 		ret.Lbrace = p.pos
 		p.next()
 		ret.Name = p.parseIdent()
+		if p.tok == token.COLON {
+			ret.Colon = p.pos
+			p.next()
+			if p.tok == token.DEC { // ${name:--1}: `:-` followed by a negative default
+				p.pos, p.tok = p.pos+1, token.SUB
+			} else {
+				p.expect(token.SUB)
+			}
+			ret.Default = p.parseRHS()
+		}
 		ret.Rbrace = p.expect(token.RBRACE)
 	} else { // $name
 		ret.Name = p.parseIdent()
@@ -2240,6 +2388,7 @@ func (p *parser) checkExpr(x ast.Expr) ast.Expr {
 	case *ast.FuncLit:
 	case *ast.CompositeLit:
 	case *ast.SliceLit:
+	case *ast.MatrixLit:
 	case *ast.ComprehensionExpr:
 	case *ast.SelectorExpr:
 	case *ast.IndexExpr:
@@ -3549,7 +3698,12 @@ func (p *parser) parseStmt(allowCmd bool) (s ast.Stmt) {
 		// no statement found
 		pos := p.pos
 		p.errorExpected(pos, "statement", 2)
-		p.advance(stmtStart)
+		if p.mode&RecoverErrors != 0 {
+			p.next() // make progress
+			p.skipStmt()
+		} else {
+			p.advance(stmtStart)
+		}
 		s = &ast.BadStmt{From: pos, To: p.pos}
 	}
 
@@ -3749,7 +3903,7 @@ func (p *parser) parseGenDecl(keyword token.Token, f parseSpecFunction) *ast.Gen
 	if p.tok == token.LPAREN {
 		lparen = p.pos
 		p.next()
-		for iota := 0; p.tok != token.RPAREN && p.tok != token.EOF; iota++ {
+		for iota := 0; p.tok != token.RPAREN && p.tok != token.EOF && !p.atDeclStart(); iota++ {
 			list = append(list, f(p.leadComment, keyword, iota))
 		}
 		rparen = p.expect(token.RPAREN)
@@ -4007,7 +4161,7 @@ func (p *parser) parseDecl(sync map[token.Token]bool) ast.Decl {
 	case token.FUNC:
 		decl, call := p.parseFuncDeclOrCall()
 		if decl != nil {
-			if p.errors.Len() != 0 {
+			if p.errors.Len() != 0 && p.mode&RecoverErrors == 0 {
 				p.advance(sync)
 			}
 			return decl
@@ -4024,12 +4178,20 @@ func (p *parser) parseGlobalStmts(sync map[token.Token]bool, pos token.Pos, stmt
 	doc := p.leadComment
 	p.openLabelScope()
 	list := p.parseStmtList()
+	for p.mode&RecoverErrors != 0 && p.tok != token.EOF { // unexpected '}', case or default
+		pos := p.pos
+		p.errorExpected(pos, "statement", 2)
+		p.next()
+		p.skipStmt()
+		list = append(list, &ast.BadStmt{From: pos, To: p.pos})
+		list = append(list, p.parseStmtList()...)
+	}
 	p.closeLabelScope()
 	p.closeScope()
 	if stmts != nil {
 		list = append(stmts, list...)
 	}
-	if p.errors.Len() != 0 { // TODO(xsw): error
+	if p.errors.Len() != 0 && p.mode&RecoverErrors == 0 { // TODO(xsw): error
 		p.advance(sync)
 	}
 	if p.tok != token.EOF {
@@ -4057,7 +4219,7 @@ func (p *parser) parseFile() *ast.File {
 
 	// Don't bother parsing the rest if we had errors scanning the first token.
 	// Likely not a Go source file at all.
-	if p.errors.Len() != 0 {
+	if p.errors.Len() != 0 && p.mode&RecoverErrors == 0 {
 		return nil
 	}
 
@@ -4078,7 +4240,7 @@ func (p *parser) parseFile() *ast.File {
 
 		// Don't bother parsing the rest if we had errors parsing the package clause.
 		// Likely not a Go source file at all.
-		if p.errors.Len() != 0 {
+		if p.errors.Len() != 0 && p.mode&RecoverErrors == 0 {
 			return nil
 		}
 	} else {
@@ -17,7 +17,9 @@
 package parser
 
 import (
+	"fmt"
 	"io/fs"
+	"strings"
 	"testing"
 
 	"github.com/goplus/gop/ast"
@@ -359,3 +361,130 @@ func TestNumberUnitLit(t *testing.T) {
 }
 
 // -----------------------------------------------------------------------------
+
+func parseRecover(t *testing.T, code string) *ast.File {
+	t.Helper()
+	fset := token.NewFileSet()
+	f, err := parseFile(fset, "/foo/bar.gop", code, RecoverErrors)
+	if f == nil || err == nil {
+		t.Fatal("parseRecover:", f, err)
+	}
+	return f
+}
+
+func declNames(f *ast.File) (names []string) {
+	for _, decl := range f.Decls {
+		if d, ok := decl.(*ast.FuncDecl); ok {
+			names = append(names, d.Name.Name)
+		}
+	}
+	return
+}
+
+func stmtTypes(list []ast.Stmt) (types []string) {
+	for _, stmt := range list {
+		types = append(types, fmt.Sprintf("%T", stmt))
+	}
+	return
+}
+
+func TestRecoverUnclosedCall(t *testing.T) {
+	f := parseRecover(t, `x := foo(1, 2
+y := [1, 2
+println y
+`)
+	if f.ShadowEntry == nil {
+		t.Fatal("no shadow entry")
+	}
+	list := f.ShadowEntry.Body.List
+	if ret := stmtTypes(list); len(ret) != 3 || ret[2] != "*ast.ExprStmt" {
+		t.Fatal("stmts:", ret)
+	}
+	call := list[0].(*ast.AssignStmt).Rhs[0].(*ast.CallExpr)
+	if len(call.Args) != 2 {
+		t.Fatal("args:", call.Args)
+	}
+}
+
+func TestRecoverUnclosedBlock(t *testing.T) {
+	f := parseRecover(t, `func foo() {
+	if x {
+		println 1
+
+func bar() {
+	a := (1 +
+}
+
+type T struct {
+	A int
+`)
+	if ret := declNames(f); len(ret) != 2 || ret[0] != "foo" || ret[1] != "bar" {
+		t.Fatal("decls:", ret)
+	}
+	if len(f.Decls) != 3 {
+		t.Fatal("decls:", f.Decls)
+	}
+}
+
+func TestRecoverBadStmt(t *testing.T) {
+	f := parseRecover(t, `func foo() {
+	x := 1
+	)
+	y := 2
+}
+`)
+	body := f.Decls[0].(*ast.FuncDecl).Body
+	if ret := stmtTypes(body.List); len(ret) != 3 || ret[1] != "*ast.BadStmt" || ret[2] != "*ast.AssignStmt" {
+		t.Fatal("stmts:", ret)
+	}
+	f = parseRecover(t, `x := 1
+}
+y := 2
+`)
+	if ret := stmtTypes(f.ShadowEntry.Body.List); len(ret) != 3 || ret[1] != "*ast.BadStmt" {
+		t.Fatal("stmts:", ret)
+	}
+}
+
+func TestRecoverTooMany(t *testing.T) {
+	var b strings.Builder
+	for i := 0; i < 20; i++ {
+		fmt.Fprintf(&b, "func f%d() { var }\n", i)
+	}
+	f := parseRecover(t, b.String())
+	if len(f.Decls) != 20 {
+		t.Fatal("decls:", len(f.Decls))
+	}
+}
+
+func TestRecoverPrefixes(t *testing.T) {
+	const code = `import "fmt"
+
+type T struct {
+	A int
+}
+
+func (t *T) Get(n int) (int, error) {
+	if n > 0 {
+		return t.A + n, nil
+	}
+	return [x*x for x in [1, 2, 3] if x > 1][0], nil
+}
+
+var m = {"a": 1, "b": 2}
+
+for k, v in m {
+	fmt.Println(k, v)
+}
+echo "${m}", m?
+`
+	for i := range code {
+		fset := token.NewFileSet()
+		f, _ := parseFile(fset, "/foo/bar.gop", code[:i], RecoverErrors)
+		if f == nil || f.Name == nil {
+			t.Fatal("parse prefix failed:", i)
+		}
+	}
+}
+
+// -----------------------------------------------------------------------------
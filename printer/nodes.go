@@ -1126,7 +1126,12 @@ func (p *printer) expr1(expr ast.Expr, prec1, depth int) {
 	case *ast.EnvExpr:
 		p.print(token.ENV)
 		if x.HasBrace() {
-			p.print(token.LBRACE, x.Name, token.RBRACE)
+			p.print(token.LBRACE, x.Name)
+			if x.Default != nil {
+				p.print(token.COLON, token.SUB)
+				p.expr(x.Default)
+			}
+			p.print(token.RBRACE)
 		} else {
 			p.print(x.Name)
 		}
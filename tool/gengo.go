@@ -24,6 +24,7 @@ import (
 	"strings"
 	"syscall"
 
+	"github.com/goplus/gop/x/sourcemap"
 	"github.com/goplus/mod/gopmod"
 	"github.com/goplus/mod/modcache"
 	"github.com/goplus/mod/modfetch"
@@ -44,6 +45,7 @@ const (
 	GenFlagSingleFile
 	GenFlagPrintError
 	GenFlagPrompt
+	GenFlagSourceMap // also write a source map next to each autogen file
 )
 
 // -----------------------------------------------------------------------------
@@ -162,7 +164,24 @@ func genGoSingleFile(file string, conf *Config, flags GenFlags) (err error) {
 	if err := out.WriteFile(autogen); err != nil {
 		return errors.NewWith(err, `out.WriteFile(autogen)`, -2, "(*gogen.Package).WriteFile", out, autogen)
 	}
-	return nil
+	return writeSourceMap(autogen, flags)
+}
+
+// writeSourceMap writes the source map of the autogen file if
+// GenFlagSourceMap is set.
+func writeSourceMap(autogen string, flags GenFlags) error {
+	if flags&GenFlagSourceMap == 0 {
+		return nil
+	}
+	src, err := os.ReadFile(autogen)
+	if err != nil {
+		return errors.NewWith(err, `os.ReadFile(autogen)`, -2, "os.ReadFile", autogen)
+	}
+	m, err := sourcemap.Build(filepath.Base(autogen), src)
+	if err != nil {
+		return errors.NewWith(err, `sourcemap.Build(autogen, src)`, -2, "sourcemap.Build", autogen, src)
+	}
+	return m.WriteFile(autogen + sourcemap.Ext)
 }
 
 func genGoIn(dir string, conf *Config, genTestPkg bool, flags GenFlags, gen ...*bool) (err error) {
@@ -185,12 +204,20 @@ func genGoIn(dir string, conf *Config, genTestPkg bool, flags GenFlags, gen ...*
 	if gen != nil { // say `gop_autogen.go generated`
 		*gen[0] = true
 	}
+	if err = writeSourceMap(file, flags); err != nil {
+		return
+	}
 
 	testFile := filepath.Join(dir, autoGenTestFile)
 	err = out.WriteFile(testFile, testingGoFile)
 	if err != nil && err != syscall.ENOENT {
 		return errors.NewWith(err, `out.WriteFile(testFile, testingGoFile)`, -2, "(*gogen.Package).WriteFile", out, testFile, testingGoFile)
 	}
+	if err == nil {
+		if err = writeSourceMap(testFile, flags); err != nil {
+			return
+		}
+	}
 
 	if test != nil {
 		testFile = filepath.Join(dir, autoGen2TestFile)
@@ -198,6 +225,7 @@ func genGoIn(dir string, conf *Config, genTestPkg bool, flags GenFlags, gen ...*
 		if err != nil {
 			return errors.NewWith(err, `test.WriteFile(testFile, testingGoFile)`, -2, "(*gogen.Package).WriteFile", test, testFile, testingGoFile)
 		}
+		err = writeSourceMap(testFile, flags)
 	} else {
 		err = nil
 	}
@@ -132,6 +132,14 @@ type Config struct {
 
 	IgnoreNotatedError bool
 	DontUpdateGoMod    bool
+
+	// MaxErrors is the maximum number of errors to report of compiling a Go+
+	// package (optional, 0 means no limit). See cl.Config.MaxErrors.
+	MaxErrors int
+
+	// OnWarning is called to report the warnings of compiling Go+ files
+	// (optional). See cl.Config.OnWarning.
+	OnWarning func(pos token.Pos, code, msg string)
 }
 
 // ConfFlags represents configuration flags.
@@ -274,6 +282,9 @@ func LoadDir(dir string, conf *Config, genTestPkg bool, promptGenGo ...bool) (ou
 		RelativeBase: relativeBaseOf(mod),
 		Importer:     imp,
 		LookupClass:  mod.LookupClass,
+		Domains:      cl.ModDomains(mod.Opt),
+		MaxErrors:    conf.MaxErrors,
+		OnWarning:    conf.OnWarning,
 	}
 
 	for name, pkg := range pkgs {
@@ -394,6 +405,9 @@ func LoadFiles(dir string, files []string, conf *Config) (out *gogen.Package, er
 			RelativeBase: relativeBaseOf(mod),
 			Importer:     imp,
 			LookupClass:  mod.LookupClass,
+			Domains:      cl.ModDomains(mod.Opt),
+			MaxErrors:    conf.MaxErrors,
+			OnWarning:    conf.OnWarning,
 		}
 		out, err = cl.NewPackage("", pkg, clConf)
 		if err != nil {
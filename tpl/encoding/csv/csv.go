@@ -19,9 +19,20 @@ package csv
 import (
 	"encoding/csv"
 	"strings"
+
+	"github.com/goplus/gop/tpl/encoding"
 )
 
 // New creates a new csv object from a string
 func New(text string) (records [][]string, err error) {
 	return csv.NewReader(strings.NewReader(text)).ReadAll()
 }
+
+// Check checks if text is valid csv.
+func Check(text string) error {
+	_, err := New(text)
+	if e, ok := err.(*csv.ParseError); ok {
+		return &encoding.SyntaxError{Offset: encoding.OffsetOf(text, e.Line, e.Column), Err: err}
+	}
+	return err
+}
@@ -0,0 +1,78 @@
+/*
+ * Copyright (c) 2025 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package encoding holds the common definitions of the text domains in its
+// subpackages.
+package encoding
+
+import (
+	"regexp/syntax"
+	"strings"
+)
+
+// A SyntaxError is an error in the text of a domain text literal.
+type SyntaxError struct {
+	Offset int // byte offset of the error in the text
+	Err    error
+}
+
+func (p *SyntaxError) Error() string {
+	return p.Err.Error()
+}
+
+func (p *SyntaxError) Unwrap() error {
+	return p.Err
+}
+
+// OffsetOf returns the byte offset of a (line, col) position in text. Both
+// line and col are 1-based, and col counts bytes.
+func OffsetOf(text string, line, col int) int {
+	off := 0
+	for ; line > 1; line-- {
+		i := strings.IndexByte(text[off:], '\n')
+		if i < 0 {
+			return len(text)
+		}
+		off += i + 1
+	}
+	if col > 1 {
+		off += col - 1
+	}
+	if off > len(text) {
+		off = len(text)
+	}
+	return off
+}
+
+// RegexpError returns the SyntaxError of e, an error of parsing the regular
+// expression text with flags. Its offset is the one of the fragment e.Expr
+// which fails: the first occurrence whose prefix of text fails the same way.
+func RegexpError(text string, flags syntax.Flags, e *syntax.Error) *SyntaxError {
+	for off := 0; ; {
+		i := strings.Index(text[off:], e.Expr)
+		if i < 0 {
+			break
+		}
+		off += i
+		end := off + len(e.Expr)
+		_, err := syntax.Parse(text[:end], flags)
+		if pe, ok := err.(*syntax.Error); ok && pe.Code == e.Code && pe.Expr == e.Expr {
+			return &SyntaxError{Offset: off, Err: e}
+		}
+		off++
+	}
+	return &SyntaxError{Offset: 0, Err: e}
+}
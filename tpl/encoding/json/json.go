@@ -18,7 +18,10 @@ package json
 
 import (
 	"encoding/json"
+	"io"
 	"strings"
+
+	"github.com/goplus/gop/tpl/encoding"
 )
 
 // New creates a new json object from a string
@@ -26,3 +29,23 @@ func New(text string) (ret any, err error) {
 	err = json.NewDecoder(strings.NewReader(text)).Decode(&ret)
 	return
 }
+
+// Check checks if text is a valid json value.
+func Check(text string) error {
+	var ret any
+	err := json.NewDecoder(strings.NewReader(text)).Decode(&ret)
+	switch e := err.(type) {
+	case nil:
+		return nil
+	case *json.SyntaxError:
+		off := int(e.Offset)
+		if off > 0 {
+			off-- // e.Offset is after the invalid byte
+		}
+		return &encoding.SyntaxError{Offset: off, Err: err}
+	}
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return &encoding.SyntaxError{Offset: len(text), Err: err}
+}
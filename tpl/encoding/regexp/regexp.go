@@ -18,9 +18,21 @@ package regexp
 
 import (
 	"regexp"
+	"regexp/syntax"
+
+	"github.com/goplus/gop/tpl/encoding"
 )
 
 // New creates a new regexp object from a string
 func New(text string) (*regexp.Regexp, error) {
 	return regexp.Compile(text)
 }
+
+// Check checks if text is a valid regular expression.
+func Check(text string) error {
+	_, err := syntax.Parse(text, syntax.Perl)
+	if e, ok := err.(*syntax.Error); ok {
+		return encoding.RegexpError(text, syntax.Perl, e)
+	}
+	return err
+}
@@ -18,9 +18,21 @@ package regexposix
 
 import (
 	"regexp"
+	"regexp/syntax"
+
+	"github.com/goplus/gop/tpl/encoding"
 )
 
 // New creates a new POSIX regexp object from a string
 func New(text string) (*regexp.Regexp, error) {
 	return regexp.CompilePOSIX(text)
 }
+
+// Check checks if text is a valid POSIX regular expression.
+func Check(text string) error {
+	_, err := syntax.Parse(text, syntax.POSIX)
+	if e, ok := err.(*syntax.Error); ok {
+		return encoding.RegexpError(text, syntax.POSIX, e)
+	}
+	return err
+}
@@ -18,7 +18,10 @@ package xml
 
 import (
 	"encoding/xml"
+	"io"
 	"strings"
+
+	"github.com/goplus/gop/tpl/encoding"
 )
 
 // New creates a new xml object from a string
@@ -26,3 +29,36 @@ func New(text string) (ret any, err error) {
 	err = xml.NewDecoder(strings.NewReader(text)).Decode(&ret)
 	return
 }
+
+// Check checks if text is well-formed xml.
+func Check(text string) error {
+	d := xml.NewDecoder(strings.NewReader(text))
+	for {
+		_, err := d.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			off := 0
+			if e, ok := err.(*xml.SyntaxError); ok {
+				off = errorOffset(text, int(d.InputOffset()), e.Line)
+			}
+			return &encoding.SyntaxError{Offset: off, Err: err}
+		}
+	}
+}
+
+// errorOffset returns the offset of a syntax error on line. The decoder stops
+// at off, right after the byte where it detects the error.
+func errorOffset(text string, off, line int) int {
+	if off > len(text) {
+		off = len(text)
+	}
+	if off > 0 {
+		off--
+	}
+	if strings.Count(text[:off], "\n")+1 != line {
+		off = encoding.OffsetOf(text, line, 1)
+	}
+	return off
+}
@@ -0,0 +1,349 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package fakenet
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrReset is returned by the operations on a Conn whose peer was closed
+	// abruptly by a FaultClose.
+	ErrReset = errors.New("fakenet: connection reset by peer")
+)
+
+// Fault is a failure injected into a Conn.
+type Fault int
+
+const (
+	// FaultDrop silently drops the bytes of a write. The bytes written later
+	// are still delivered in order.
+	FaultDrop Fault = iota + 1
+	// FaultShortWrite delivers the first half of the bytes of a write, which
+	// then fails with io.ErrShortWrite.
+	FaultShortWrite
+	// FaultClose closes the connection abruptly instead of writing: pending
+	// bytes are discarded, and the peer gets ErrReset.
+	FaultClose
+	// FaultCloseWrite delivers the bytes of a write, then half-closes the
+	// connection: the peer reads io.EOF, but can still write.
+	FaultCloseWrite
+)
+
+// Event schedules a Fault on the Nth call to Write (counted from 1) of a side
+// of a connection.
+type Event struct {
+	Write int
+	Fault Fault
+}
+
+// Config configures the connections made by a Listener.
+type Config struct {
+	// Latency is how long written bytes take to become readable by the peer.
+	Latency time.Duration
+	// ReadChunk, if positive, is the maximum number of bytes returned by a Read,
+	// to break messages into pieces.
+	ReadChunk int
+	// Client schedules the faults of the dialed side of each connection.
+	Client []Event
+	// Server schedules the faults of the accepted side of each connection.
+	Server []Event
+}
+
+// -----------------------------------------------------------------------------
+
+// Listener is an in-memory listener. Each call to Dial makes a new connection,
+// the other side of which is returned by Accept.
+type Listener struct {
+	conf   Config
+	conns  chan *Conn
+	done   chan struct{}
+	closed sync.Once
+}
+
+// Listen returns a new in-memory listener. If conf is nil, its connections
+// are free of faults.
+func Listen(conf *Config) *Listener {
+	l := &Listener{conns: make(chan *Conn), done: make(chan struct{})}
+	if conf != nil {
+		l.conf = *conf
+	}
+	return l
+}
+
+// Accept blocks waiting for a connection to be dialed or the listener to be
+// closed.
+func (l *Listener) Accept(ctx context.Context) (io.ReadWriteCloser, error) {
+	select {
+	case <-l.done:
+		return nil, net.ErrClosed
+	default:
+	}
+	select {
+	case c := <-l.conns:
+		return c, nil
+	case <-l.done:
+		return nil, net.ErrClosed
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Close stops the listener. It doesn't close the connections already made.
+func (l *Listener) Close() error {
+	l.closed.Do(func() { close(l.done) })
+	return nil
+}
+
+// Dial makes a new connection to the listener.
+func (l *Listener) Dial(ctx context.Context) (io.ReadWriteCloser, error) {
+	up, down := newPipe(), newPipe()
+	client := &Conn{name: "fakenet.client", in: down, out: up, conf: &l.conf, events: l.conf.Client}
+	server := &Conn{name: "fakenet.server", in: up, out: down, conf: &l.conf, events: l.conf.Server}
+	select {
+	case l.conns <- server:
+		return client, nil
+	case <-l.done:
+		return nil, net.ErrClosed
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// -----------------------------------------------------------------------------
+
+// Conn is a side of an in-memory connection made by a Listener.
+type Conn struct {
+	name string
+	in   *pipe // read by this side
+	out  *pipe // written by this side
+	conf *Config
+
+	mu     sync.Mutex // serializes writes
+	events []Event
+	writes int
+}
+
+var _ net.Conn = (*Conn)(nil)
+
+func (c *Conn) Read(b []byte) (int, error) {
+	if n := c.conf.ReadChunk; n > 0 && len(b) > n {
+		b = b[:n]
+	}
+	return c.in.read(b)
+}
+
+func (c *Conn) Write(b []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.writes++
+	var fault Fault
+	for _, e := range c.events {
+		if e.Write == c.writes {
+			fault = e.Fault
+		}
+	}
+	at := time.Now().Add(c.conf.Latency)
+	switch fault {
+	case FaultDrop:
+		if err := c.out.writable(); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	case FaultShortWrite:
+		n, err := c.out.write(b[:len(b)/2], at)
+		if err == nil {
+			err = io.ErrShortWrite
+		}
+		return n, err
+	case FaultClose:
+		c.abort()
+		return 0, net.ErrClosed
+	case FaultCloseWrite:
+		n, err := c.out.write(b, at)
+		c.out.closeWrite()
+		return n, err
+	}
+	return c.out.write(b, at)
+}
+
+// CloseWrite half-closes the connection: the peer reads io.EOF once it has
+// read all the bytes written before.
+func (c *Conn) CloseWrite() error {
+	c.out.closeWrite()
+	return nil
+}
+
+// Close closes the connection. The peer reads io.EOF once it has read all the
+// bytes written before, and its writes fail.
+func (c *Conn) Close() error {
+	c.out.closeWrite()
+	c.in.closeRead()
+	return nil
+}
+
+// abort closes the connection abruptly.
+func (c *Conn) abort() {
+	c.in.reset(false)
+	c.out.reset(true)
+}
+
+func (c *Conn) LocalAddr() net.Addr                { return fakeAddr(c.name) }
+func (c *Conn) RemoteAddr() net.Addr               { return fakeAddr(c.name) }
+func (c *Conn) SetDeadline(t time.Time) error      { return nil }
+func (c *Conn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *Conn) SetWriteDeadline(t time.Time) error { return nil }
+
+// -----------------------------------------------------------------------------
+
+// pipe is a direction of an in-memory connection.
+type pipe struct {
+	mu      sync.Mutex
+	changed chan struct{} // closed and replaced when the state changes
+	chunks  []chunk
+	wclosed bool  // no more bytes will be written
+	rclosed bool  // the reader has gone
+	err     error // error of reads, set when the pipe is reset
+	werr    error // error of writes, set when the pipe is reset
+}
+
+type chunk struct {
+	data []byte
+	at   time.Time // when data becomes readable
+}
+
+func newPipe() *pipe {
+	return &pipe{changed: make(chan struct{})}
+}
+
+// notify wakes up the readers waiting for a change. It must be called with
+// p.mu held.
+func (p *pipe) notify() {
+	close(p.changed)
+	p.changed = make(chan struct{})
+}
+
+func (p *pipe) read(b []byte) (int, error) {
+	p.mu.Lock()
+	for {
+		switch {
+		case p.rclosed:
+			p.mu.Unlock()
+			return 0, net.ErrClosed
+		case p.err != nil:
+			err := p.err
+			p.mu.Unlock()
+			return 0, err
+		}
+		var timer <-chan time.Time
+		if len(p.chunks) > 0 {
+			c := &p.chunks[0]
+			wait := time.Until(c.at)
+			if wait <= 0 {
+				n := copy(b, c.data)
+				if c.data = c.data[n:]; len(c.data) == 0 {
+					p.chunks = p.chunks[1:]
+				}
+				p.mu.Unlock()
+				return n, nil
+			}
+			timer = time.After(wait)
+		} else if p.wclosed {
+			p.mu.Unlock()
+			return 0, io.EOF
+		}
+		changed := p.changed
+		p.mu.Unlock()
+		select {
+		case <-changed:
+		case <-timer:
+		}
+		p.mu.Lock()
+	}
+}
+
+// writable returns the error a write would fail with.
+func (p *pipe) writable() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.writeErr()
+}
+
+func (p *pipe) writeErr() error {
+	switch {
+	case p.werr != nil:
+		return p.werr
+	case p.wclosed:
+		return net.ErrClosed
+	case p.rclosed:
+		return io.ErrClosedPipe
+	}
+	return nil
+}
+
+func (p *pipe) write(b []byte, at time.Time) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.writeErr(); err != nil {
+		return 0, err
+	}
+	if len(b) > 0 {
+		p.chunks = append(p.chunks, chunk{data: append([]byte(nil), b...), at: at})
+		p.notify()
+	}
+	return len(b), nil
+}
+
+func (p *pipe) closeWrite() {
+	p.mu.Lock()
+	if !p.wclosed {
+		p.wclosed = true
+		p.notify()
+	}
+	p.mu.Unlock()
+}
+
+func (p *pipe) closeRead() {
+	p.mu.Lock()
+	if !p.rclosed {
+		p.rclosed = true
+		p.chunks = nil
+		p.notify()
+	}
+	p.mu.Unlock()
+}
+
+// reset discards the pending bytes of the pipe, and makes its operations fail.
+// The side which reset the pipe gets net.ErrClosed, and its peer ErrReset.
+func (p *pipe) reset(writer bool) {
+	p.mu.Lock()
+	p.chunks = nil
+	if writer {
+		p.werr, p.err = net.ErrClosed, ErrReset
+	} else {
+		p.werr, p.err = ErrReset, net.ErrClosed
+	}
+	p.notify()
+	p.mu.Unlock()
+}
+
+// -----------------------------------------------------------------------------
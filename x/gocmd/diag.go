@@ -0,0 +1,272 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gocmd
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/goplus/gop/x/sourcemap"
+)
+
+// -----------------------------------------------------------------------------
+
+// gopOperators maps the Gop_xxx methods of operators to the operators.
+var gopOperators = map[string]string{
+	"Gop_Add": "+", "Gop_Sub": "-", "Gop_Mul": "*", "Gop_Quo": "/", "Gop_Rem": "%",
+	"Gop_And": "&", "Gop_Or": "|", "Gop_Xor": "^", "Gop_Lsh": "<<", "Gop_Rsh": ">>",
+	"Gop_AndNot": "&^",
+
+	"Gop_AddAssign": "+=", "Gop_SubAssign": "-=", "Gop_MulAssign": "*=",
+	"Gop_QuoAssign": "/=", "Gop_RemAssign": "%=", "Gop_AndAssign": "&=",
+	"Gop_OrAssign": "|=", "Gop_XorAssign": "^=", "Gop_LshAssign": "<<=",
+	"Gop_RshAssign": ">>=", "Gop_AndNotAssign": "&^=",
+
+	"Gop_EQ": "==", "Gop_NE": "!=", "Gop_LE": "<=", "Gop_LT": "<", "Gop_GE": ">=",
+	"Gop_GT": ">", "Gop_PointTo": "->", "Gop_PointBi": "<>", "Gop_LAnd": "&&",
+	"Gop_LOr": "||", "Gop_Send": "<-",
+
+	"Gop_Inc": "++", "Gop_Dec": "--", "Gop_Neg": "-", "Gop_Dup": "+", "Gop_Not": "^",
+	"Gop_LNot": "!", "Gop_Recv": "<-",
+}
+
+var (
+	// file.go:line:col: msg, or file.go:line: msg
+	diagRE  = regexp.MustCompile(`^(\S+\.go):(\d+)(?::(\d+))?: `)
+	identRE = regexp.MustCompile(`\bGop_[A-Za-z]+\b|\b[A-Za-z_][A-Za-z0-9_]*__\d+\b|\b_gop_err\b`)
+)
+
+// isAutogen checks if fname is a Go file generated from Go+ sources.
+func isAutogen(fname string) bool {
+	fname = filepath.Base(fname)
+	return strings.HasSuffix(fname, "_autogen.go") || strings.HasSuffix(fname, "_autogen_test.go") ||
+		strings.HasPrefix(fname, "gop_autogen")
+}
+
+// gopIdents rewrites the identifiers generated by the Go+ compiler in a
+// diagnostic message into Go+ terms.
+func gopIdents(msg string) string {
+	return identRE.ReplaceAllStringFunc(msg, func(ident string) string {
+		if op, ok := gopOperators[ident]; ok {
+			return "operator " + op
+		}
+		if ident == "_gop_err" {
+			return "err"
+		}
+		if i := strings.LastIndex(ident, "__"); i > 0 {
+			return ident[:i] // overload suffix
+		}
+		return ident
+	})
+}
+
+// -----------------------------------------------------------------------------
+
+// diagWriter rewrites the diagnostics of the Go toolchain, which are written
+// to it line by line, into Go+ terms: the positions in autogen files are
+// mapped back to the Go+ sources, and the generated identifiers are renamed.
+type diagWriter struct {
+	w       io.Writer
+	dir     string // directory the relative file names are based on
+	buf     []byte
+	maps    map[string]*sourcemap.Map // autogen file => source map (nil if none)
+	lines   map[string][]string       // file => its lines (nil if unreadable)
+	roots   map[string]string         // directory => its module root, see modRoot
+	autogen bool                      // the last diagnostic is of an autogen file
+}
+
+func newDiagWriter(w io.Writer, dir string) *diagWriter {
+	return &diagWriter{
+		w: w, dir: dir, maps: make(map[string]*sourcemap.Map), lines: make(map[string][]string),
+		roots: make(map[string]string),
+	}
+}
+
+func (p *diagWriter) Write(b []byte) (n int, err error) {
+	p.buf = append(p.buf, b...)
+	for {
+		i := bytes.IndexByte(p.buf, '\n')
+		if i < 0 {
+			break
+		}
+		line := p.rewrite(string(p.buf[:i+1]))
+		p.buf = p.buf[i+1:]
+		if _, err = io.WriteString(p.w, line); err != nil {
+			return
+		}
+	}
+	return len(b), nil
+}
+
+// Flush writes the last line if it isn't terminated by a newline.
+func (p *diagWriter) Flush() (err error) {
+	if len(p.buf) > 0 {
+		_, err = io.WriteString(p.w, p.rewrite(string(p.buf)))
+		p.buf = nil
+	}
+	return
+}
+
+func (p *diagWriter) rewrite(line string) string {
+	if strings.HasPrefix(line, "\t") { // continuation of a diagnostic
+		if p.autogen {
+			return gopIdents(line)
+		}
+		return line
+	}
+	m := diagRE.FindStringSubmatchIndex(line)
+	if m == nil {
+		p.autogen = false
+		return line
+	}
+	file := line[m[2]:m[3]]
+	if p.autogen = isAutogen(file); !p.autogen {
+		return line
+	}
+	pos, msg := line[:m[1]], gopIdents(line[m[1]:])
+	goLine, _ := strconv.Atoi(line[m[4]:m[5]])
+	goCol := 1
+	if m[6] >= 0 {
+		goCol, _ = strconv.Atoi(line[m[6]:m[7]])
+	}
+	if gopPos, ok := p.gopPos(file, goLine, goCol); ok {
+		pos = gopPos + ": "
+	}
+	return pos + msg
+}
+
+// gopPos returns the Go+ position of (line, col) in an autogen file. The
+// relative file names of //line comments are based on the module root, see
+// modRoot, and the Go+ file name is printed relative to p.dir. The
+// source map gives the Go+ line only, so col is moved by the difference of the
+// indentations of the Go and Go+ lines. The column is omitted if the Go+ file
+// can't be read.
+func (p *diagWriter) gopPos(file string, line, col int) (string, bool) {
+	file = p.abs(file)
+	m := p.sourceMap(file)
+	if m == nil {
+		return "", false
+	}
+	off := m.Offset(line, col)
+	seg, ok := m.ToGop(off)
+	if !ok {
+		return "", false
+	}
+	gopLine := seg.Line
+	if segLine, _ := m.Position(seg.Start); segLine != line {
+		gopLine += line - segLine
+	}
+	gopFile := seg.File
+	if !filepath.IsAbs(gopFile) {
+		gopFile = filepath.Join(p.modRoot(filepath.Dir(file)), gopFile)
+	}
+	pos := p.rel(gopFile) + ":" + strconv.Itoa(gopLine)
+	if goIndent, ok := p.indent(file, line); ok && col > goIndent {
+		if gopIndent, ok := p.indent(gopFile, gopLine); ok {
+			pos += ":" + strconv.Itoa(col-goIndent+gopIndent)
+		}
+	}
+	return pos, true
+}
+
+// indent returns the number of the leading blanks of a 1-based line of file.
+func (p *diagWriter) indent(file string, line int) (int, bool) {
+	lines, ok := p.lines[file]
+	if !ok {
+		if b, err := os.ReadFile(file); err == nil {
+			lines = strings.Split(string(b), "\n")
+		}
+		p.lines[file] = lines
+	}
+	if line < 1 || line > len(lines) {
+		return 0, false
+	}
+	text := lines[line-1]
+	return len(text) - len(strings.TrimLeft(text, " \t")), true
+}
+
+func (p *diagWriter) abs(file string) string {
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(p.dir, file)
+	}
+	return file
+}
+
+// rel returns file (an absolute path) relative to p.dir if it is in p.dir.
+func (p *diagWriter) rel(file string) string {
+	dir, err := filepath.Abs(p.dir)
+	if err != nil {
+		return file
+	}
+	if rel, err := filepath.Rel(dir, file); err == nil && rel != ".." &&
+		!strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return rel
+	}
+	return file
+}
+
+// modRoot returns the root directory of the module of dir, which the Go+
+// compiler bases the relative file names of //line comments on. It returns
+// dir itself if dir is not in a module.
+func (p *diagWriter) modRoot(dir string) string {
+	if root, ok := p.roots[dir]; ok {
+		return root
+	}
+	root := dir
+	for d := dir; ; {
+		if fileExists(filepath.Join(d, "go.mod")) || fileExists(filepath.Join(d, "gop.mod")) {
+			root = d
+			break
+		}
+		parent := filepath.Dir(d)
+		if parent == d {
+			break
+		}
+		d = parent
+	}
+	p.roots[dir] = root
+	return root
+}
+
+func fileExists(file string) bool {
+	fi, err := os.Stat(file)
+	return err == nil && !fi.IsDir()
+}
+
+// sourceMap loads the source map of an autogen file (an absolute path): from
+// the map file next to it if any, else from its //line comments.
+func (p *diagWriter) sourceMap(file string) *sourcemap.Map {
+	if m, ok := p.maps[file]; ok {
+		return m
+	}
+	m, err := sourcemap.Load(file + sourcemap.Ext)
+	if err != nil {
+		m = nil
+		if src, e := os.ReadFile(file); e == nil {
+			m, _ = sourcemap.Build(filepath.Base(file), src)
+		}
+	}
+	p.maps[file] = m
+	return m
+}
+
+// -----------------------------------------------------------------------------
@@ -0,0 +1,129 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gocmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const autogen = `package main
+
+import "fmt"
+
+//line a.gop:1:1
+func add(a int, b int) int {
+//line a.gop:2:1
+	return a + b
+}
+//line a.gop:5
+func main() {
+//line a.gop:5:1
+	x := add(1, 2)
+	fmt.Println(x)
+}
+`
+
+func TestGopIdents(t *testing.T) {
+	cases := [][2]string{
+		{"a.Gop_Add undefined (type T has no field or method Gop_Add)",
+			"a.operator + undefined (type T has no field or method operator +)"},
+		{"cannot use x (variable of type int) as string value in argument to foo__1",
+			"cannot use x (variable of type int) as string value in argument to foo"},
+		{"declared and not used: _gop_err", "declared and not used: err"},
+		{"Gop_Unknown and _gop_errs", "Gop_Unknown and _gop_errs"},
+	}
+	for _, c := range cases {
+		if ret := gopIdents(c[0]); ret != c[1] {
+			t.Fatalf("gopIdents(%q) = %q", c[0], ret)
+		}
+	}
+}
+
+const gopSrc = `func add(a int, b int) int {
+	return a + b
+}
+
+x := add(1, 2)
+echo x
+`
+
+func TestDiagWriter(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "gop_autogen.go"), []byte(autogen), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "a.gop"), []byte(gopSrc), 0666); err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	w := newDiagWriter(&out, dir)
+	w.Write([]byte("# main\n./gop_autogen.go:13:7: undefined: x.Gop_Add\n\thave foo__0()\n./gop_au"))
+	w.Write([]byte("togen.go:8:9: invalid operation\n./foo.go:3:1: undefined: foo__1\n\thave Gop_Add()\n"))
+	w.Write([]byte("./gop_autogen.go:3:8: \"fmt\" imported and not used\na.gop:7:3: x"))
+	w.Flush()
+	expected := `# main
+a.gop:5:6: undefined: x.operator +
+	have foo()
+a.gop:2:9: invalid operation
+./foo.go:3:1: undefined: foo__1
+	have Gop_Add()
+./gop_autogen.go:3:8: "fmt" imported and not used
+a.gop:7:3: x`
+	if ret := out.String(); ret != expected {
+		t.Fatalf("diagWriter:\n%s\nexpected:\n%s", ret, expected)
+	}
+
+	os.Remove(filepath.Join(dir, "a.gop"))
+	out.Reset()
+	w = newDiagWriter(&out, dir)
+	w.Write([]byte("./gop_autogen.go:13:7: undefined: x\n"))
+	if ret := out.String(); ret != "a.gop:5: undefined: x\n" { // no Go+ source, no column
+		t.Fatalf("diagWriter: %s", ret)
+	}
+}
+
+func TestDiagWriterSubpkg(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "sub", "foo")
+	os.MkdirAll(dir, 0777)
+	src := strings.ReplaceAll(autogen, "//line a.gop", "//line sub/foo/a.gop") // relative to the module root
+	files := map[string]string{
+		filepath.Join(root, "go.mod"):        "module example.com/foo\n",
+		filepath.Join(dir, "gop_autogen.go"): src,
+		filepath.Join(dir, "a.gop"):          gopSrc,
+	}
+	for file, data := range files {
+		if err := os.WriteFile(file, []byte(data), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cases := []struct{ dir, diag, expected string }{
+		{root, "sub/foo/gop_autogen.go:13:7: undefined: x\n", "sub/foo/a.gop:5:6: undefined: x\n"},
+		{dir, "./gop_autogen.go:13:7: undefined: x\n", "a.gop:5:6: undefined: x\n"},
+	}
+	for _, c := range cases {
+		var out strings.Builder
+		w := newDiagWriter(&out, c.dir)
+		w.Write([]byte(c.diag))
+		if ret := out.String(); ret != filepath.FromSlash(c.expected) {
+			t.Fatalf("diagWriter: %s, expected: %s", ret, c.expected)
+		}
+	}
+}
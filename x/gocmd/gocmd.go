@@ -53,11 +53,24 @@ func doWithArgs(dir, op string, conf *Config, args ...string) (err error) {
 	cmd.Dir = dir
 	run := conf.Run
 	if run == nil {
-		run = runCmd
+		run = runGoCmd
 	}
 	return run(cmd)
 }
 
+// runGoCmd runs a command of the Go toolchain, whose diagnostics are rewritten
+// into Go+ terms. The stderr of `go run` also holds the one of the app, whose
+// lines are kept unless they look like diagnostics of autogen files.
+func runGoCmd(cmd *exec.Cmd) (err error) {
+	stderr := newDiagWriter(os.Stderr, cmd.Dir)
+	cmd.Stdin = os.Stdin
+	cmd.Stderr = stderr
+	cmd.Stdout = os.Stdout
+	err = cmd.Run()
+	stderr.Flush()
+	return
+}
+
 func runCmd(cmd *exec.Cmd) (err error) {
 	cmd.Stdin = os.Stdin
 	cmd.Stderr = os.Stderr
@@ -0,0 +1,142 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package jsonrpc2
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+)
+
+const (
+	// MethodCancelRequest is the notification sent to cancel a call in flight
+	// on the peer. Its params are CancelParams.
+	MethodCancelRequest = "$/cancelRequest"
+
+	// MethodProgress is the notification sent by a handler to report the
+	// progress of a call. Its params are ProgressParams. It isn't LSP's
+	// $/progress, whose params are identified by a work done token.
+	MethodProgress = "$/callProgress"
+)
+
+// CancelParams is the params of a $/cancelRequest notification.
+type CancelParams struct {
+	ID any `json:"id"`
+}
+
+// ProgressParams is the params of a $/callProgress notification.
+type ProgressParams struct {
+	ID    any             `json:"id"`
+	Value json.RawMessage `json:"value"`
+}
+
+// ErrNotInCall is returned by Progress if ctx was not passed to the handler of
+// an incoming call.
+var ErrNotInCall = errors.New("jsonrpc2: context of no incoming call")
+
+// -----------------------------------------------------------------------------
+
+type progressKey struct{}
+type callKey struct{}
+
+// incomingCall identifies the incoming call whose handler a Context was
+// passed to.
+type incomingCall struct {
+	conn *Connection
+	id   ID
+}
+
+// WithProgress returns a copy of ctx which makes Connection.Call deliver the
+// $/callProgress notifications of the call to onProgress. onProgress is called
+// by the goroutine reading the connection, so it must not block.
+func WithProgress(ctx context.Context, onProgress func(value json.RawMessage)) context.Context {
+	return context.WithValue(ctx, progressKey{}, onProgress)
+}
+
+// Progress reports the progress of an incoming call to the caller, by sending
+// a $/callProgress notification with value. ctx is the Context passed to the
+// handler of the call.
+func Progress(ctx context.Context, value any) error {
+	call, ok := ctx.Value(callKey{}).(*incomingCall)
+	if !ok {
+		return ErrNotInCall
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return call.conn.Notify(ctx, MethodProgress, &ProgressParams{ID: call.id.value, Value: data})
+}
+
+// intercept handles the notifications of the cancellation and progress
+// protocols. It reports whether msg was handled.
+func (c *Connection) intercept(msg *Request) bool {
+	switch msg.Method {
+	case MethodCancelRequest:
+		var enabled bool
+		c.updateInFlight(func(s *inFlightState) {
+			enabled = s.cancelRequests
+		})
+		if !enabled {
+			return false
+		}
+		var params CancelParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			c.internalErrorf("%s: %v", msg.Method, err)
+			return true
+		}
+		if id, err := makeID(params.ID); err == nil && id.IsValid() {
+			c.Cancel(id)
+		}
+		return true
+	case MethodProgress:
+		var params ProgressParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return false
+		}
+		id, err := makeID(params.ID)
+		if err != nil || !id.IsValid() {
+			return false
+		}
+		var ac *AsyncCall
+		c.updateInFlight(func(s *inFlightState) {
+			ac = s.outgoingCalls[id]
+		})
+		if ac == nil || ac.onProgress == nil {
+			return false
+		}
+		ac.onProgress(params.Value)
+		return true
+	}
+	return false
+}
+
+// cancelOnDone sends a $/cancelRequest notification for ac to the peer if
+// ctx is done before ac gets its response.
+func (c *Connection) cancelOnDone(ctx context.Context, ac *AsyncCall) {
+	select {
+	case <-ctx.Done():
+		err := c.Notify(context.Background(), MethodCancelRequest, &CancelParams{ID: ac.id.value})
+		if debugCall {
+			log.Println("Connection.cancel", ac.id, err)
+		}
+	case <-ac.ready:
+	}
+}
+
+// -----------------------------------------------------------------------------
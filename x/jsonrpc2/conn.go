@@ -65,6 +65,11 @@ type ConnectionOptions struct {
 	// Handler is used as the queued message handler for inbound messages.
 	// If nil, all responses will be ErrNotHandled.
 	Handler Handler
+	// CancelRequests enables the $/cancelRequest protocol: a call is canceled on
+	// the peer when the Context passed to Call is done, and the deadline of that
+	// Context is sent along with the call. Incoming $/cancelRequest
+	// notifications cancel the Context passed to the handler of the call.
+	CancelRequests bool
 	// OnInternalError, if non-nil, is called with any internal errors that occur
 	// while serving the connection, such as protocol errors or invariant
 	// violations. (If nil, internal errors result in panics.)
@@ -108,6 +113,10 @@ type inFlightState struct {
 	closer   io.Closer
 	closeErr error // error returned from closer.Close
 
+	// cancelRequests is set from ConnectionOptions.CancelRequests. It is only
+	// known after Bind, which may start using the Connection already.
+	cancelRequests bool
+
 	outgoingCalls         map[ID]*AsyncCall // calls only
 	outgoingNotifications int               // # of notifications awaiting "write"
 
@@ -211,6 +220,25 @@ type incomingRequest struct {
 	*Request // the request being processed
 	ctx      context.Context
 	cancel   context.CancelFunc
+	batch    *incomingBatch // non-nil if the call arrived in a batch
+}
+
+// incomingBatch collects the responses to the calls of an incoming batch, so
+// that they can be written back as a single batch.
+type incomingBatch struct {
+	mu        sync.Mutex
+	pending   int // # of calls that have not yet produced a response
+	responses Batch
+}
+
+// add records a response and reports the full batch once the last pending
+// call has been answered.
+func (b *incomingBatch) add(response *Response) (Batch, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.responses = append(b.responses, response)
+	b.pending--
+	return b.responses, b.pending == 0
 }
 
 // newConnection creates a new connection and runs it.
@@ -266,6 +294,7 @@ func newConnection(bindCtx context.Context, rwc io.ReadWriteCloser, binder Binde
 		//
 		// (If the Binder closed the Connection already, this should error out and
 		// return almost immediately.)
+		s.cancelRequests = options.CancelRequests
 		s.reading = true
 		go c.readIncoming(ctx, reader, options.Preempter)
 	})
@@ -335,6 +364,7 @@ func (c *Connection) Call(ctx context.Context, method string, params any) *Async
 		id:    id,
 		ready: make(chan struct{}),
 	}
+	ac.onProgress, _ = ctx.Value(progressKey{}).(func(json.RawMessage))
 	// When this method returns, either ac is retired, or the request has been
 	// written successfully and the call is awaiting a response (to be provided by
 	// the readIncoming goroutine).
@@ -345,6 +375,7 @@ func (c *Connection) Call(ctx context.Context, method string, params any) *Async
 		return ac
 	}
 
+	var cancelRequests bool
 	c.updateInFlight(func(s *inFlightState) {
 		err = s.shuttingDown(ErrClientClosing)
 		if err != nil {
@@ -354,11 +385,15 @@ func (c *Connection) Call(ctx context.Context, method string, params any) *Async
 			s.outgoingCalls = make(map[ID]*AsyncCall)
 		}
 		s.outgoingCalls[ac.id] = ac
+		cancelRequests = s.cancelRequests
 	})
 	if err != nil {
 		ac.retire(&Response{ID: id, Error: err})
 		return ac
 	}
+	if cancelRequests {
+		call.Deadline, _ = ctx.Deadline()
+	}
 
 	err = c.write(ctx, call)
 	if debugCall {
@@ -377,14 +412,128 @@ func (c *Connection) Call(ctx context.Context, method string, params any) *Async
 				_ = 0
 			}
 		})
+	} else if cancelRequests && ctx.Done() != nil {
+		go c.cancelOnDone(ctx, ac)
 	}
 	return ac
 }
 
+// BatchCall describes one entry of a batch sent by Connection.Batch.
+type BatchCall struct {
+	Method string
+	Params any
+	// Notify sends the entry as a notification, which gets no response.
+	Notify bool
+}
+
+// Batch sends calls and notifications to the peer as a single batch message.
+// It returns one AsyncCall per entry of calls, in order; the entries for
+// notifications are nil.
+// If sending the batch failed, the responses will be ready and have the error
+// in them.
+func (c *Connection) Batch(ctx context.Context, calls ...BatchCall) []*AsyncCall {
+	if debugCall {
+		log.Println("Batch", len(calls), "calls")
+	}
+	acs := make([]*AsyncCall, len(calls))
+	batch := make(Batch, 0, len(calls))
+	notifications := 0
+	for i, bc := range calls {
+		if bc.Notify {
+			notify, err := NewNotification(bc.Method, bc.Params)
+			if err != nil {
+				return c.retireBatch(acs, fmt.Errorf("marshaling notify parameters: %w", err))
+			}
+			batch = append(batch, notify)
+			notifications++
+			continue
+		}
+		id := Int64ID(atomic.AddInt64(&c.seq, 1))
+		acs[i] = &AsyncCall{id: id, ready: make(chan struct{})}
+		call, err := NewCall(id, bc.Method, bc.Params)
+		if err != nil {
+			return c.retireBatch(acs, fmt.Errorf("marshaling call parameters: %w", err))
+		}
+		batch = append(batch, call)
+	}
+	if len(batch) == 0 {
+		return acs
+	}
+
+	var err error
+	var cancelRequests bool
+	c.updateInFlight(func(s *inFlightState) {
+		err = s.shuttingDown(ErrClientClosing)
+		if err != nil {
+			return
+		}
+		cancelRequests = s.cancelRequests
+		for _, ac := range acs {
+			if ac == nil {
+				continue
+			}
+			if s.outgoingCalls == nil {
+				s.outgoingCalls = make(map[ID]*AsyncCall)
+			}
+			s.outgoingCalls[ac.id] = ac
+		}
+		s.outgoingNotifications += notifications
+	})
+	if err != nil {
+		return c.retireBatch(acs, err)
+	}
+	if deadline, ok := ctx.Deadline(); ok && cancelRequests {
+		for _, msg := range batch {
+			if req := msg.(*Request); req.IsCall() {
+				req.Deadline = deadline
+			}
+		}
+	}
+
+	err = c.write(ctx, batch)
+	if debugCall {
+		log.Println("Connection.write batch", len(batch), err)
+	}
+	c.updateInFlight(func(s *inFlightState) {
+		s.outgoingNotifications -= notifications
+		if err == nil {
+			if cancelRequests && ctx.Done() != nil {
+				for _, ac := range acs {
+					if ac != nil {
+						go c.cancelOnDone(ctx, ac)
+					}
+				}
+			}
+			return
+		}
+		// Sending failed, so deliver fake responses to the calls that were not
+		// already retired by the connection breaking.
+		for _, ac := range acs {
+			if ac != nil && s.outgoingCalls[ac.id] == ac {
+				delete(s.outgoingCalls, ac.id)
+				ac.retire(&Response{ID: ac.id, Error: err})
+			}
+		}
+	})
+	return acs
+}
+
+// retireBatch retires all calls of a batch that could not be sent.
+func (c *Connection) retireBatch(acs []*AsyncCall, err error) []*AsyncCall {
+	for _, ac := range acs {
+		if ac != nil {
+			ac.retire(&Response{ID: ac.id, Error: err})
+		}
+	}
+	return acs
+}
+
 type AsyncCall struct {
 	id       ID
 	ready    chan struct{} // closed after response has been set
 	response *Response
+
+	onProgress func(value json.RawMessage) // see WithProgress
 }
 
 // ID used for this call.
@@ -527,24 +676,13 @@ func (c *Connection) readIncoming(ctx context.Context, reader Reader, preempter
 
 		switch msg := msg.(type) {
 		case *Request:
-			c.acceptRequest(ctx, msg, n, preempter)
+			c.acceptRequest(ctx, msg, n, nil, preempter)
 
 		case *Response:
-			if Verbose {
-				log.Println("==> readIncoming Response:", msg.ID)
-			}
-			c.updateInFlight(func(s *inFlightState) {
-				if ac, ok := s.outgoingCalls[msg.ID]; ok {
-					delete(s.outgoingCalls, msg.ID)
-					ac.retire(msg)
-				} else {
-					// TODO: How should we report unexpected responses?
-					_ = 0
-				}
-			})
-			if Verbose {
-				log.Println("==> readIncoming: updateInFlight -", msg.ID)
-			}
+			c.acceptResponse(msg)
+
+		case Batch:
+			c.acceptBatch(ctx, msg, n, preempter)
 
 		default:
 			c.internalErrorf("Read returned an unexpected message of type %T", msg)
@@ -567,16 +705,87 @@ func (c *Connection) readIncoming(ctx context.Context, reader Reader, preempter
 	})
 }
 
+// acceptResponse delivers a response to the outgoing call it answers.
+func (c *Connection) acceptResponse(msg *Response) {
+	if Verbose {
+		log.Println("==> readIncoming Response:", msg.ID)
+	}
+	c.updateInFlight(func(s *inFlightState) {
+		if ac, ok := s.outgoingCalls[msg.ID]; ok {
+			delete(s.outgoingCalls, msg.ID)
+			ac.retire(msg)
+		} else {
+			// TODO: How should we report unexpected responses?
+			_ = 0
+		}
+	})
+	if Verbose {
+		log.Println("==> readIncoming: updateInFlight -", msg.ID)
+	}
+}
+
+// acceptBatch accepts each message of an incoming batch. The responses to the
+// calls in the batch are written back as a single batch.
+func (c *Connection) acceptBatch(ctx context.Context, msgs Batch, msgBytes int64, preempter Preempter) {
+	var batch *incomingBatch
+	for _, msg := range msgs { // calls and invalid entries are answered
+		switch msg := msg.(type) {
+		case *Request:
+			if !msg.IsCall() {
+				continue
+			}
+		case *invalidEntry:
+		default:
+			continue
+		}
+		if batch == nil {
+			batch = new(incomingBatch)
+		}
+		batch.pending++
+	}
+	for _, msg := range msgs {
+		switch msg := msg.(type) {
+		case *Request:
+			if msg.IsCall() {
+				c.acceptRequest(ctx, msg, msgBytes, batch, preempter)
+			} else {
+				c.acceptRequest(ctx, msg, msgBytes, nil, preempter)
+			}
+		case *Response:
+			c.acceptResponse(msg)
+		case *invalidEntry: // answered with an error response in the batch
+			if responses, complete := batch.add(&Response{ID: msg.id, Error: msg.err}); complete {
+				c.write(notDone{ctx}, responses)
+			}
+		default:
+			c.internalErrorf("Read returned an unexpected message of type %T in a batch", msg)
+		}
+	}
+}
+
 // acceptRequest either handles msg synchronously or enqueues it to be handled
 // asynchronously.
-func (c *Connection) acceptRequest(ctx context.Context, msg *Request, msgBytes int64, preempter Preempter) {
+func (c *Connection) acceptRequest(ctx context.Context, msg *Request, msgBytes int64, batch *incomingBatch, preempter Preempter) {
+	if !msg.IsCall() && c.intercept(msg) {
+		return
+	}
+
 	// In theory notifications cannot be cancelled, but we build them a cancel
 	// context anyway.
-	ctx, cancel := context.WithCancel(ctx)
+	var cancel context.CancelFunc
+	if msg.Deadline.IsZero() {
+		ctx, cancel = context.WithCancel(ctx)
+	} else {
+		ctx, cancel = context.WithDeadline(ctx, msg.Deadline)
+	}
+	if msg.IsCall() {
+		ctx = context.WithValue(ctx, callKey{}, &incomingCall{conn: c, id: msg.ID})
+	}
 	req := &incomingRequest{
 		Request: msg,
 		ctx:     ctx,
 		cancel:  cancel,
+		batch:   batch,
 	}
 
 	// If the request is a call, add it to the incoming map so it can be
@@ -722,7 +931,9 @@ func (c *Connection) processResult(from any, req *incomingRequest, result any, e
 		result = nil // Discard the spurious result and respond with err.
 	}
 
-	if req.IsCall() {
+	if req.IsCall() || req.batch != nil {
+		// A call of a batch always gets a response, even if its ID was rejected
+		// as a duplicate, or the batch would never be complete.
 		response, respErr := NewResponse(req.ID, result, err)
 		if debugCall {
 			log.Println("processResult", response.ID, string(response.Result), response.Error)
@@ -734,13 +945,24 @@ func (c *Connection) processResult(from any, req *incomingRequest, result any, e
 		c.updateInFlight(func(s *inFlightState) {
 			delete(s.incomingByID, req.ID)
 		})
-		if respErr == nil {
-			writeErr := c.write(notDone{req.ctx}, response)
+		if respErr != nil {
+			err = c.internalErrorf("%#v returned a malformed result for %q: %w", from, req.Method, respErr)
+			response = &Response{ID: req.ID, Error: err}
+		}
+		var msg Message
+		switch {
+		case req.batch != nil:
+			if batch, complete := req.batch.add(response); complete {
+				msg = batch
+			}
+		case respErr == nil:
+			msg = response
+		}
+		if msg != nil {
+			writeErr := c.write(notDone{req.ctx}, msg)
 			if err == nil {
 				err = writeErr
 			}
-		} else {
-			err = c.internalErrorf("%#v returned a malformed result for %q: %w", from, req.Method, respErr)
 		}
 	} else { // req is a notification
 		if result != nil {
@@ -22,7 +22,9 @@ package jsonrpc2
 
 import (
 	"bufio"
+	"bytes"
 	"context"
+	"encoding/binary"
 	"fmt"
 	"io"
 	"strconv"
@@ -151,3 +153,152 @@ func (w *headerWriter) Write(ctx context.Context, msg Message) (int64, error) {
 	}
 	return total, err
 }
+
+// NDJSONFramer returns a new Framer.
+// The messages are sent as newline-delimited JSON: each message is encoded on
+// a single line, and empty lines between messages are ignored.
+func NDJSONFramer() Framer { return ndjsonFramer{} }
+
+type ndjsonFramer struct{}
+type ndjsonReader struct{ in *bufio.Reader }
+type ndjsonWriter struct{ out io.Writer }
+
+func (ndjsonFramer) Reader(rw io.Reader) Reader {
+	return &ndjsonReader{in: bufio.NewReader(rw)}
+}
+
+func (ndjsonFramer) Writer(rw io.Writer) Writer {
+	return &ndjsonWriter{out: rw}
+}
+
+func (r *ndjsonReader) Read(ctx context.Context) (Message, int64, error) {
+	select {
+	case <-ctx.Done():
+		return nil, 0, ctx.Err()
+	default:
+	}
+	var total int64
+	for {
+		line, err := r.in.ReadBytes('\n')
+		total += int64(len(line))
+		data := bytes.TrimSpace(line)
+		if len(data) == 0 {
+			if err != nil {
+				return nil, total, err
+			}
+			continue
+		}
+		if err != nil && err != io.EOF {
+			return nil, total, err
+		}
+		// the last message of a stream may omit its newline
+		msg, err := DecodeMessage(data)
+		return msg, total, err
+	}
+}
+
+func (w *ndjsonWriter) Write(ctx context.Context, msg Message) (int64, error) {
+	select {
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	default:
+	}
+	data, err := EncodeMessage(msg)
+	if err != nil {
+		return 0, fmt.Errorf("marshaling message: %v", err)
+	}
+	n, err := w.out.Write(append(data, '\n'))
+	return int64(n), err
+}
+
+// LengthPrefixFramer returns a new Framer.
+// Each message is preceded by its length, encoded as a 4-byte big-endian
+// unsigned integer. Unlike the other framers it is safe for any payload.
+func LengthPrefixFramer() Framer { return lengthPrefixFramer{} }
+
+type lengthPrefixFramer struct{}
+type lengthPrefixReader struct{ in *bufio.Reader }
+type lengthPrefixWriter struct{ out io.Writer }
+
+const (
+	lengthPrefixSize = 4
+
+	// maxMessageSize is the maximum length of a message read by
+	// LengthPrefixFramer, so that a corrupted or hostile length prefix can't
+	// make it allocate up to 4GB.
+	maxMessageSize = 64 << 20
+)
+
+func (lengthPrefixFramer) Reader(rw io.Reader) Reader {
+	return &lengthPrefixReader{in: bufio.NewReader(rw)}
+}
+
+func (lengthPrefixFramer) Writer(rw io.Writer) Writer {
+	return &lengthPrefixWriter{out: rw}
+}
+
+func (r *lengthPrefixReader) Read(ctx context.Context) (Message, int64, error) {
+	select {
+	case <-ctx.Done():
+		return nil, 0, ctx.Err()
+	default:
+	}
+	var prefix [lengthPrefixSize]byte
+	n, err := io.ReadFull(r.in, prefix[:])
+	total := int64(n)
+	if err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, total, fmt.Errorf("failed reading length prefix: %w", err)
+		}
+		return nil, total, err
+	}
+	length := binary.BigEndian.Uint32(prefix[:])
+	if length == 0 {
+		return nil, total, fmt.Errorf("invalid length prefix: %v", length)
+	}
+	if length > maxMessageSize {
+		return nil, total, fmt.Errorf("message too large: %v bytes, the limit is %v", length, maxMessageSize)
+	}
+	data := make([]byte, length)
+	n, err = io.ReadFull(r.in, data)
+	total += int64(n)
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, total, err
+	}
+	msg, err := DecodeMessage(data)
+	return msg, total, err
+}
+
+func (w *lengthPrefixWriter) Write(ctx context.Context, msg Message) (int64, error) {
+	select {
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	default:
+	}
+	data, err := EncodeMessage(msg)
+	if err != nil {
+		return 0, fmt.Errorf("marshaling message: %v", err)
+	}
+	buf := make([]byte, lengthPrefixSize, lengthPrefixSize+len(data))
+	binary.BigEndian.PutUint32(buf, uint32(len(data)))
+	n, err := w.out.Write(append(buf, data...))
+	return int64(n), err
+}
+
+// FramerByName returns the Framer with the specified name: "header" (or
+// empty) for HeaderFramer, "ndjson" for NDJSONFramer and "length" for
+// LengthPrefixFramer.
+func FramerByName(name string) (Framer, error) {
+	switch name {
+	case "", "header":
+		return HeaderFramer(), nil
+	case "ndjson":
+		return NDJSONFramer(), nil
+	case "length":
+		return LengthPrefixFramer(), nil
+	}
+	return nil, fmt.Errorf("unknown framing %q: expected header, ndjson or length", name)
+}
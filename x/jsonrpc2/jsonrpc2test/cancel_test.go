@@ -0,0 +1,139 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package jsonrpc2test_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/goplus/gop/x/jsonrpc2"
+	"github.com/goplus/gop/x/jsonrpc2/jsonrpc2test"
+)
+
+func dialRouter(t *testing.T, router *jsonrpc2.Router) *jsonrpc2.Connection {
+	ctx := context.Background()
+	listener := jsonrpc2test.NetPipeListener()
+	server := jsonrpc2.NewServer(ctx, listener, jsonrpc2.BinderFunc(
+		func(context.Context, *jsonrpc2.Connection) jsonrpc2.ConnectionOptions {
+			return jsonrpc2.ConnectionOptions{Handler: router, CancelRequests: true}
+		}))
+	conn, err := jsonrpc2.Dial(ctx, listener.Dialer(), jsonrpc2.BinderFunc(
+		func(context.Context, *jsonrpc2.Connection) jsonrpc2.ConnectionOptions {
+			return jsonrpc2.ConnectionOptions{CancelRequests: true}
+		}), nil)
+	if err != nil {
+		t.Fatal("Dial:", err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+		listener.Close()
+		server.Wait()
+	})
+	return conn
+}
+
+func TestCancelRequest(t *testing.T) {
+	started := make(chan struct{})
+	canceled := make(chan error, 1)
+	r := jsonrpc2.NewRouter()
+	jsonrpc2.Register(r, "block", func(ctx context.Context, _ struct{}) (bool, error) {
+		close(started)
+		select {
+		case <-ctx.Done():
+			canceled <- ctx.Err()
+			return false, ctx.Err()
+		case <-time.After(5 * time.Second):
+			return true, nil
+		}
+	})
+	conn := dialRouter(t, r)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ac := conn.Call(ctx, "block", nil)
+	<-started
+	cancel()
+	select {
+	case err := <-canceled:
+		if err != context.Canceled {
+			t.Fatal("handler context:", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("$/cancelRequest didn't cancel the handler")
+	}
+	if err := ac.Await(context.Background(), nil); err == nil {
+		t.Fatal("Await: expected an error")
+	}
+}
+
+func TestDeadline(t *testing.T) {
+	r := jsonrpc2.NewRouter()
+	jsonrpc2.Register(r, "deadline", func(ctx context.Context, _ struct{}) (time.Time, error) {
+		d, ok := ctx.Deadline()
+		if !ok {
+			return d, errors.New("no deadline")
+		}
+		return d, nil
+	})
+	conn := dialRouter(t, r)
+
+	deadline := time.Now().Add(time.Hour)
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+	var got time.Time
+	if err := conn.Call(ctx, "deadline", nil).Await(ctx, &got); err != nil {
+		t.Fatal("deadline:", err)
+	}
+	if d := got.Sub(deadline); d < -time.Second || d > time.Second { // sent as the time remaining
+		t.Fatal("deadline:", got, "expected", deadline)
+	}
+	if err := conn.Call(context.Background(), "deadline", nil).Await(ctx, &got); err == nil {
+		t.Fatal("deadline: expected no deadline")
+	}
+}
+
+func TestProgress(t *testing.T) {
+	r := jsonrpc2.NewRouter()
+	jsonrpc2.Register(r, "count", func(ctx context.Context, n int) (int, error) {
+		for i := 1; i <= n; i++ {
+			if err := jsonrpc2.Progress(ctx, i); err != nil {
+				return 0, err
+			}
+		}
+		return n, nil
+	})
+	conn := dialRouter(t, r)
+
+	var got []int
+	ctx := jsonrpc2.WithProgress(context.Background(), func(value json.RawMessage) {
+		var i int
+		json.Unmarshal(value, &i)
+		got = append(got, i)
+	})
+	var n int
+	if err := conn.Call(ctx, "count", 3).Await(ctx, &n); err != nil || n != 3 {
+		t.Fatal("count:", n, err)
+	}
+	if len(got) != 3 || got[0] != 1 || got[2] != 3 {
+		t.Fatal("progress:", got)
+	}
+	if err := jsonrpc2.Progress(context.Background(), 1); err != jsonrpc2.ErrNotInCall {
+		t.Fatal("Progress:", err)
+	}
+}
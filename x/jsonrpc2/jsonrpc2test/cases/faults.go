@@ -0,0 +1,191 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cases
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/goplus/gop/x/fakenet"
+	"github.com/goplus/gop/x/jsonrpc2"
+	"github.com/goplus/gop/x/jsonrpc2/jsonrpc2test"
+)
+
+// hangTimeout is how long a scenario waits for something that must happen.
+const hangTimeout = 3 * time.Second
+
+// scenario runs against a fakenet listener whose faults are made by conf from
+// the number of writes the framer uses per message.
+type scenario struct {
+	name string
+	conf func(perMsg int) fakenet.Config
+	run  func(t *testing.T, ctx context.Context, env *faultEnv)
+}
+
+type faultEnv struct {
+	framer   jsonrpc2.Framer
+	listener jsonrpc2.Listener
+}
+
+var faultScenarios = []scenario{
+	{"latency", func(int) fakenet.Config {
+		return fakenet.Config{Latency: time.Millisecond, ReadChunk: 5}
+	}, func(t *testing.T, ctx context.Context, env *faultEnv) {
+		c := env.dial(t, ctx)
+		defer c.Close()
+		for i := 0; i < 3; i++ {
+			var ret string
+			if err := awaitCall(t, c, "join", []string{"a", "b"}, &ret); err != nil || ret != "a/b" {
+				t.Fatal("join:", ret, err)
+			}
+		}
+	}},
+	{"dropped message", func(perMsg int) fakenet.Config {
+		return fakenet.Config{Client: writes(fakenet.FaultDrop, 1, perMsg)}
+	}, func(t *testing.T, ctx context.Context, env *faultEnv) {
+		c := env.dial(t, ctx)
+		defer c.Close()
+		// the first notification is lost, but the stream is still in sync
+		if err := c.Notify(ctx, "set", 5); err != nil {
+			t.Fatal("set:", err)
+		}
+		if err := c.Notify(ctx, "add", 2); err != nil {
+			t.Fatal("add:", err)
+		}
+		var ret int
+		if err := awaitCall(t, c, "get", nil, &ret); err != nil || ret != 2 {
+			t.Fatal("get:", ret, err)
+		}
+	}},
+	{"short write", func(int) fakenet.Config {
+		return fakenet.Config{Server: writes(fakenet.FaultShortWrite, 1, 1)}
+	}, func(t *testing.T, ctx context.Context, env *faultEnv) {
+		c := env.dial(t, ctx)
+		if err := awaitCall(t, c, "no_args", nil, nil); err == nil {
+			t.Fatal("no_args: expected an error")
+		}
+		waitDone(t, c)
+	}},
+	{"abrupt close", func(int) fakenet.Config {
+		return fakenet.Config{Server: writes(fakenet.FaultClose, 1, 1)}
+	}, func(t *testing.T, ctx context.Context, env *faultEnv) {
+		c := env.dial(t, ctx)
+		if err := awaitCall(t, c, "no_args", nil, nil); err == nil {
+			t.Fatal("no_args: expected an error")
+		}
+		waitDone(t, c)
+	}},
+	{"half close", func(perMsg int) fakenet.Config {
+		return fakenet.Config{Client: writes(fakenet.FaultCloseWrite, perMsg, perMsg)}
+	}, func(t *testing.T, ctx context.Context, env *faultEnv) {
+		c := env.dial(t, ctx)
+		// the call in flight is still answered after the client half-closed
+		var ret string
+		if err := awaitCall(t, c, "one_string", "fish", &ret); err != nil || ret != "got:fish" {
+			t.Fatal("one_string:", ret, err)
+		}
+		waitDone(t, c)
+	}},
+	{"reconnect", func(perMsg int) fakenet.Config {
+		return fakenet.Config{Server: writes(fakenet.FaultClose, perMsg+1, perMsg+1)}
+	}, func(t *testing.T, ctx context.Context, env *faultEnv) {
+		for i := 0; i < 2; i++ {
+			c := env.dial(t, ctx)
+			if err := awaitCall(t, c, "no_args", nil, nil); err != nil {
+				t.Fatal("no_args:", i, err)
+			}
+			if err := awaitCall(t, c, "no_args", nil, nil); err == nil {
+				t.Fatal("no_args: expected an error", i)
+			}
+			waitDone(t, c)
+		}
+	}},
+}
+
+// Faults runs the fault injection scenarios with framer.
+func Faults(t *testing.T, ctx context.Context, framer jsonrpc2.Framer) {
+	perMsg := writesPerMessage(framer)
+	for _, sc := range faultScenarios {
+		t.Run(sc.name, func(t *testing.T) {
+			conf := sc.conf(perMsg)
+			listener := jsonrpc2test.FakeNetListener(&conf)
+			server := jsonrpc2.NewServer(ctx, listener, binder{framer, nil})
+			defer func() {
+				listener.Close()
+				server.Wait()
+			}()
+			sc.run(t, ctx, &faultEnv{framer: framer, listener: listener})
+		})
+	}
+}
+
+func (env *faultEnv) dial(t *testing.T, ctx context.Context) *jsonrpc2.Connection {
+	c, err := jsonrpc2.Dial(ctx, env.listener.Dialer(), binder{env.framer, nil}, nil)
+	if err != nil {
+		t.Fatal("Dial:", err)
+	}
+	return c
+}
+
+// writes schedules fault on the writes from..to.
+func writes(fault fakenet.Fault, from, to int) (ret []fakenet.Event) {
+	for i := from; i <= to; i++ {
+		ret = append(ret, fakenet.Event{Write: i, Fault: fault})
+	}
+	return
+}
+
+// awaitCall calls method and fails the test if the call hangs.
+func awaitCall(t *testing.T, c *jsonrpc2.Connection, method string, params, result any) error {
+	ctx, cancel := context.WithTimeout(context.Background(), hangTimeout)
+	defer cancel()
+	err := c.Call(context.Background(), method, params).Await(ctx, result)
+	if err == context.DeadlineExceeded {
+		t.Fatalf("%v: call hangs", method)
+	}
+	return err
+}
+
+// waitDone fails the test if c is not closed soon.
+func waitDone(t *testing.T, c *jsonrpc2.Connection) {
+	done := make(chan struct{})
+	go func() {
+		c.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(hangTimeout):
+		t.Fatal("connection is not closed")
+	}
+}
+
+type writeCounter int
+
+func (n *writeCounter) Write(b []byte) (int, error) {
+	*n++
+	return len(b), nil
+}
+
+// writesPerMessage returns how many writes framer uses to send a message.
+func writesPerMessage(framer jsonrpc2.Framer) int {
+	var n writeCounter
+	msg, _ := jsonrpc2.NewNotification("count", nil)
+	framer.Writer(&n).Write(context.Background(), msg)
+	return int(n)
+}
@@ -60,6 +60,20 @@ var callTests = []invoker{
 		notify{"unblock", "a"},
 		collect{"a", true, false},
 	}},
+	batch{"batch", []jsonrpc2.BatchCall{
+		{Method: "one_string", Params: "fish"},
+		{Method: "set", Params: 3, Notify: true},
+		{Method: "add", Params: 5, Notify: true},
+		{Method: "get"},
+		{Method: "join", Params: []string{"a", "b"}},
+	}, []any{"got:fish", nil, nil, 8, "a/b"}},
+	sequence{"batch fork", []invoker{
+		batch{"fork", []jsonrpc2.BatchCall{
+			{Method: "fork", Params: "a"},
+			{Method: "unblock", Params: "a", Notify: true},
+			{Method: "no_args"},
+		}, []any{true, nil, true}},
+	}},
 	sequence{"concurrent", []invoker{
 		async{"a", "fork", "a"},
 		notify{"unblock", "a"},
@@ -117,6 +131,12 @@ type cancel struct {
 	name string
 }
 
+type batch struct {
+	name   string
+	calls  []jsonrpc2.BatchCall
+	expect []any // nil for notifications
+}
+
 type sequence struct {
 	name  string
 	tests []invoker
@@ -208,6 +228,28 @@ func (test cancel) Invoke(t *testing.T, ctx context.Context, h *handler) {
 	}
 }
 
+func (test batch) Name() string { return test.name }
+func (test batch) Invoke(t *testing.T, ctx context.Context, h *handler) {
+	acs := h.conn.Batch(ctx, test.calls...)
+	if len(acs) != len(test.calls) {
+		t.Fatalf("%v:Batch returned %d calls, expected %d", test.name, len(acs), len(test.calls))
+	}
+	for i, ac := range acs {
+		method := test.calls[i].Method
+		if test.calls[i].Notify {
+			if ac != nil {
+				t.Fatalf("%v:Batch returned a call for notification %v", test.name, method)
+			}
+			continue
+		}
+		results := newResults(test.expect[i])
+		if err := ac.Await(ctx, results); err != nil {
+			t.Fatalf("%v:Batch %v failed: %v", test.name, method, err)
+		}
+		verifyResults(t, method, results, test.expect[i])
+	}
+}
+
 func (test sequence) Name() string { return test.name }
 func (test sequence) Invoke(t *testing.T, ctx context.Context, h *handler) {
 	for _, child := range test.tests {
@@ -5,9 +5,17 @@
 package jsonrpc2test_test
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/goplus/gop/x/fakenet"
 	"github.com/goplus/gop/x/jsonrpc2"
 	"github.com/goplus/gop/x/jsonrpc2/jsonrpc2test"
 	"github.com/goplus/gop/x/jsonrpc2/jsonrpc2test/cases"
@@ -19,3 +27,233 @@ func TestNetPipe(t *testing.T) {
 	listener := jsonrpc2test.NetPipeListener()
 	cases.Test(t, ctx, listener, jsonrpc2.HeaderFramer(), true)
 }
+
+func TestNetListener(t *testing.T) {
+	ctx := context.Background()
+	listener, err := jsonrpc2.NetListener(ctx, "tcp", "127.0.0.1:0", jsonrpc2.NetListenOptions{})
+	if err != nil {
+		t.Fatal("jsonrpc2.NetListener:", err)
+	}
+	cases.Test(t, ctx, listener, jsonrpc2.HeaderFramer(), true)
+}
+
+func TestNDJSONFramer(t *testing.T) {
+	ctx := context.Background()
+	listener := jsonrpc2test.NetPipeListener()
+	cases.Test(t, ctx, listener, jsonrpc2.NDJSONFramer(), true)
+}
+
+func TestLengthPrefixFramer(t *testing.T) {
+	ctx := context.Background()
+	listener := jsonrpc2test.NetPipeListener()
+	cases.Test(t, ctx, listener, jsonrpc2.LengthPrefixFramer(), true)
+}
+
+func TestFakeNet(t *testing.T) {
+	ctx := context.Background()
+	listener := jsonrpc2test.FakeNetListener(&fakenet.Config{Latency: time.Millisecond, ReadChunk: 16})
+	cases.Test(t, ctx, listener, jsonrpc2.HeaderFramer(), true)
+}
+
+func TestFaults(t *testing.T) {
+	ctx := context.Background()
+	for _, name := range []string{"header", "ndjson", "length"} {
+		framer, _ := jsonrpc2.FramerByName(name)
+		t.Run(name, func(t *testing.T) {
+			cases.Faults(t, ctx, framer)
+		})
+	}
+}
+
+func TestFramerByName(t *testing.T) {
+	for _, name := range []string{"", "header", "ndjson", "length"} {
+		if _, err := jsonrpc2.FramerByName(name); err != nil {
+			t.Fatal("FramerByName:", err)
+		}
+	}
+	if _, err := jsonrpc2.FramerByName("xml"); err == nil {
+		t.Fatal("FramerByName: unknown framing should fail")
+	}
+}
+
+func TestFramerStream(t *testing.T) {
+	ctx := context.Background()
+	for _, framer := range []jsonrpc2.Framer{
+		jsonrpc2.HeaderFramer(), jsonrpc2.NDJSONFramer(), jsonrpc2.LengthPrefixFramer(),
+	} {
+		var buf bytes.Buffer
+		w := framer.Writer(&buf)
+		msgs := []jsonrpc2.Message{
+			mustDecode(t, `{"jsonrpc":"2.0","id":1,"method":"a","params":"line1\nline2"}`),
+			mustDecode(t, `{"jsonrpc":"2.0","method":"b"}`),
+			mustDecode(t, `[{"jsonrpc":"2.0","id":"x","result":{"v":[1,2]}}]`),
+		}
+		var want []string
+		for _, msg := range msgs {
+			if _, err := w.Write(ctx, msg); err != nil {
+				t.Fatalf("%T.Write: %v", framer, err)
+			}
+			data, _ := jsonrpc2.EncodeMessage(msg)
+			want = append(want, string(data))
+		}
+		r := framer.Reader(&buf)
+		for _, expect := range want {
+			msg, _, err := r.Read(ctx)
+			if err != nil {
+				t.Fatalf("%T.Read: %v", framer, err)
+			}
+			if data, _ := jsonrpc2.EncodeMessage(msg); string(data) != expect {
+				t.Fatalf("%T.Read: got %s, expected %s", framer, data, expect)
+			}
+		}
+		if _, _, err := r.Read(ctx); err != io.EOF {
+			t.Fatalf("%T.Read: expected io.EOF, got %v", framer, err)
+		}
+	}
+}
+
+func TestLengthPrefixTooLarge(t *testing.T) {
+	r := jsonrpc2.LengthPrefixFramer().Reader(bytes.NewReader([]byte{0xff, 0xff, 0xff, 0xff, '{', '}'}))
+	if _, _, err := r.Read(context.Background()); err == nil || !strings.Contains(err.Error(), "too large") {
+		t.Fatal("Read: expected a message too large error, got", err)
+	}
+}
+
+func TestBatchWire(t *testing.T) {
+	ctx := context.Background()
+	listener := jsonrpc2test.NetPipeListener()
+	handler := jsonrpc2.HandlerFunc(func(ctx context.Context, req *jsonrpc2.Request) (any, error) {
+		if req.Method == "twice" {
+			var v int
+			if err := json.Unmarshal(req.Params, &v); err != nil {
+				return nil, err
+			}
+			if !req.IsCall() {
+				return nil, nil
+			}
+			return v * 2, nil
+		}
+		return nil, jsonrpc2.ErrNotHandled
+	})
+	server := jsonrpc2.NewServer(ctx, listener, jsonrpc2.BinderFunc(
+		func(context.Context, *jsonrpc2.Connection) jsonrpc2.ConnectionOptions {
+			return jsonrpc2.ConnectionOptions{Handler: handler}
+		}))
+	defer func() {
+		listener.Close()
+		server.Wait()
+	}()
+
+	rwc, err := listener.Dialer().Dial(ctx)
+	if err != nil {
+		t.Fatal("Dial:", err)
+	}
+	defer rwc.Close()
+	framer := jsonrpc2.HeaderFramer()
+	w, r := framer.Writer(rwc), framer.Reader(rwc)
+	go w.Write(ctx, mustDecode(t, `[
+		{"jsonrpc":"2.0","id":1,"method":"twice","params":2},
+		{"jsonrpc":"2.0","method":"twice","params":5},
+		{"jsonrpc":"2.0","id":"x","method":"unknown"},
+		{"jsonrpc":"2.0","id":3,"method":"twice","params":21}
+	]`))
+	msg, _, err := r.Read(ctx)
+	if err != nil {
+		t.Fatal("Read:", err)
+	}
+	batch, ok := msg.(jsonrpc2.Batch)
+	if !ok || len(batch) != 3 {
+		t.Fatalf("expected a batch of 3 responses, got %#v", msg)
+	}
+	results := make(map[any]string)
+	for _, m := range batch {
+		resp := m.(*jsonrpc2.Response)
+		if resp.Error != nil {
+			results[resp.ID.Raw()] = "error"
+		} else {
+			results[resp.ID.Raw()] = string(resp.Result)
+		}
+	}
+	if results[int64(1)] != "4" || results[int64(3)] != "42" || results["x"] != "error" {
+		t.Fatal("unexpected batch responses:", results)
+	}
+}
+
+func TestBatchInvalidEntry(t *testing.T) {
+	ctx := context.Background()
+	listener := jsonrpc2test.NetPipeListener()
+	handler := jsonrpc2.HandlerFunc(func(ctx context.Context, req *jsonrpc2.Request) (any, error) {
+		return req.Method, nil
+	})
+	server := jsonrpc2.NewServer(ctx, listener, jsonrpc2.BinderFunc(
+		func(context.Context, *jsonrpc2.Connection) jsonrpc2.ConnectionOptions {
+			return jsonrpc2.ConnectionOptions{Handler: handler}
+		}))
+	defer func() {
+		listener.Close()
+		server.Wait()
+	}()
+
+	rwc, err := listener.Dialer().Dial(ctx)
+	if err != nil {
+		t.Fatal("Dial:", err)
+	}
+	defer rwc.Close()
+	data := `[
+		{"jsonrpc":"2.0","id":1,"method":"a"},
+		{"jsonrpc":"2.0","id":2,"method":3},
+		{"jsonrpc":"1.0","id":3,"method":"c"},
+		4,
+		{"jsonrpc":"2.0","id":5,"method":"e"}
+	]`
+	go fmt.Fprintf(rwc, "Content-Length: %d\r\n\r\n%s", len(data), data)
+	msg, _, err := jsonrpc2.HeaderFramer().Reader(rwc).Read(ctx)
+	if err != nil {
+		t.Fatal("Read:", err)
+	}
+	batch, ok := msg.(jsonrpc2.Batch)
+	if !ok || len(batch) != 5 {
+		t.Fatalf("expected a batch of 5 responses, got %#v", msg)
+	}
+	results := make(map[any]string)
+	for _, m := range batch {
+		resp := m.(*jsonrpc2.Response)
+		if resp.Error != nil {
+			if !errors.Is(resp.Error, jsonrpc2.ErrInvalidRequest) {
+				t.Fatal("unexpected error:", resp.Error)
+			}
+			results[resp.ID.Raw()] += "invalid"
+		} else {
+			results[resp.ID.Raw()] += string(resp.Result)
+		}
+	}
+	if results[int64(1)] != `"a"` || results[int64(2)] != "invalid" || results[int64(3)] != "invalid" ||
+		results[nil] != "invalid" || results[int64(5)] != `"e"` {
+		t.Fatal("unexpected batch responses:", results)
+	}
+}
+
+func TestBatchDecode(t *testing.T) {
+	if _, err := jsonrpc2.DecodeMessage([]byte(" []")); err == nil {
+		t.Fatal("DecodeMessage: empty batch should fail")
+	}
+	if _, err := jsonrpc2.EncodeMessage(jsonrpc2.Batch{}); err == nil {
+		t.Fatal("EncodeMessage: empty batch should fail")
+	}
+	msg := mustDecode(t, `[{"jsonrpc":"2.0","id":1,"result":true}]`)
+	data, err := jsonrpc2.EncodeMessage(msg)
+	if err != nil {
+		t.Fatal("EncodeMessage:", err)
+	}
+	if string(data) != `[{"jsonrpc":"2.0","id":1,"result":true}]` {
+		t.Fatal("EncodeMessage:", string(data))
+	}
+}
+
+func mustDecode(t *testing.T, data string) jsonrpc2.Message {
+	msg, err := jsonrpc2.DecodeMessage([]byte(data))
+	if err != nil {
+		t.Fatal("DecodeMessage:", err)
+	}
+	return msg
+}
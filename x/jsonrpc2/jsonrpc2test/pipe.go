@@ -25,6 +25,7 @@ import (
 	"io"
 	"net"
 
+	"github.com/goplus/gop/x/fakenet"
 	"github.com/goplus/gop/x/jsonrpc2"
 )
 
@@ -87,3 +88,17 @@ func (l *netPiper) Dial(ctx context.Context) (io.ReadWriteCloser, error) {
 		return nil, net.ErrClosed
 	}
 }
+
+// FakeNetListener returns a new Listener built on fakenet.Listen, whose
+// connections inject the faults scheduled by conf.
+func FakeNetListener(conf *fakenet.Config) jsonrpc2.Listener {
+	return fakeNetListener{fakenet.Listen(conf)}
+}
+
+type fakeNetListener struct {
+	*fakenet.Listener
+}
+
+func (l fakeNetListener) Dialer() jsonrpc2.Dialer {
+	return l.Listener
+}
@@ -0,0 +1,214 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package jsonrpc2test_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/goplus/gop/x/jsonrpc2"
+	"github.com/goplus/gop/x/jsonrpc2/jsonrpc2test"
+)
+
+type addParams struct {
+	A, B int
+}
+
+func newTestRouter() *jsonrpc2.Router {
+	r := jsonrpc2.NewRouter()
+	jsonrpc2.Register(r, "add", func(ctx context.Context, p addParams) (int, error) {
+		return p.A + p.B, nil
+	})
+	jsonrpc2.Register(r, "find", func(ctx context.Context, name string) (*addParams, error) {
+		if name == "" {
+			return nil, nil
+		}
+		return &addParams{A: len(name)}, nil
+	})
+	jsonrpc2.RegisterNotify(r, "crash", func(ctx context.Context, _ struct{}) error {
+		panic("boom")
+	})
+	return r
+}
+
+func handleCall(t *testing.T, h jsonrpc2.Handler, method string, params any) (any, error) {
+	req, err := jsonrpc2.NewCall(jsonrpc2.Int64ID(1), method, params)
+	if err != nil {
+		t.Fatal("NewCall:", err)
+	}
+	return h.Handle(context.Background(), req)
+}
+
+func TestRouter(t *testing.T) {
+	r := newTestRouter()
+	if ret, err := handleCall(t, r, "add", addParams{1, 2}); err != nil || ret != 3 {
+		t.Fatal("add:", ret, err)
+	}
+	if ret, err := handleCall(t, r, "find", ""); err != nil || ret.(*addParams) != nil {
+		t.Fatal("find:", ret, err)
+	}
+	if _, err := handleCall(t, r, "add", "x"); !errors.Is(err, jsonrpc2.ErrInvalidParams) {
+		t.Fatal("add: expected ErrInvalidParams, got", err)
+	}
+	if _, err := handleCall(t, r, "sub", nil); !errors.Is(err, jsonrpc2.ErrMethodNotFound) {
+		t.Fatal("sub: expected ErrMethodNotFound, got", err)
+	}
+	if ret, err := r.Preempt(context.Background(), &jsonrpc2.Request{Method: "sub"}); ret != nil || err != jsonrpc2.ErrNotHandled {
+		t.Fatal("Preempt:", ret, err)
+	}
+
+	// a notification has no result
+	req, _ := jsonrpc2.NewNotification("add", addParams{1, 2})
+	if ret, err := r.Handle(context.Background(), req); ret != nil || err != nil {
+		t.Fatal("add notification:", ret, err)
+	}
+
+	r.Fallback = jsonrpc2.HandlerFunc(func(ctx context.Context, req *jsonrpc2.Request) (any, error) {
+		return "fallback:" + req.Method, nil
+	})
+	if ret, err := handleCall(t, r, "sub", nil); err != nil || ret != "fallback:sub" {
+		t.Fatal("Fallback:", ret, err)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	r := newTestRouter()
+	var trace []string
+	tracer := func(name string) jsonrpc2.Middleware {
+		return func(next jsonrpc2.Handler) jsonrpc2.Handler {
+			return jsonrpc2.HandlerFunc(func(ctx context.Context, req *jsonrpc2.Request) (any, error) {
+				trace = append(trace, name+">")
+				defer func() { trace = append(trace, "<"+name) }()
+				return next.Handle(ctx, req)
+			})
+		}
+	}
+	var logs []string
+	var timed []string
+	var panicked string
+	r.Use(
+		jsonrpc2.Logging(func(format string, args ...any) {
+			logs = append(logs, fmt.Sprintf(format, args...))
+		}),
+		jsonrpc2.Timing(func(method string, elapsed time.Duration, err error) {
+			timed = append(timed, method)
+		}),
+		jsonrpc2.Recover(func(method string, v any, stack []byte) {
+			panicked = fmt.Sprint(method, ":", v)
+		}),
+		tracer("a"), tracer("b"),
+	)
+	if ret, err := handleCall(t, r, "add", addParams{3, 4}); err != nil || ret != 7 {
+		t.Fatal("add:", ret, err)
+	}
+	if got := strings.Join(trace, " "); got != "a> b> <b <a" {
+		t.Fatal("middleware order:", got)
+	}
+	if _, err := handleCall(t, r, "crash", nil); !errors.Is(err, jsonrpc2.ErrInternal) || panicked != "crash:boom" {
+		t.Fatal("Recover:", err, panicked)
+	}
+	if len(logs) != 2 || !strings.HasPrefix(logs[0], "jsonrpc2: add 1: ok (") {
+		t.Fatal("Logging:", logs)
+	}
+	if strings.Join(timed, " ") != "add crash" {
+		t.Fatal("Timing:", timed)
+	}
+}
+
+func TestConcurrency(t *testing.T) {
+	var running, peak int32
+	slow := jsonrpc2.Chain(jsonrpc2.HandlerFunc(func(ctx context.Context, req *jsonrpc2.Request) (any, error) {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			old := atomic.LoadInt32(&peak)
+			if n <= old || atomic.CompareAndSwapInt32(&peak, old, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		return true, nil
+	}), jsonrpc2.Concurrency(map[string]int{"slow": 2}))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			handleCall(t, slow, "slow", nil)
+		}()
+	}
+	wg.Wait()
+	if peak != 2 {
+		t.Fatal("Concurrency: peak", peak)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	block := make(chan struct{})
+	busy := jsonrpc2.Chain(jsonrpc2.HandlerFunc(func(ctx context.Context, req *jsonrpc2.Request) (any, error) {
+		<-block
+		return true, nil
+	}), jsonrpc2.Concurrency(map[string]int{"busy": 1}))
+	req, _ := jsonrpc2.NewCall(jsonrpc2.Int64ID(1), "busy", nil)
+	go busy.Handle(context.Background(), req)
+	time.Sleep(5 * time.Millisecond)
+	cancel()
+	if _, err := busy.Handle(ctx, req); !errors.Is(err, jsonrpc2.ErrServerOverloaded) {
+		t.Fatal("Concurrency: expected ErrServerOverloaded, got", err)
+	}
+	close(block)
+}
+
+func TestRouterConn(t *testing.T) {
+	ctx := context.Background()
+	listener := jsonrpc2test.NetPipeListener()
+	router := newTestRouter()
+	server := jsonrpc2.NewServer(ctx, listener, jsonrpc2.BinderFunc(
+		func(context.Context, *jsonrpc2.Connection) jsonrpc2.ConnectionOptions {
+			return jsonrpc2.ConnectionOptions{Handler: router}
+		}))
+	defer func() {
+		listener.Close()
+		server.Wait()
+	}()
+	conn, err := jsonrpc2.Dial(ctx, listener.Dialer(), jsonrpc2.BinderFunc(
+		func(context.Context, *jsonrpc2.Connection) jsonrpc2.ConnectionOptions {
+			return jsonrpc2.ConnectionOptions{}
+		}), nil)
+	if err != nil {
+		t.Fatal("Dial:", err)
+	}
+	defer conn.Close()
+
+	var sum int
+	if err = conn.Call(ctx, "add", addParams{20, 22}).Await(ctx, &sum); err != nil || sum != 42 {
+		t.Fatal("add:", sum, err)
+	}
+	var found *addParams
+	if err = conn.Call(ctx, "find", "").Await(ctx, &found); err != nil || found != nil {
+		t.Fatal("find:", found, err)
+	}
+	if err = conn.Call(ctx, "sub", nil).Await(ctx, nil); !errors.Is(err, jsonrpc2.ErrMethodNotFound) {
+		t.Fatal("sub: expected ErrMethodNotFound, got", err)
+	}
+}
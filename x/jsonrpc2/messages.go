@@ -24,6 +24,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"time"
 )
 
 // ID is a Request identifier.
@@ -33,8 +34,8 @@ type ID struct {
 
 // Message is the interface to all jsonrpc2 message types.
 // They share no common functionality, but are a closed set of concrete types
-// that are allowed to implement this interface. The message types are *Request
-// and *Response.
+// that are allowed to implement this interface. The message types are *Request,
+// *Response and Batch.
 type Message interface {
 	// marshal builds the wire form from the API form.
 	// It is private, which makes the set of Message implementations closed.
@@ -51,6 +52,11 @@ type Request struct {
 	Method string
 	// Params is either a struct or an array with the parameters of the method.
 	Params json.RawMessage
+	// Deadline is the time by which the caller gives up on a call.
+	// It is zero if the call has no deadline. It is sent as the milliseconds
+	// remaining (the "$timeout" extension field), so that the clocks of the
+	// peers needn't agree, and turned back into a deadline on receipt.
+	Deadline time.Time
 }
 
 // Response is a Message used as a reply to a call Request.
@@ -64,6 +70,18 @@ type Response struct {
 	ID ID
 }
 
+// Batch is a Message that carries several requests or responses at once.
+// It is sent on the wire as a JSON array. An entry of a received batch that
+// can't be decoded is kept in the batch as a placeholder, which Connection
+// answers with an error response.
+type Batch []Message
+
+// invalidEntry is the placeholder of an invalid entry of a received batch.
+type invalidEntry struct {
+	id  ID // the id of the entry, if it could be decoded
+	err error
+}
+
 // StringID creates a new string request identifier.
 func StringID(s string) ID { return ID{value: s} }
 
@@ -97,6 +115,13 @@ func (msg *Request) marshal(to *wireCombined) {
 	to.ID = msg.ID.value
 	to.Method = msg.Method
 	to.Params = msg.Params
+	if !msg.Deadline.IsZero() {
+		timeout := time.Until(msg.Deadline).Milliseconds()
+		if timeout < 0 {
+			timeout = 0
+		}
+		to.Timeout = &timeout
+	}
 }
 
 // NewResponse constructs a new Response message that is a reply to the
@@ -112,6 +137,16 @@ func (msg *Response) marshal(to *wireCombined) {
 	to.Result = msg.Result
 }
 
+func (msg Batch) marshal(to *wireCombined) {
+	panic("jsonrpc2: Batch can't be marshaled as a single message")
+}
+
+// marshal builds the error response to the invalid entry.
+func (msg *invalidEntry) marshal(to *wireCombined) {
+	to.ID = msg.id.value
+	to.Error = toWireError(msg.err)
+}
+
 func toWireError(err error) *wireError {
 	if err == nil {
 		// no error, the response is complete
@@ -132,6 +167,9 @@ func toWireError(err error) *wireError {
 }
 
 func EncodeMessage(msg Message) ([]byte, error) {
+	if batch, ok := msg.(Batch); ok {
+		return encodeBatch(batch)
+	}
 	wire := wireCombined{VersionTag: wireVersion}
 	msg.marshal(&wire)
 	data, err := json.Marshal(&wire)
@@ -141,37 +179,104 @@ func EncodeMessage(msg Message) ([]byte, error) {
 	return data, nil
 }
 
+func encodeBatch(batch Batch) ([]byte, error) {
+	if len(batch) == 0 {
+		return nil, fmt.Errorf("marshaling jsonrpc batch: %w", ErrInvalidRequest)
+	}
+	wires := make([]wireCombined, len(batch))
+	for i, msg := range batch {
+		if _, ok := msg.(Batch); ok {
+			return nil, fmt.Errorf("marshaling jsonrpc batch: %w: nested batch", ErrInvalidRequest)
+		}
+		wires[i].VersionTag = wireVersion
+		msg.marshal(&wires[i])
+	}
+	data, err := json.Marshal(wires)
+	if err != nil {
+		return data, fmt.Errorf("marshaling jsonrpc batch: %w", err)
+	}
+	return data, nil
+}
+
 func DecodeMessage(data []byte) (Message, error) {
+	if isBatch(data) {
+		return decodeBatch(data)
+	}
 	msg := wireCombined{}
 	if err := json.Unmarshal(data, &msg); err != nil {
 		return nil, fmt.Errorf("unmarshaling jsonrpc message: %w", err)
 	}
+	return decodeWire(&msg)
+}
+
+// isBatch reports whether data holds a JSON array.
+func isBatch(data []byte) bool {
+	for _, c := range data {
+		switch c {
+		case ' ', '\t', '\r', '\n':
+			continue
+		}
+		return c == '['
+	}
+	return false
+}
+
+// decodeBatch decodes the entries of a batch separately, so that an invalid
+// entry doesn't fail the others.
+func decodeBatch(data []byte) (Message, error) {
+	var entries []json.RawMessage
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("unmarshaling jsonrpc batch: %w", err)
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("unmarshaling jsonrpc batch: %w: empty batch", ErrInvalidRequest)
+	}
+	batch := make(Batch, len(entries))
+	for i, entry := range entries {
+		batch[i] = decodeEntry(entry)
+	}
+	return batch, nil
+}
+
+func decodeEntry(entry json.RawMessage) Message {
+	msg := wireCombined{}
+	err := json.Unmarshal(entry, &msg)
+	if err == nil {
+		var ret Message
+		if ret, err = decodeWire(&msg); err == nil {
+			return ret
+		}
+	}
+	if !errors.Is(err, ErrInvalidRequest) {
+		err = fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+	}
+	id, _ := makeID(msg.ID) // msg.ID is kept if other fields are of wrong types
+	return &invalidEntry{id: id, err: err}
+}
+
+func decodeWire(msg *wireCombined) (Message, error) {
 	if msg.VersionTag != wireVersion {
 		return nil, fmt.Errorf("invalid message version tag %s expected %s", msg.VersionTag, wireVersion)
 	}
-	id := ID{}
-	switch v := msg.ID.(type) {
-	case nil:
-	case float64:
-		// coerce the id type to int64 if it is float64, the spec does not allow fractional parts
-		id = Int64ID(int64(v))
-	case int64:
-		id = Int64ID(v)
-	case string:
-		id = StringID(v)
-	default:
-		return nil, fmt.Errorf("invalid message id type <%T>%v", v, v)
+	id, err := makeID(msg.ID)
+	if err != nil {
+		return nil, err
 	}
 	if msg.Method != "" {
 		// has a method, must be a call
-		return &Request{
+		req := &Request{
 			Method: msg.Method,
 			ID:     id,
 			Params: msg.Params,
-		}, nil
+		}
+		if msg.Timeout != nil {
+			req.Deadline = time.Now().Add(time.Duration(*msg.Timeout) * time.Millisecond)
+		}
+		return req, nil
 	}
-	// no method, should be a response
-	if !id.IsValid() {
+	// no method, should be a response, whose id is null only if it is the error
+	// of a request that couldn't be decoded
+	if !id.IsValid() && msg.Error == nil {
 		return nil, ErrInvalidRequest
 	}
 	resp := &Response{
@@ -185,6 +290,22 @@ func DecodeMessage(data []byte) (Message, error) {
 	return resp, nil
 }
 
+// makeID converts a decoded JSON value into an ID.
+func makeID(v any) (ID, error) {
+	switch v := v.(type) {
+	case nil:
+		return ID{}, nil
+	case float64:
+		// coerce the id type to int64 if it is float64, the spec does not allow fractional parts
+		return Int64ID(int64(v)), nil
+	case int64:
+		return Int64ID(v), nil
+	case string:
+		return StringID(v), nil
+	}
+	return ID{}, fmt.Errorf("invalid message id type <%T>%v", v, v)
+}
+
 func marshalToRaw(obj any) (json.RawMessage, error) {
 	if obj == nil {
 		return nil, nil
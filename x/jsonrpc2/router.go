@@ -0,0 +1,227 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package jsonrpc2
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"runtime/debug"
+	"time"
+)
+
+// -----------------------------------------------------------------------------
+
+// Middleware wraps a Handler to add behavior around it.
+type Middleware func(next Handler) Handler
+
+// Router dispatches requests to the handlers registered for their methods.
+// It implements both Handler and Preempter.
+type Router struct {
+	methods    map[string]Handler
+	middleware []Middleware
+
+	// Fallback handles the requests whose method is not registered.
+	// If nil, they are answered with ErrMethodNotFound by Handle, and
+	// ErrNotHandled by Preempt.
+	Fallback Handler
+}
+
+// NewRouter creates a new Router.
+func NewRouter() *Router {
+	return &Router{methods: make(map[string]Handler)}
+}
+
+// Use appends middleware to the chain wrapped around every handler of the
+// router. The first middleware is the outermost one.
+func (r *Router) Use(mw ...Middleware) {
+	r.middleware = append(r.middleware, mw...)
+}
+
+// Method registers the handler for a method, replacing any previous one.
+func (r *Router) Method(method string, h Handler) {
+	r.methods[method] = h
+}
+
+// Has returns whether a handler is registered for method.
+func (r *Router) Has(method string) bool {
+	_, ok := r.methods[method]
+	return ok
+}
+
+func (r *Router) Handle(ctx context.Context, req *Request) (any, error) {
+	h, ok := r.methods[req.Method]
+	if !ok {
+		if r.Fallback == nil {
+			return nil, fmt.Errorf("%w: %q", ErrMethodNotFound, req.Method)
+		}
+		h = r.Fallback
+	}
+	return Chain(h, r.middleware...).Handle(ctx, req)
+}
+
+func (r *Router) Preempt(ctx context.Context, req *Request) (any, error) {
+	if r.Fallback == nil && !r.Has(req.Method) {
+		return nil, ErrNotHandled
+	}
+	return r.Handle(ctx, req)
+}
+
+var (
+	_ Handler   = (*Router)(nil)
+	_ Preempter = (*Router)(nil)
+)
+
+// Register registers a typed handler for a method of router r. The params of
+// the request are decoded into P, and a decoding failure is answered with
+// ErrInvalidParams. The result of a notification is discarded.
+func Register[P, R any](r *Router, method string, fn func(ctx context.Context, params P) (R, error)) {
+	r.Method(method, HandlerFunc(func(ctx context.Context, req *Request) (any, error) {
+		var params P
+		if len(req.Params) > 0 {
+			if err := json.Unmarshal(req.Params, &params); err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrInvalidParams, err)
+			}
+		}
+		ret, err := fn(ctx, params)
+		if err != nil || !req.IsCall() {
+			return nil, err
+		}
+		if any(ret) == nil {
+			return json.RawMessage("null"), nil
+		}
+		return ret, nil
+	}))
+}
+
+// RegisterNotify registers a typed handler without result for a method of
+// router r. A call to it is answered with null.
+func RegisterNotify[P any](r *Router, method string, fn func(ctx context.Context, params P) error) {
+	Register(r, method, func(ctx context.Context, params P) (any, error) {
+		return nil, fn(ctx, params)
+	})
+}
+
+// Chain wraps h with middleware. The first middleware is the outermost one.
+func Chain(h Handler, mw ...Middleware) Handler {
+	for i := len(mw) - 1; i >= 0; i-- {
+		h = mw[i](h)
+	}
+	return h
+}
+
+// ChainPreempter wraps p with middleware. The first middleware is the
+// outermost one.
+func ChainPreempter(p Preempter, mw ...Middleware) Preempter {
+	h := Chain(HandlerFunc(p.Preempt), mw...)
+	return PreempterFunc(h.Handle)
+}
+
+// -----------------------------------------------------------------------------
+
+// Logging returns a middleware which logs each request handled, together with
+// its error and how long it took. Requests not handled are not logged.
+func Logging(logf func(format string, args ...any)) Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, req *Request) (any, error) {
+			start := time.Now()
+			ret, err := next.Handle(ctx, req)
+			if err != ErrNotHandled {
+				id := req.ID.Raw()
+				if id == nil {
+					id = "-"
+				}
+				logf("jsonrpc2: %s %v: %v (%v)", req.Method, id, errOrOK(err), time.Since(start))
+			}
+			return ret, err
+		})
+	}
+}
+
+func errOrOK(err error) any {
+	if err == nil {
+		return "ok"
+	}
+	return err
+}
+
+// Timing returns a middleware which reports how long each request handled
+// took.
+func Timing(report func(method string, elapsed time.Duration, err error)) Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, req *Request) (any, error) {
+			start := time.Now()
+			ret, err := next.Handle(ctx, req)
+			if err != ErrNotHandled {
+				report(req.Method, time.Since(start), err)
+			}
+			return ret, err
+		})
+	}
+}
+
+// Recover returns a middleware which turns a panic of the handler into an
+// ErrInternal error. If onPanic isn't nil, it is called with the recovered
+// value and the stack of the panic.
+func Recover(onPanic func(method string, v any, stack []byte)) Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, req *Request) (ret any, err error) {
+			defer func() {
+				if v := recover(); v != nil {
+					if onPanic != nil {
+						onPanic(req.Method, v, debug.Stack())
+					}
+					ret, err = nil, fmt.Errorf("%w: %q panicked: %v", ErrInternal, req.Method, v)
+				}
+			}()
+			return next.Handle(ctx, req)
+		})
+	}
+}
+
+// Concurrency returns a middleware which limits how many requests of each
+// method in limits may be handled at the same time. Methods without a limit
+// are not restricted. A request over the limit waits until one of the
+// requests in flight completes, or fails with ErrServerOverloaded when its
+// context is done first.
+//
+// The limits apply to all the connections sharing the middleware. Note a
+// Connection calls its Handler sequentially, so they only matter if the
+// middleware is shared by several connections.
+func Concurrency(limits map[string]int) Middleware {
+	sems := make(map[string]chan struct{}, len(limits))
+	for method, n := range limits {
+		if n > 0 {
+			sems[method] = make(chan struct{}, n)
+		}
+	}
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, req *Request) (any, error) {
+			sem, ok := sems[req.Method]
+			if !ok {
+				return next.Handle(ctx, req)
+			}
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return nil, fmt.Errorf("%w: %q: %v", ErrServerOverloaded, req.Method, ctx.Err())
+			}
+			defer func() { <-sem }()
+			return next.Handle(ctx, req)
+		})
+	}
+}
@@ -66,6 +66,7 @@ type wireCombined struct {
 	Params     json.RawMessage `json:"params,omitempty"`
 	Result     json.RawMessage `json:"result,omitempty"`
 	Error      *wireError      `json:"error,omitempty"`
+	Timeout    *int64          `json:"$timeout,omitempty"` // see Request.Deadline
 }
 
 // wireError represents a structured error in a Response.
@@ -17,7 +17,9 @@
 package langserver
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 
 	"github.com/goplus/gop/x/jsonrpc2"
 )
@@ -27,6 +29,7 @@ import (
 const (
 	methodGenGo   = "gengo"
 	methodChanged = "changed"
+	methodStatus  = "status"
 )
 
 // -----------------------------------------------------------------------------
@@ -44,8 +47,16 @@ type Client struct {
 // Open uses the dialer to make a new connection and returns a client of the LangServer
 // based on the connection.
 func Open(ctx context.Context, dialer Dialer, onDone func()) (ret Client, err error) {
+	return OpenFramer(ctx, dialer, nil, onDone)
+}
+
+// OpenFramer is like Open but frames messages with framer. If framer is nil,
+// HeaderFramer will be used.
+func OpenFramer(ctx context.Context, dialer Dialer, framer jsonrpc2.Framer, onDone func()) (ret Client, err error) {
 	c, err := jsonrpc2.Dial(ctx, dialer, jsonrpc2.BinderFunc(
 		func(ctx context.Context, c *jsonrpc2.Connection) (ret jsonrpc2.ConnectionOptions) {
+			ret.Framer = framer
+			ret.CancelRequests = true
 			return
 		}), onDone)
 	if err != nil {
@@ -59,16 +70,63 @@ func (p Client) Close() error {
 	return p.conn.Close()
 }
 
+// GenGoProgress is the progress of a gengo call, reported after each project
+// has been processed.
+type GenGoProgress struct {
+	Proj  string `json:"proj"`
+	Done  int    `json:"done"`
+	Total int    `json:"total"`
+}
+
+// GenGoParams is the params of a gengo call.
+type GenGoParams struct {
+	Pattern    []string `json:"pattern"`
+	GenTestPkg bool     `json:"gentest"` // generate the test packages
+}
+
+// UnmarshalJSON also accepts a bare pattern, the params of older clients,
+// which generate the test packages.
+func (p *GenGoParams) UnmarshalJSON(data []byte) error {
+	if data = bytes.TrimSpace(data); len(data) > 0 && data[0] == '[' {
+		p.GenTestPkg = true
+		return json.Unmarshal(data, &p.Pattern)
+	}
+	type params GenGoParams
+	return json.Unmarshal(data, (*params)(p))
+}
+
+// AsyncGenGo starts to generate Go files for the projects of pattern. The call
+// is canceled on the LangServer when ctx is done. Use jsonrpc2.WithProgress
+// to receive its GenGoProgress.
 func (p Client) AsyncGenGo(ctx context.Context, pattern ...string) *AsyncCall {
-	return p.conn.Call(ctx, methodGenGo, pattern)
+	return p.AsyncGenGoEx(ctx, true, pattern...)
+}
+
+// AsyncGenGoEx is like AsyncGenGo, but generates the test packages only if
+// genTestPkg is set.
+func (p Client) AsyncGenGoEx(ctx context.Context, genTestPkg bool, pattern ...string) *AsyncCall {
+	return p.conn.Call(ctx, methodGenGo, &GenGoParams{Pattern: pattern, GenTestPkg: genTestPkg})
 }
 
 func (p Client) GenGo(ctx context.Context, pattern ...string) (err error) {
 	return p.AsyncGenGo(ctx, pattern...).Await(ctx, nil)
 }
 
+// GenGoEx is like GenGo, but generates the test packages only if genTestPkg
+// is set.
+func (p Client) GenGoEx(ctx context.Context, genTestPkg bool, pattern ...string) (err error) {
+	return p.AsyncGenGoEx(ctx, genTestPkg, pattern...).Await(ctx, nil)
+}
+
 func (p Client) Changed(ctx context.Context, files ...string) (err error) {
 	return p.conn.Notify(ctx, methodChanged, files)
 }
 
+// Status returns the queued and in-flight work of the LangServer.
+func (p Client) Status(ctx context.Context) (ret *Status, err error) {
+	ret = new(Status)
+	err = p.conn.Call(ctx, methodStatus, nil).Await(ctx, ret)
+	return
+}
+
 // -----------------------------------------------------------------------------
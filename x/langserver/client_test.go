@@ -0,0 +1,23 @@
+package langserver
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestGenGoParams(t *testing.T) {
+	var params GenGoParams
+	if err := json.Unmarshal([]byte(`["./foo", "./bar"]`), &params); err != nil ||
+		len(params.Pattern) != 2 || params.Pattern[1] != "./bar" || !params.GenTestPkg {
+		t.Fatal("GenGoParams:", params, err)
+	}
+	b, err := json.Marshal(&GenGoParams{Pattern: []string{"./foo"}})
+	if err != nil || string(b) != `{"pattern":["./foo"],"gentest":false}` {
+		t.Fatal("json.Marshal:", string(b), err)
+	}
+	params = GenGoParams{}
+	if err = json.Unmarshal(b, &params); err != nil ||
+		len(params.Pattern) != 1 || params.Pattern[0] != "./foo" || params.GenTestPkg {
+		t.Fatal("GenGoParams:", params, err)
+	}
+}
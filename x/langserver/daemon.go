@@ -0,0 +1,257 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package langserver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/goplus/gop/x/jsonrpc2"
+	"github.com/goplus/gop/x/jsonrpc2/stdio"
+)
+
+var (
+	ErrInvalidAddr = errors.New("invalid address: expect stdio, unix:<path> or tcp:<host:port>")
+)
+
+// -----------------------------------------------------------------------------
+
+const (
+	addrStdio = "stdio"
+)
+
+// parseAddr parses an address in the form of `unix:<path>` or `tcp:<host:port>`.
+func parseAddr(addr string) (network, address string, err error) {
+	pos := strings.IndexByte(addr, ':')
+	if pos > 0 {
+		network, address = addr[:pos], addr[pos+1:]
+		switch network {
+		case "unix", "tcp", "tcp4", "tcp6":
+			if address != "" {
+				return
+			}
+		}
+	}
+	return "", "", ErrInvalidAddr
+}
+
+// Listen announces on the local address addr, which is `stdio`, `unix:<path>`
+// or `tcp:<host:port>`. Unlike stdio, a socket listener can be shared by many
+// clients at the same time.
+//
+// If idleTimeout is non-zero, the listener is closed after there are no clients
+// for this duration.
+func Listen(ctx context.Context, addr string, idleTimeout time.Duration) (ret Listener, err error) {
+	if addr == "" || addr == addrStdio {
+		ret = stdio.Listener(false)
+	} else {
+		network, address, e := parseAddr(addr)
+		if e != nil {
+			return nil, e
+		}
+		if network == "unix" {
+			removeStaleSocket(address)
+		}
+		ret, err = jsonrpc2.NetListener(ctx, network, address, jsonrpc2.NetListenOptions{})
+		if err != nil {
+			return
+		}
+	}
+	if idleTimeout > 0 {
+		ret = jsonrpc2.NewIdleListener(idleTimeout, ret)
+	}
+	return
+}
+
+// removeStaleSocket removes the socket file left by a dead LangServer.
+func removeStaleSocket(path string) {
+	if _, err := os.Stat(path); err != nil {
+		return
+	}
+	if c, err := net.DialTimeout("unix", path, time.Second); err == nil {
+		c.Close()
+		return
+	}
+	os.Remove(path)
+}
+
+// Dial connects to the LangServer listening on addr (`unix:<path>` or
+// `tcp:<host:port>`) and returns a client of it.
+func Dial(ctx context.Context, addr string, onDone func()) (ret Client, err error) {
+	return DialFramer(ctx, addr, nil, onDone)
+}
+
+// DialFramer is like Dial but frames messages with framer. If framer is nil,
+// HeaderFramer will be used.
+func DialFramer(ctx context.Context, addr string, framer jsonrpc2.Framer, onDone func()) (ret Client, err error) {
+	network, address, err := parseAddr(addr)
+	if err != nil {
+		return
+	}
+	dialer := jsonrpc2.NetDialer(network, address, net.Dialer{Timeout: 5 * time.Second})
+	return OpenFramer(ctx, dialer, framer, onDone)
+}
+
+// DialDaemon is like Dial, but frames messages with the framing recorded for
+// the LangServer listening on addr by RegisterDaemon, if any.
+func DialDaemon(ctx context.Context, addr string, onDone func()) (ret Client, err error) {
+	var framer jsonrpc2.Framer
+	if ds, e := Daemons(); e == nil {
+		for _, d := range ds {
+			if d.Addr == addr {
+				if framer, err = jsonrpc2.FramerByName(d.Framing); err != nil {
+					return
+				}
+				break
+			}
+		}
+	}
+	return DialFramer(ctx, addr, framer, onDone)
+}
+
+// -----------------------------------------------------------------------------
+
+// Daemon represents a running LangServer which is listening on a socket.
+type Daemon struct {
+	Pid     int
+	Addr    string
+	Framing string // name of the message framing, see jsonrpc2.FramerByName
+}
+
+const (
+	// daemonListen and daemonStopped are the records that a LangServer writes
+	// into its logfile ~/.gop/serve-{pid}.log when it starts listening on a
+	// socket and when it exits.
+	daemonListen  = "==> LangServer: listen "
+	daemonStopped = "==> LangServer: stopped"
+
+	stopTimeout = 5 * time.Second
+)
+
+// RegisterDaemon records that the current process is a LangServer listening on
+// addr with the message framing of the specified name, so that it can be found
+// by Daemons. Call unregister when the server exits.
+func RegisterDaemon(addr, framing string) (unregister func(), err error) {
+	if framing == "" {
+		framing = "header"
+	}
+	gopDir, err := gopDirOf()
+	if err != nil {
+		return
+	}
+	file := logFileOf(gopDir, os.Getpid())
+	if err = appendRecord(file, daemonListen+framing+" "+addr); err != nil {
+		return
+	}
+	return func() { appendRecord(file, daemonStopped) }, nil
+}
+
+func appendRecord(file, record string) error {
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = f.WriteString(record + "\n")
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	return err
+}
+
+// daemonOf returns the LangServer recorded in a logfile, if it is still
+// listening.
+func daemonOf(gopDir, fname string) (d Daemon, ok bool) {
+	b, err := os.ReadFile(gopDir + fname)
+	if err != nil {
+		return
+	}
+	for _, line := range strings.Split(string(b), "\n") {
+		if strings.HasPrefix(line, daemonListen) {
+			// ==> LangServer: listen <framing> <addr>
+			d.Framing, d.Addr, ok = strings.Cut(line[len(daemonListen):], " ")
+		} else if line == daemonStopped {
+			ok = false
+		}
+	}
+	if !ok {
+		return
+	}
+	d.Pid = pidByName(fname)
+	return d, d.Pid >= 0 && d.alive()
+}
+
+// Daemons returns all running LangServers registered by RegisterDaemon.
+func Daemons() (ret []Daemon, err error) {
+	gopDir, err := gopDirOf()
+	if err != nil {
+		return
+	}
+	fis, err := os.ReadDir(gopDir)
+	if err != nil {
+		return
+	}
+	for _, fi := range fis {
+		if fname := fi.Name(); !fi.IsDir() && isLog(fname) {
+			if d, ok := daemonOf(gopDir, fname); ok {
+				ret = append(ret, d)
+			}
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Pid < ret[j].Pid
+	})
+	return
+}
+
+func (p Daemon) alive() bool {
+	network, address, err := parseAddr(p.Addr)
+	if err != nil {
+		return false
+	}
+	c, err := net.DialTimeout(network, address, time.Second)
+	if err != nil {
+		return false
+	}
+	c.Close()
+	return true
+}
+
+// Stop asks the LangServer to exit by SIGTERM, and kills it if it is still
+// listening after a timeout.
+func (p Daemon) Stop() {
+	proc, err := os.FindProcess(p.Pid)
+	if err != nil {
+		return
+	}
+	if proc.Signal(syscall.SIGTERM) == nil {
+		for deadline := time.Now().Add(stopTimeout); time.Now().Before(deadline); {
+			if !p.alive() {
+				return
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+	}
+	proc.Kill()
+}
+
+// -----------------------------------------------------------------------------
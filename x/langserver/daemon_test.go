@@ -0,0 +1,140 @@
+package langserver
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/goplus/gop/x/jsonrpc2"
+)
+
+func TestParseAddr(t *testing.T) {
+	if network, address, err := parseAddr("tcp:127.0.0.1:8080"); err != nil || network != "tcp" || address != "127.0.0.1:8080" {
+		t.Fatal("parseAddr:", network, address, err)
+	}
+	for _, addr := range []string{"", "unix:", "foo:bar", ":8080"} {
+		if _, _, err := parseAddr(addr); err != ErrInvalidAddr {
+			t.Fatal("parseAddr:", addr, err)
+		}
+	}
+}
+
+func TestDaemon(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	addr := "unix:" + filepath.Join(t.TempDir(), "gop.sock")
+	ctx := context.Background()
+	listener, err := Listen(ctx, addr, 0)
+	if err != nil {
+		t.Fatal("Listen:", err)
+	}
+	server := NewServer(ctx, listener, &Config{Framer: jsonrpc2.NDJSONFramer()})
+	defer func() {
+		listener.Close()
+		server.Wait()
+	}()
+
+	unregister, err := RegisterDaemon(addr, "ndjson")
+	if err != nil {
+		t.Fatal("RegisterDaemon:", err)
+	}
+	ds, err := Daemons()
+	if err != nil || len(ds) != 1 || ds[0].Addr != addr || ds[0].Framing != "ndjson" {
+		t.Fatal("Daemons:", ds, err)
+	}
+
+	// two clients share the same server
+	framer, _ := jsonrpc2.FramerByName(ds[0].Framing)
+	for i := 0; i < 2; i++ {
+		c, err := DialFramer(ctx, addr, framer, nil)
+		if err != nil {
+			t.Fatal("Dial:", err)
+		}
+		if _, err = c.Status(ctx); err != nil {
+			t.Fatal("Status:", err)
+		}
+		c.Close()
+	}
+	c, err := DialDaemon(ctx, addr, nil) // with the framing recorded
+	if err != nil {
+		t.Fatal("DialDaemon:", err)
+	}
+	if _, err = c.Status(ctx); err != nil {
+		t.Fatal("Status:", err)
+	}
+	c.Close()
+
+	unregister()
+	if ds, err = Daemons(); err != nil || len(ds) != 0 {
+		t.Fatal("Daemons:", ds, err)
+	}
+	b, err := os.ReadFile(logFileOf(filepath.Join(os.Getenv("HOME"), ".gop")+"/", os.Getpid()))
+	if err != nil || string(b) != daemonListen+"ndjson "+addr+"\n"+daemonStopped+"\n" {
+		t.Fatal("records:", string(b), err)
+	}
+}
+
+func TestDaemonStop(t *testing.T) {
+	if addr := os.Getenv("GOP_TEST_DAEMON"); addr != "" { // the LangServer to stop
+		listener, err := Listen(context.Background(), addr, 0)
+		if err != nil {
+			t.Fatal("Listen:", err)
+		}
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, syscall.SIGTERM)
+		<-sig
+		listener.Close()
+		return
+	}
+	addr := "unix:" + filepath.Join(t.TempDir(), "gop.sock")
+	cmd := exec.Command(os.Args[0], "-test.run=^TestDaemonStop$")
+	cmd.Env = append(os.Environ(), "GOP_TEST_DAEMON="+addr)
+	var out strings.Builder
+	cmd.Stdout = &out
+	if err := cmd.Start(); err != nil {
+		t.Fatal("Start:", err)
+	}
+	d := Daemon{Pid: cmd.Process.Pid, Addr: addr}
+	for i := 0; !d.alive(); i++ {
+		if i == 100 {
+			cmd.Process.Kill()
+			t.Fatal("Daemon: not listening")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	d.Stop()
+	if err := cmd.Wait(); err != nil { // exits by itself on SIGTERM
+		t.Fatal("Stop:", err, out.String())
+	}
+}
+
+func TestDialFramer(t *testing.T) {
+	addr := "unix:" + filepath.Join(t.TempDir(), "gop.sock")
+	ctx := context.Background()
+	for _, name := range []string{"ndjson", "length"} {
+		framer, err := jsonrpc2.FramerByName(name)
+		if err != nil {
+			t.Fatal("FramerByName:", err)
+		}
+		listener, err := Listen(ctx, addr, 0)
+		if err != nil {
+			t.Fatal("Listen:", err)
+		}
+		server := NewServer(ctx, listener, &Config{Framer: framer})
+		c, err := DialFramer(ctx, addr, framer, nil)
+		if err != nil {
+			t.Fatal("DialFramer:", err)
+		}
+		if _, err = c.Status(ctx); err != nil {
+			t.Fatal("Status:", name, err)
+		}
+		c.Close()
+		listener.Close()
+		server.Wait()
+	}
+}
@@ -0,0 +1,104 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package langserver
+
+import (
+	"bytes"
+	"unicode"
+	"unicode/utf8"
+)
+
+// -----------------------------------------------------------------------------
+
+// Diagnostics type-checks the package in dir and returns the diagnostics of
+// each of its files. Files without problems are mapped to an empty list, so
+// that clients can clear the diagnostics they have shown before.
+func (p *workspace) Diagnostics(dir string) (ret []PublishDiagnosticsParams, err error) {
+	pkg, err := p.check(dir)
+	if err != nil {
+		return
+	}
+	byFile := make(map[string][]Diagnostic)
+	for file := range pkg.Files {
+		byFile[file] = []Diagnostic{}
+	}
+	for file := range pkg.GoFiles {
+		byFile[file] = []Diagnostic{}
+	}
+	texts := make(map[string][]byte)
+	for _, e := range pkg.Errors {
+		file := e.Pos.Filename
+		if _, ok := byFile[file]; !ok || e.Pos.Line == 0 {
+			continue
+		}
+		text, ok := texts[file]
+		if !ok {
+			text, _ = p.content(file)
+			texts[file] = text
+		}
+		severity := SeverityError
+		if e.Soft {
+			severity = SeverityWarning
+		}
+		byFile[file] = append(byFile[file], Diagnostic{
+			Range:    wordRangeAt(text, e.Pos.Line, e.Pos.Column),
+			Severity: severity,
+			Source:   "gop",
+			Message:  e.Msg,
+		})
+	}
+	for _, file := range sortedFiles(byFile) {
+		params := PublishDiagnosticsParams{URI: uriOf(file), Diagnostics: byFile[file]}
+		p.mutex.Lock()
+		if doc, ok := p.docs[file]; ok {
+			params.Version = doc.version
+		}
+		p.mutex.Unlock()
+		ret = append(ret, params)
+	}
+	return
+}
+
+// wordRangeAt returns the range of the word starting at the 1-based line and
+// byte column of text. If there is no word, an empty range is returned.
+func wordRangeAt(text []byte, line, column int) Range {
+	off := 0
+	for i := 1; i < line; i++ {
+		n := bytes.IndexByte(text[off:], '\n')
+		if n < 0 {
+			break
+		}
+		off += n + 1
+	}
+	if column > 0 {
+		off += column - 1
+	}
+	if off > len(text) {
+		off = len(text)
+	}
+	end := off
+	for end < len(text) {
+		r, n := utf8.DecodeRune(text[end:])
+		if r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			break
+		}
+		end += n
+	}
+	return Range{Start: positionOf(text, off), End: positionOf(text, end)}
+}
+
+// -----------------------------------------------------------------------------
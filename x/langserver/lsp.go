@@ -0,0 +1,340 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package langserver
+
+import (
+	"go/types"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/goplus/gop/ast"
+	"github.com/goplus/gop/token"
+)
+
+// -----------------------------------------------------------------------------
+
+// Hover returns the type information of the identifier at pos.
+// It returns nil if there is nothing to show.
+func (p *workspace) Hover(uri DocumentURI, pos Position) (ret *Hover, err error) {
+	at, err := p.locate(uri, pos)
+	if err != nil || at.ident == nil {
+		return
+	}
+	info := at.pkg.Info
+	var text string
+	if _, objs := info.OverloadOf(at.ident); objs != nil {
+		lines := make([]string, len(objs))
+		for i, o := range objs {
+			lines[i] = objectString(at.pkg.Types, o)
+		}
+		text = strings.Join(lines, "\n")
+	} else if obj := info.ObjectOf(at.ident); obj != nil {
+		text = objectString(at.pkg.Types, obj)
+	} else if typ := info.TypeOf(at.ident); typ != nil {
+		text = types.TypeString(typ, types.RelativeTo(at.pkg.Types))
+	} else {
+		return
+	}
+	rg := at.rangeOf(at.ident)
+	ret = &Hover{
+		Contents: MarkupContent{Kind: "markdown", Value: "```gop\n" + text + "\n```"},
+		Range:    &rg,
+	}
+	return
+}
+
+// Definition returns the location where the identifier at pos is defined.
+func (p *workspace) Definition(uri DocumentURI, pos Position) (ret []Location, err error) {
+	at, err := p.locate(uri, pos)
+	if err != nil || at.ident == nil {
+		return
+	}
+	obj := at.pkg.Info.ObjectOf(at.ident)
+	if obj == nil || !obj.Pos().IsValid() {
+		return
+	}
+	if loc, ok := p.locationOf(at.pkg.Fset, obj.Pos(), len(obj.Name())); ok {
+		ret = append(ret, loc)
+	}
+	return
+}
+
+// locationOf converts a position of fset into a LSP location.
+func (p *workspace) locationOf(fset *token.FileSet, pos token.Pos, n int) (loc Location, ok bool) {
+	f := fset.File(pos)
+	if f == nil {
+		return
+	}
+	file := f.Name()
+	if !filepath.IsAbs(file) {
+		return
+	}
+	text, err := p.content(file)
+	if err != nil {
+		return
+	}
+	off := f.Offset(pos)
+	loc.URI = uriOf(file)
+	loc.Range = Range{Start: positionOf(text, off), End: positionOf(text, off+n)}
+	return loc, true
+}
+
+// Completion returns the candidates which can be used at pos.
+func (p *workspace) Completion(uri DocumentURI, pos Position) (ret *CompletionList, err error) {
+	file := FilenameOf(uri)
+	text, err := p.content(file)
+	if err != nil {
+		return
+	}
+	pkg, err := p.check(filepath.Dir(file))
+	if err != nil {
+		return
+	}
+	off := offsetOf(text, pos)
+	start := identStart(text, off)
+	prefix := string(text[start:off])
+	ret = &CompletionList{Items: []CompletionItem{}}
+	tpos := tokenPos(pkg, file, start)
+	scope := innermostScope(pkg, file, tpos)
+	if start > 0 && text[start-1] == '.' {
+		x := string(text[identStart(text, start-1):(start - 1)])
+		if x == "" {
+			return
+		}
+		_, obj := scope.LookupParent(x, token.NoPos)
+		ret.Items = selectorItems(pkg.Types, obj, prefix)
+	} else {
+		ret.Items = scopeItems(pkg.Types, scope, prefix)
+	}
+	sort.Slice(ret.Items, func(i, j int) bool {
+		return ret.Items[i].Label < ret.Items[j].Label
+	})
+	return
+}
+
+func selectorItems(this *types.Package, obj types.Object, prefix string) (items []CompletionItem) {
+	if obj == nil {
+		return
+	}
+	if pkgName, ok := obj.(*types.PkgName); ok {
+		scope := pkgName.Imported().Scope()
+		for _, name := range scope.Names() {
+			if o := scope.Lookup(name); o.Exported() && strings.HasPrefix(name, prefix) {
+				items = append(items, completionItem(this, o))
+			}
+		}
+		return
+	}
+	if _, ok := obj.(*types.TypeName); ok {
+		return
+	}
+	typ := obj.Type()
+	seen := make(map[string]bool)
+	add := func(o types.Object) {
+		name := o.Name()
+		if seen[name] || !strings.HasPrefix(name, prefix) {
+			return
+		}
+		if !o.Exported() && o.Pkg() != this {
+			return
+		}
+		seen[name] = true
+		items = append(items, completionItem(this, o))
+	}
+	mset := types.NewMethodSet(typ)
+	if _, ok := typ.Underlying().(*types.Pointer); !ok && !types.IsInterface(typ) {
+		mset = types.NewMethodSet(types.NewPointer(typ))
+	}
+	for i, n := 0, mset.Len(); i < n; i++ {
+		add(mset.At(i).Obj())
+	}
+	if t, ok := typ.Underlying().(*types.Pointer); ok {
+		typ = t.Elem()
+	}
+	if t, ok := typ.Underlying().(*types.Struct); ok {
+		for i, n := 0, t.NumFields(); i < n; i++ {
+			add(t.Field(i))
+		}
+	}
+	return
+}
+
+func scopeItems(this *types.Package, scope *types.Scope, prefix string) (items []CompletionItem) {
+	seen := make(map[string]bool)
+	for s := scope; s != nil; s = s.Parent() {
+		for _, name := range s.Names() {
+			if seen[name] || !strings.HasPrefix(name, prefix) || strings.HasPrefix(name, "_") {
+				continue
+			}
+			seen[name] = true
+			items = append(items, completionItem(this, s.Lookup(name)))
+		}
+	}
+	return
+}
+
+func completionItem(this *types.Package, o types.Object) CompletionItem {
+	item := CompletionItem{Label: o.Name(), Detail: objectString(this, o)}
+	switch v := o.(type) {
+	case *types.Func:
+		if sig, ok := v.Type().(*types.Signature); ok && sig.Recv() != nil {
+			item.Kind = CompletionMethod
+		} else {
+			item.Kind = CompletionFunction
+		}
+	case *types.Var:
+		if v.IsField() {
+			item.Kind = CompletionField
+		} else {
+			item.Kind = CompletionVariable
+		}
+	case *types.Const:
+		item.Kind = CompletionConstant
+	case *types.PkgName:
+		item.Kind = CompletionModule
+	case *types.TypeName:
+		switch v.Type().Underlying().(type) {
+		case *types.Struct:
+			item.Kind = CompletionStruct
+		case *types.Interface:
+			item.Kind = CompletionInterface
+		default:
+			item.Kind = CompletionClass
+		}
+	case *types.Builtin:
+		item.Kind = CompletionFunction
+	default:
+		item.Kind = CompletionText
+	}
+	return item
+}
+
+func objectString(this *types.Package, o types.Object) string {
+	return types.ObjectString(o, types.RelativeTo(this))
+}
+
+// -----------------------------------------------------------------------------
+
+// location is a position in a type-checked Go+ file.
+type location struct {
+	pkg   *Package
+	file  *ast.File
+	text  []byte
+	tfile *token.File
+	ident *ast.Ident // identifier at the position, if any
+}
+
+func (p *location) rangeOf(node ast.Node) Range {
+	start := p.tfile.Offset(node.Pos())
+	end := p.tfile.Offset(node.End())
+	return Range{Start: positionOf(p.text, start), End: positionOf(p.text, end)}
+}
+
+// locate type-checks the package of uri and finds the identifier at pos.
+func (p *workspace) locate(uri DocumentURI, pos Position) (at *location, err error) {
+	file := FilenameOf(uri)
+	text, err := p.content(file)
+	if err != nil {
+		return
+	}
+	pkg, err := p.check(filepath.Dir(file))
+	if err != nil {
+		return
+	}
+	f, ok := pkg.Files[file]
+	if !ok {
+		return
+	}
+	tfile := pkg.Fset.File(f.Pos())
+	if tfile == nil {
+		return
+	}
+	at = &location{pkg: pkg, file: f, text: text, tfile: tfile}
+	off := offsetOf(text, pos)
+	if off > tfile.Size() {
+		return
+	}
+	tpos := tfile.Pos(off)
+	ast.Inspect(f, func(n ast.Node) bool {
+		if n == nil || tpos < n.Pos() || tpos > n.End() {
+			return false
+		}
+		if id, ok := n.(*ast.Ident); ok {
+			at.ident = id
+		}
+		return true
+	})
+	return
+}
+
+// tokenPos converts a byte offset of file into a token.Pos.
+func tokenPos(pkg *Package, file string, off int) token.Pos {
+	if f, ok := pkg.Files[file]; ok {
+		if tfile := pkg.Fset.File(f.Pos()); tfile != nil && off <= tfile.Size() {
+			return tfile.Pos(off)
+		}
+	}
+	return token.NoPos
+}
+
+// innermostScope returns the innermost scope of file which contains pos.
+func innermostScope(pkg *Package, file string, pos token.Pos) *types.Scope {
+	scope := pkg.Types.Scope()
+	if !pos.IsValid() {
+		return scope
+	}
+	f, ok := pkg.Files[file]
+	if !ok {
+		return scope
+	}
+	// Scopes of synthesized nodes (eg. the main func of a script file) may
+	// have no valid end, so they are considered to span to the end of file.
+	var innerPos, innerEnd token.Pos
+	for node, s := range pkg.Info.Scopes {
+		start, end := s.Pos(), s.End()
+		if !start.IsValid() {
+			start = node.Pos()
+		}
+		if !end.IsValid() {
+			end = f.End()
+		}
+		if start < f.Pos() || start > pos || pos > end || end > f.End() {
+			continue
+		}
+		if innerPos == token.NoPos || (start >= innerPos && end <= innerEnd) {
+			innerPos, innerEnd, scope = start, end, s
+		}
+	}
+	return scope
+}
+
+// identStart returns the start offset of the identifier which ends at off.
+func identStart(text []byte, off int) int {
+	for off > 0 {
+		r, n := utf8.DecodeLastRune(text[:off])
+		if r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			break
+		}
+		off -= n
+	}
+	return off
+}
+
+// -----------------------------------------------------------------------------
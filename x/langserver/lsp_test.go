@@ -0,0 +1,341 @@
+package langserver
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/goplus/gop/token"
+	"github.com/goplus/gop/x/jsonrpc2"
+	"github.com/goplus/gop/x/jsonrpc2/jsonrpc2test"
+)
+
+func init() {
+	if os.Getenv("GOPROOT") == "" {
+		dir, _ := os.Getwd()
+		os.Setenv("GOPROOT", filepath.Clean(filepath.Join(dir, "./../..")))
+	}
+}
+
+const testSrc = `import "strings"
+
+type Point struct {
+	X, Y int
+}
+
+func (p *Point) Len() int {
+	return p.X + p.Y
+}
+
+pt := &Point{1, 2}
+println strings.ToUpper("a"), pt.Len()
+`
+
+func dialTestServer(t *testing.T) (*jsonrpc2.Connection, func()) {
+	ctx := context.Background()
+	listener := jsonrpc2test.NetPipeListener()
+	server := NewServer(ctx, listener, nil)
+	conn, err := jsonrpc2.Dial(ctx, listener.Dialer(), jsonrpc2.BinderFunc(
+		func(ctx context.Context, c *jsonrpc2.Connection) (ret jsonrpc2.ConnectionOptions) {
+			return
+		}), nil)
+	if err != nil {
+		t.Fatal("jsonrpc2.Dial:", err)
+	}
+	return conn, func() {
+		conn.Close()
+		server.Shutdown()
+	}
+}
+
+func TestLSP(t *testing.T) {
+	ctx := context.Background()
+	conn, done := dialTestServer(t)
+	defer done()
+
+	var initRet InitializeResult
+	if err := conn.Call(ctx, methodInitialize, &InitializeParams{}).Await(ctx, &initRet); err != nil {
+		t.Fatal("initialize:", err)
+	}
+	if caps := initRet.Capabilities; !caps.HoverProvider || !caps.DefinitionProvider || caps.CompletionProvider == nil {
+		t.Fatal("initialize: unexpected capabilities -", caps)
+	}
+
+	dir := t.TempDir()
+	uri := uriOf(filepath.Join(dir, "main.gop"))
+	err := conn.Notify(ctx, methodDidOpen, &DidOpenTextDocumentParams{
+		TextDocument: TextDocumentItem{URI: uri, LanguageID: "gop", Version: 1, Text: testSrc},
+	})
+	if err != nil {
+		t.Fatal("didOpen:", err)
+	}
+
+	var hover Hover
+	err = conn.Call(ctx, methodHover, &TextDocumentPositionParams{
+		TextDocument: TextDocumentIdentifier{URI: uri},
+		Position:     Position{Line: 11, Character: 30},
+	}).Await(ctx, &hover)
+	if err != nil {
+		t.Fatal("hover:", err)
+	}
+	if !strings.Contains(hover.Contents.Value, "var pt *Point") {
+		t.Fatal("hover:", hover.Contents.Value)
+	}
+
+	var locs []Location
+	err = conn.Call(ctx, methodDefinition, &TextDocumentPositionParams{
+		TextDocument: TextDocumentIdentifier{URI: uri},
+		Position:     Position{Line: 10, Character: 9},
+	}).Await(ctx, &locs)
+	if err != nil {
+		t.Fatal("definition:", err)
+	}
+	if len(locs) != 1 || locs[0].URI != uri || locs[0].Range.Start != (Position{Line: 2, Character: 5}) {
+		t.Fatal("definition:", locs)
+	}
+
+	err = conn.Notify(ctx, methodDidChange, &DidChangeTextDocumentParams{
+		TextDocument: VersionedTextDocumentIdentifier{URI: uri, Version: 2},
+		ContentChanges: []TextDocumentContentChangeEvent{{
+			Range: &Range{Start: Position{Line: 11, Character: 0}, End: Position{Line: 12, Character: 0}},
+			Text:  "pt.Le\n",
+		}},
+	})
+	if err != nil {
+		t.Fatal("didChange:", err)
+	}
+	var list CompletionList
+	err = conn.Call(ctx, methodCompletion, &TextDocumentPositionParams{
+		TextDocument: TextDocumentIdentifier{URI: uri},
+		Position:     Position{Line: 11, Character: 5},
+	}).Await(ctx, &list)
+	if err != nil {
+		t.Fatal("completion:", err)
+	}
+	if len(list.Items) != 1 || list.Items[0].Label != "Len" || list.Items[0].Kind != CompletionMethod {
+		t.Fatal("completion:", list.Items)
+	}
+
+	var null any
+	if err = conn.Call(ctx, methodShutdown, nil).Await(ctx, &null); err != nil {
+		t.Fatal("shutdown:", err)
+	}
+}
+
+func TestPosition(t *testing.T) {
+	text := []byte("a := \"世界😀\"\nb")
+	for _, c := range []struct {
+		off int
+		pos Position
+	}{
+		{0, Position{0, 0}},
+		{6, Position{0, 6}},
+		{9, Position{0, 7}},
+		{16, Position{0, 10}},
+		{18, Position{1, 0}},
+	} {
+		if pos := positionOf(text, c.off); pos != c.pos {
+			t.Fatal("positionOf:", c.off, pos, c.pos)
+		}
+		if off := offsetOf(text, c.pos); off != c.off {
+			t.Fatal("offsetOf:", c.pos, off, c.off)
+		}
+	}
+	file := "/foo/bar baz.gop"
+	if uri := uriOf(file); uri != "file:///foo/bar%20baz.gop" || FilenameOf(uri) != file {
+		t.Fatal("uriOf:", uri)
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	ctx := context.Background()
+	listener := jsonrpc2test.NetPipeListener()
+	server := NewServer(ctx, listener, nil)
+	defer server.Shutdown()
+
+	diags := make(chan PublishDiagnosticsParams, 16)
+	conn, err := jsonrpc2.Dial(ctx, listener.Dialer(), jsonrpc2.BinderFunc(
+		func(ctx context.Context, c *jsonrpc2.Connection) (ret jsonrpc2.ConnectionOptions) {
+			ret.Handler = jsonrpc2.HandlerFunc(func(ctx context.Context, req *jsonrpc2.Request) (any, error) {
+				if req.Method == methodPublishDiagnostics {
+					var params PublishDiagnosticsParams
+					if err := json.Unmarshal(req.Params, &params); err != nil {
+						return nil, err
+					}
+					diags <- params
+				}
+				return nil, nil
+			})
+			return
+		}), nil)
+	if err != nil {
+		t.Fatal("jsonrpc2.Dial:", err)
+	}
+	defer conn.Close()
+
+	dir := t.TempDir()
+	uri := uriOf(filepath.Join(dir, "main.gop"))
+	err = conn.Notify(ctx, methodDidOpen, &DidOpenTextDocumentParams{
+		TextDocument: TextDocumentItem{URI: uri, LanguageID: "gop", Version: 3, Text: "a := 1\nb := a + \"x\"\n"},
+	})
+	if err != nil {
+		t.Fatal("didOpen:", err)
+	}
+	select {
+	case params := <-diags:
+		if params.URI != uri || params.Version != 3 || len(params.Diagnostics) == 0 {
+			t.Fatal("publishDiagnostics:", params)
+		}
+		if d := params.Diagnostics[0]; d.Range.Start.Line != 1 || d.Severity != SeverityError {
+			t.Fatal("publishDiagnostics:", d)
+		}
+	case <-time.After(time.Minute):
+		t.Fatal("publishDiagnostics: timeout")
+	}
+}
+
+func TestHalfTyped(t *testing.T) {
+	const src = `import "strings"
+
+type Point struct {
+	X, Y int
+}
+
+func (p *Point) Len() int {
+	s := strings.ToUpper(
+	return p.X + p.Y
+}
+
+func other() {
+}
+
+pt := &Point{1, 2}
+pt.Le
+`
+	dir := t.TempDir()
+	uri := uriOf(filepath.Join(dir, "main.gop"))
+	ws := newWorkspace()
+	ws.open(FilenameOf(uri), &document{uri: uri, text: []byte(src)})
+
+	syms, err := ws.DocumentSymbols(uri)
+	if err != nil {
+		t.Fatal("DocumentSymbols:", err)
+	}
+	var names []string
+	for _, sym := range syms {
+		names = append(names, sym.Name)
+	}
+	if strings.Join(names, " ") != "Point (*Point).Len other" {
+		t.Fatal("DocumentSymbols:", names)
+	}
+	list, err := ws.Completion(uri, Position{Line: 15, Character: 5})
+	if err != nil || list == nil || len(list.Items) != 1 || list.Items[0].Label != "Len" {
+		t.Fatal("Completion:", list, err)
+	}
+	diags, err := ws.Diagnostics(dir)
+	if err != nil || len(diags) != 1 || len(diags[0].Diagnostics) == 0 {
+		t.Fatal("Diagnostics:", diags, err)
+	}
+}
+
+func TestDropFiles(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "main.gop")
+	ws := newWorkspace()
+	ws.open(file, &document{uri: uriOf(file), text: []byte("echo 1\n")})
+	nfiles := func() (n int) {
+		ws.fset.Iterate(func(*token.File) bool {
+			n++
+			return true
+		})
+		return
+	}
+	var n int
+	for i := 0; i < 3; i++ {
+		if _, err := ws.check(dir); err != nil {
+			t.Fatal("check:", err)
+		}
+		ws.outline(dir)
+		if i == 0 {
+			n = nfiles()
+		} else if nfiles() != n {
+			t.Fatal("files of dropped packages are kept:", nfiles(), n)
+		}
+		ws.change(file, int32(i+1), []TextDocumentContentChangeEvent{{Text: "echo 2\n"}})
+	}
+}
+
+func TestInvalidateImports(t *testing.T) {
+	dir := writeTestFiles(t, map[string]string{
+		"go.mod":       "module example.com/foo\n\ngo 1.18\n",
+		"foo.go":       "package foo\n\nfunc Hello() string { return \"hi\" }\n",
+		"app/main.gop": "import \"example.com/foo\"\n\necho foo.Hello()\n",
+	})
+	app := filepath.Join(dir, "app")
+	ws := newWorkspace()
+	if deps := ws.dependents(dir, []string{dir, app}); len(deps) != 1 || deps[0] != app {
+		t.Fatal("dependents:", deps)
+	}
+	pkg, err := ws.check(app)
+	if err != nil || len(pkg.Errors) != 0 {
+		t.Fatal("check:", err, pkg.Errors)
+	}
+	src := "package foo\n\nfunc Hello(n int) string { return \"hi\" }\n"
+	if err = os.WriteFile(filepath.Join(dir, "foo.go"), []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ws.invalidateImports(dir)
+	if _, ok := ws.imps[dir]; ok {
+		t.Fatal("invalidateImports: the importer is kept")
+	}
+	pkg, err = ws.check(app)
+	if err != nil || len(pkg.Errors) == 0 {
+		t.Fatal("check: the change of foo is ignored")
+	}
+}
+
+func TestCheckConcurrently(t *testing.T) {
+	dir := writeTestFiles(t, map[string]string{
+		"go.mod": "module example.com/foo\n\ngo 1.18\n",
+		"lib/lib.gop": `package lib
+
+func Add = (
+	func(a, b int) int {
+		return a + b
+	}
+	func(a, b string) string {
+		return a + b
+	}
+)
+`,
+		"a/a.gop": "import \"example.com/foo/lib\"\n\necho lib.Add(1, 2)\n",
+		"b/b.gop": "import \"example.com/foo/lib\"\n\necho lib.Add(\"x\", \"y\")\n",
+	})
+	ws := newWorkspace()
+	a, b := filepath.Join(dir, "a"), filepath.Join(dir, "b")
+	var wg sync.WaitGroup
+	pkgs := make([]*Package, 4)
+	for i, d := range []string{a, b, a, b} {
+		wg.Add(1)
+		go func(i int, d string) {
+			defer wg.Done()
+			pkg, err := ws.check(d)
+			if err != nil || len(pkg.Errors) != 0 {
+				t.Error("check:", d, err, pkg.Errors)
+			}
+			pkgs[i] = pkg
+		}(i, d)
+	}
+	wg.Wait()
+	if t.Failed() {
+		return
+	}
+	if pkgs[0] != pkgs[2] || pkgs[1] != pkgs[3] {
+		t.Fatal("check: a directory is checked twice")
+	}
+}
@@ -0,0 +1,466 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package langserver
+
+// This file contains the subset of the Language Server Protocol used by the
+// Go+ LangServer.
+// see https://microsoft.github.io/language-server-protocol/specification
+
+// -----------------------------------------------------------------------------
+
+const (
+	methodInitialize  = "initialize"
+	methodInitialized = "initialized"
+	methodShutdown    = "shutdown"
+	methodExit        = "exit"
+
+	methodDidOpen   = "textDocument/didOpen"
+	methodDidChange = "textDocument/didChange"
+	methodDidClose  = "textDocument/didClose"
+	methodDidSave   = "textDocument/didSave"
+
+	methodPublishDiagnostics = "textDocument/publishDiagnostics"
+
+	methodHover      = "textDocument/hover"
+	methodDefinition = "textDocument/definition"
+	methodCompletion = "textDocument/completion"
+	methodRename     = "textDocument/rename"
+	methodReferences = "textDocument/references"
+
+	methodSignatureHelp = "textDocument/signatureHelp"
+	methodInlayHint     = "textDocument/inlayHint"
+
+	methodSemanticTokensFull = "textDocument/semanticTokens/full"
+
+	methodDocumentSymbol  = "textDocument/documentSymbol"
+	methodWorkspaceSymbol = "workspace/symbol"
+
+	methodPrepareCallHierarchy = "textDocument/prepareCallHierarchy"
+	methodIncomingCalls        = "callHierarchy/incomingCalls"
+	methodOutgoingCalls        = "callHierarchy/outgoingCalls"
+)
+
+// DocumentURI is the URI of a text document.
+type DocumentURI string
+
+// Position in a text document expressed as zero-based line and zero-based
+// character offset. The character offset is counted in UTF-16 code units.
+type Position struct {
+	Line      uint32 `json:"line"`
+	Character uint32 `json:"character"`
+}
+
+// Range in a text document expressed as (zero-based) start and end positions.
+type Range struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+// Location represents a location inside a resource.
+type Location struct {
+	URI   DocumentURI `json:"uri"`
+	Range Range       `json:"range"`
+}
+
+// TextDocumentIdentifier identifies a text document.
+type TextDocumentIdentifier struct {
+	URI DocumentURI `json:"uri"`
+}
+
+// VersionedTextDocumentIdentifier identifies a specific version of a text document.
+type VersionedTextDocumentIdentifier struct {
+	URI     DocumentURI `json:"uri"`
+	Version int32       `json:"version"`
+}
+
+// TextDocumentItem is an item to transfer a text document from the client to
+// the server.
+type TextDocumentItem struct {
+	URI        DocumentURI `json:"uri"`
+	LanguageID string      `json:"languageId"`
+	Version    int32       `json:"version"`
+	Text       string      `json:"text"`
+}
+
+// TextDocumentPositionParams is a parameter literal used in requests to pass
+// a text document and a position inside that document.
+type TextDocumentPositionParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	Position     Position               `json:"position"`
+}
+
+// -----------------------------------------------------------------------------
+
+// ClientInfo holds information about the client.
+type ClientInfo struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+// InitializeParams is the parameter of the `initialize` request.
+type InitializeParams struct {
+	ProcessID  int         `json:"processId,omitempty"`
+	ClientInfo *ClientInfo `json:"clientInfo,omitempty"`
+	RootURI    DocumentURI `json:"rootUri,omitempty"`
+}
+
+// ServerInfo holds information about the server.
+type ServerInfo struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+// TextDocumentSyncKind defines how the host (editor) should sync document changes
+// to the language server.
+type TextDocumentSyncKind int
+
+const (
+	SyncNone        TextDocumentSyncKind = 0
+	SyncFull        TextDocumentSyncKind = 1
+	SyncIncremental TextDocumentSyncKind = 2
+)
+
+// CompletionOptions holds the completion options of the server.
+type CompletionOptions struct {
+	TriggerCharacters []string `json:"triggerCharacters,omitempty"`
+}
+
+// ServerCapabilities defines the capabilities provided by the server.
+type ServerCapabilities struct {
+	TextDocumentSync   TextDocumentSyncKind `json:"textDocumentSync"`
+	HoverProvider      bool                 `json:"hoverProvider,omitempty"`
+	DefinitionProvider bool                 `json:"definitionProvider,omitempty"`
+	CompletionProvider *CompletionOptions   `json:"completionProvider,omitempty"`
+	RenameProvider     bool                 `json:"renameProvider,omitempty"`
+	ReferencesProvider bool                 `json:"referencesProvider,omitempty"`
+
+	CallHierarchyProvider bool `json:"callHierarchyProvider,omitempty"`
+
+	SignatureHelpProvider *SignatureHelpOptions `json:"signatureHelpProvider,omitempty"`
+	InlayHintProvider     bool                  `json:"inlayHintProvider,omitempty"`
+
+	SemanticTokensProvider *SemanticTokensOptions `json:"semanticTokensProvider,omitempty"`
+
+	DocumentSymbolProvider  bool `json:"documentSymbolProvider,omitempty"`
+	WorkspaceSymbolProvider bool `json:"workspaceSymbolProvider,omitempty"`
+}
+
+// SignatureHelpOptions is the server capability of signature help.
+type SignatureHelpOptions struct {
+	TriggerCharacters []string `json:"triggerCharacters,omitempty"`
+}
+
+// InitializeResult is the result of the `initialize` request.
+type InitializeResult struct {
+	Capabilities ServerCapabilities `json:"capabilities"`
+	ServerInfo   *ServerInfo        `json:"serverInfo,omitempty"`
+}
+
+// -----------------------------------------------------------------------------
+
+// DidOpenTextDocumentParams is the parameter of `textDocument/didOpen`.
+type DidOpenTextDocumentParams struct {
+	TextDocument TextDocumentItem `json:"textDocument"`
+}
+
+// TextDocumentContentChangeEvent is an event describing a change to a text
+// document. If Range is nil, Text is the full content of the document.
+type TextDocumentContentChangeEvent struct {
+	Range *Range `json:"range,omitempty"`
+	Text  string `json:"text"`
+}
+
+// DidChangeTextDocumentParams is the parameter of `textDocument/didChange`.
+type DidChangeTextDocumentParams struct {
+	TextDocument   VersionedTextDocumentIdentifier  `json:"textDocument"`
+	ContentChanges []TextDocumentContentChangeEvent `json:"contentChanges"`
+}
+
+// DidCloseTextDocumentParams is the parameter of `textDocument/didClose`.
+type DidCloseTextDocumentParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+}
+
+// DidSaveTextDocumentParams is the parameter of `textDocument/didSave`.
+type DidSaveTextDocumentParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+}
+
+// -----------------------------------------------------------------------------
+
+// MarkupContent represents a string value which content is interpreted based
+// on its kind flag.
+type MarkupContent struct {
+	Kind  string `json:"kind"`
+	Value string `json:"value"`
+}
+
+// Hover is the result of a hover request.
+type Hover struct {
+	Contents MarkupContent `json:"contents"`
+	Range    *Range        `json:"range,omitempty"`
+}
+
+// CompletionItemKind is the kind of a completion entry.
+type CompletionItemKind int
+
+const (
+	CompletionText      CompletionItemKind = 1
+	CompletionMethod    CompletionItemKind = 2
+	CompletionFunction  CompletionItemKind = 3
+	CompletionField     CompletionItemKind = 5
+	CompletionVariable  CompletionItemKind = 6
+	CompletionClass     CompletionItemKind = 7
+	CompletionInterface CompletionItemKind = 8
+	CompletionModule    CompletionItemKind = 9
+	CompletionKeyword   CompletionItemKind = 14
+	CompletionConstant  CompletionItemKind = 21
+	CompletionStruct    CompletionItemKind = 22
+	CompletionTypeParam CompletionItemKind = 25
+)
+
+// CompletionItem represents a completion entry.
+type CompletionItem struct {
+	Label  string             `json:"label"`
+	Kind   CompletionItemKind `json:"kind,omitempty"`
+	Detail string             `json:"detail,omitempty"`
+}
+
+// CompletionList represents a collection of completion items.
+type CompletionList struct {
+	IsIncomplete bool             `json:"isIncomplete"`
+	Items        []CompletionItem `json:"items"`
+}
+
+// -----------------------------------------------------------------------------
+
+// DiagnosticSeverity is the severity of a diagnostic.
+type DiagnosticSeverity int
+
+const (
+	SeverityError       DiagnosticSeverity = 1
+	SeverityWarning     DiagnosticSeverity = 2
+	SeverityInformation DiagnosticSeverity = 3
+	SeverityHint        DiagnosticSeverity = 4
+)
+
+// Diagnostic represents a diagnostic, such as a compiler error or warning.
+type Diagnostic struct {
+	Range    Range              `json:"range"`
+	Severity DiagnosticSeverity `json:"severity,omitempty"`
+	Code     string             `json:"code,omitempty"`
+	Source   string             `json:"source,omitempty"`
+	Message  string             `json:"message"`
+}
+
+// PublishDiagnosticsParams is the parameter of `textDocument/publishDiagnostics`.
+type PublishDiagnosticsParams struct {
+	URI         DocumentURI  `json:"uri"`
+	Version     int32        `json:"version,omitempty"`
+	Diagnostics []Diagnostic `json:"diagnostics"`
+}
+
+// -----------------------------------------------------------------------------
+
+// RenameParams is the parameter of `textDocument/rename`.
+type RenameParams struct {
+	TextDocumentPositionParams
+	NewName string `json:"newName"`
+}
+
+// TextEdit is a textual edit applicable to a text document.
+type TextEdit struct {
+	Range   Range  `json:"range"`
+	NewText string `json:"newText"`
+}
+
+// WorkspaceEdit represents changes to many resources managed in the workspace.
+type WorkspaceEdit struct {
+	Changes map[DocumentURI][]TextEdit `json:"changes"`
+}
+
+// -----------------------------------------------------------------------------
+
+// ReferenceContext controls the result of `textDocument/references`.
+type ReferenceContext struct {
+	IncludeDeclaration bool `json:"includeDeclaration"`
+}
+
+// ReferenceParams is the parameter of `textDocument/references`.
+type ReferenceParams struct {
+	TextDocumentPositionParams
+	Context ReferenceContext `json:"context"`
+}
+
+// SymbolKind is the kind of a symbol.
+type SymbolKind int
+
+const (
+	SymbolFile          SymbolKind = 1
+	SymbolModule        SymbolKind = 2
+	SymbolPackage       SymbolKind = 4
+	SymbolClass         SymbolKind = 5
+	SymbolMethod        SymbolKind = 6
+	SymbolField         SymbolKind = 8
+	SymbolInterface     SymbolKind = 11
+	SymbolFunction      SymbolKind = 12
+	SymbolVariable      SymbolKind = 13
+	SymbolConstant      SymbolKind = 14
+	SymbolStruct        SymbolKind = 23
+	SymbolTypeParameter SymbolKind = 26
+)
+
+// CallHierarchyItem represents a function in the call hierarchy.
+type CallHierarchyItem struct {
+	Name           string      `json:"name"`
+	Kind           SymbolKind  `json:"kind"`
+	Detail         string      `json:"detail,omitempty"`
+	URI            DocumentURI `json:"uri"`
+	Range          Range       `json:"range"`
+	SelectionRange Range       `json:"selectionRange"`
+}
+
+// CallHierarchyPrepareParams is the parameter of `textDocument/prepareCallHierarchy`.
+type CallHierarchyPrepareParams = TextDocumentPositionParams
+
+// CallHierarchyIncomingCallsParams is the parameter of `callHierarchy/incomingCalls`.
+type CallHierarchyIncomingCallsParams struct {
+	Item CallHierarchyItem `json:"item"`
+}
+
+// CallHierarchyIncomingCall is a caller of a function.
+type CallHierarchyIncomingCall struct {
+	From       CallHierarchyItem `json:"from"`
+	FromRanges []Range           `json:"fromRanges"` // calls in the caller
+}
+
+// CallHierarchyOutgoingCallsParams is the parameter of `callHierarchy/outgoingCalls`.
+type CallHierarchyOutgoingCallsParams struct {
+	Item CallHierarchyItem `json:"item"`
+}
+
+// CallHierarchyOutgoingCall is a function called by another one.
+type CallHierarchyOutgoingCall struct {
+	To         CallHierarchyItem `json:"to"`
+	FromRanges []Range           `json:"fromRanges"` // calls in the caller
+}
+
+// -----------------------------------------------------------------------------
+
+// ParameterInformation represents a parameter of a signature.
+type ParameterInformation struct {
+	Label string `json:"label"`
+}
+
+// SignatureInformation represents the signature of something callable.
+type SignatureInformation struct {
+	Label      string                 `json:"label"`
+	Parameters []ParameterInformation `json:"parameters"`
+}
+
+// SignatureHelp represents the signatures of the function being called.
+// For an overloaded function, there is a signature for each overload and
+// ActiveSignature is the one chosen by the compiler.
+type SignatureHelp struct {
+	Signatures      []SignatureInformation `json:"signatures"`
+	ActiveSignature uint32                 `json:"activeSignature"`
+	ActiveParameter uint32                 `json:"activeParameter"`
+}
+
+// InlayHintParams is the parameter of `textDocument/inlayHint`.
+type InlayHintParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	Range        Range                  `json:"range"`
+}
+
+// InlayHintKind is the kind of an inlay hint.
+type InlayHintKind int
+
+const (
+	InlayHintType      InlayHintKind = 1
+	InlayHintParameter InlayHintKind = 2
+)
+
+// InlayHint is an extra text shown inline in the source code.
+type InlayHint struct {
+	Position     Position      `json:"position"`
+	Label        string        `json:"label"`
+	Kind         InlayHintKind `json:"kind,omitempty"`
+	Tooltip      string        `json:"tooltip,omitempty"`
+	PaddingLeft  bool          `json:"paddingLeft,omitempty"`
+	PaddingRight bool          `json:"paddingRight,omitempty"`
+}
+
+// -----------------------------------------------------------------------------
+
+// SemanticTokensLegend declares the token types and modifiers of semantic
+// tokens. A token refers them by index.
+type SemanticTokensLegend struct {
+	TokenTypes     []string `json:"tokenTypes"`
+	TokenModifiers []string `json:"tokenModifiers"`
+}
+
+// SemanticTokensOptions is the server capability of semantic tokens.
+type SemanticTokensOptions struct {
+	Legend SemanticTokensLegend `json:"legend"`
+	Full   bool                 `json:"full,omitempty"`
+}
+
+// SemanticTokensParams is the parameter of `textDocument/semanticTokens/full`.
+type SemanticTokensParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+}
+
+// SemanticTokens is the result of `textDocument/semanticTokens/full`. Each
+// token takes 5 integers: deltaLine, deltaStartChar, length, tokenType and
+// tokenModifiers.
+type SemanticTokens struct {
+	Data []uint32 `json:"data"`
+}
+
+// -----------------------------------------------------------------------------
+
+// DocumentSymbolParams is the parameter of `textDocument/documentSymbol`.
+type DocumentSymbolParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+}
+
+// DocumentSymbol represents a declaration in a document. Symbols can be
+// hierarchical, eg. the overloads of a function or the methods of a class.
+type DocumentSymbol struct {
+	Name           string           `json:"name"`
+	Detail         string           `json:"detail,omitempty"`
+	Kind           SymbolKind       `json:"kind"`
+	Range          Range            `json:"range"`
+	SelectionRange Range            `json:"selectionRange"`
+	Children       []DocumentSymbol `json:"children,omitempty"`
+}
+
+// WorkspaceSymbolParams is the parameter of `workspace/symbol`.
+type WorkspaceSymbolParams struct {
+	Query string `json:"query"`
+}
+
+// SymbolInformation represents a declaration found by `workspace/symbol`.
+type SymbolInformation struct {
+	Name          string     `json:"name"`
+	Kind          SymbolKind `json:"kind"`
+	Location      Location   `json:"location"`
+	ContainerName string     `json:"containerName,omitempty"`
+}
+
+// -----------------------------------------------------------------------------
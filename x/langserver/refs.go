@@ -0,0 +1,499 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package langserver
+
+import (
+	goast "go/ast"
+	gotoken "go/token"
+	"go/types"
+	"path/filepath"
+
+	"github.com/goplus/gop/ast"
+	"github.com/goplus/gop/token"
+)
+
+// -----------------------------------------------------------------------------
+
+// Index answers cross-package queries about references and calls in the Go
+// and Go+ files of modules. It caches type-checked packages until they are
+// invalidated.
+type Index struct {
+	ws *workspace
+}
+
+// NewIndex creates a new Index.
+func NewIndex() *Index {
+	return &Index{ws: newWorkspace()}
+}
+
+// Invalidate drops the cached type information of dir, eg. when its files
+// are changed.
+func (p *Index) Invalidate(dir string) {
+	p.ws.invalidate(dir)
+}
+
+// References returns the locations of all references to the identifier at
+// pos in the module.
+func (p *Index) References(uri DocumentURI, pos Position, includeDecl bool) ([]Location, error) {
+	return p.ws.References(uri, pos, includeDecl)
+}
+
+// PrepareCallHierarchy returns the function at pos as a call hierarchy item.
+func (p *Index) PrepareCallHierarchy(uri DocumentURI, pos Position) ([]CallHierarchyItem, error) {
+	return p.ws.PrepareCallHierarchy(uri, pos)
+}
+
+// IncomingCalls returns the functions which call the function of item.
+func (p *Index) IncomingCalls(item CallHierarchyItem) ([]CallHierarchyIncomingCall, error) {
+	return p.ws.IncomingCalls(item)
+}
+
+// OutgoingCalls returns the functions called by the function of item.
+func (p *Index) OutgoingCalls(item CallHierarchyItem) ([]CallHierarchyOutgoingCall, error) {
+	return p.ws.OutgoingCalls(item)
+}
+
+// -----------------------------------------------------------------------------
+
+// References returns the locations of all references to the identifier at
+// pos in the module.
+func (p *workspace) References(uri DocumentURI, pos Position, includeDecl bool) (ret []Location, err error) {
+	_, sym, err := p.symbolAt(uri, pos)
+	if err != nil {
+		if err == ErrNoIdent {
+			err = nil
+		}
+		return
+	}
+	refs, err := p.references(sym)
+	if err != nil {
+		return
+	}
+	for _, file := range sortedFiles(refs) {
+		for _, r := range refs[file] {
+			if r.def && !includeDecl {
+				continue
+			}
+			if loc, ok := p.locationOf(p.fset, r.pos, len(r.name)); ok {
+				ret = append(ret, loc)
+			}
+		}
+	}
+	return
+}
+
+// funcNode is a function declared in a Go or Go+ file, or the initializer of
+// a package-level variable.
+type funcNode struct {
+	file    string
+	name    string
+	namePos token.Pos // NoPos for the entry of a script
+	pos     token.Pos
+	end     token.Pos
+	obj     types.Object
+	gop     *ast.FuncDecl
+	gof     *goast.FuncDecl
+	gopVar  *ast.ValueSpec
+	goVar   *goast.ValueSpec
+}
+
+// enclosingFunc returns the function declaration or the package-level
+// variable initializer of file which contains pos.
+func enclosingFunc(pkg *Package, file string, pos token.Pos) *funcNode {
+	if f, ok := pkg.Files[file]; ok {
+		for _, decl := range f.Decls {
+			if d, ok := decl.(*ast.GenDecl); ok {
+				if d.Tok != token.VAR {
+					continue
+				}
+				for _, spec := range d.Specs {
+					v := spec.(*ast.ValueSpec)
+					if len(v.Values) == 0 || pos < v.Pos() || pos >= v.End() {
+						continue
+					}
+					name := v.Names[0]
+					return &funcNode{
+						file: file, name: name.Name, namePos: name.Pos(), pos: v.Pos(), end: v.End(),
+						obj: pkg.Info.Defs[name], gopVar: v,
+					}
+				}
+				continue
+			}
+			d, ok := decl.(*ast.FuncDecl)
+			if !ok || d.Body == nil {
+				continue
+			}
+			start, end := d.Pos(), d.End()
+			if d.Shadow { // the entry of a script has no braces
+				list := d.Body.List
+				if len(list) == 0 {
+					continue
+				}
+				start, end = list[0].Pos(), list[len(list)-1].End()
+			}
+			if pos < start || pos >= end {
+				continue
+			}
+			fn := &funcNode{file: file, name: d.Name.Name, pos: start, end: end, gop: d}
+			if !d.Shadow {
+				fn.namePos = d.Name.Pos()
+			}
+			fn.obj = pkg.Info.Defs[d.Name]
+			return fn
+		}
+	} else if f, ok := pkg.GoFiles[file]; ok {
+		for _, decl := range f.Decls {
+			if d, ok := decl.(*goast.GenDecl); ok {
+				if d.Tok != gotoken.VAR {
+					continue
+				}
+				for _, spec := range d.Specs {
+					v := spec.(*goast.ValueSpec)
+					if len(v.Values) == 0 || pos < v.Pos() || pos >= v.End() {
+						continue
+					}
+					name := v.Names[0]
+					return &funcNode{
+						file: file, name: name.Name, namePos: name.Pos(), pos: v.Pos(), end: v.End(),
+						obj: pkg.GoInfo.Defs[name], goVar: v,
+					}
+				}
+				continue
+			}
+			d, ok := decl.(*goast.FuncDecl)
+			if !ok || d.Body == nil || pos < d.Pos() || pos >= d.End() {
+				continue
+			}
+			return &funcNode{
+				file: file, name: d.Name.Name, namePos: d.Name.Pos(), pos: d.Pos(), end: d.End(),
+				obj: pkg.GoInfo.Defs[d.Name], gof: d,
+			}
+		}
+	}
+	return nil
+}
+
+// callItem converts fn into a call hierarchy item.
+func (p *workspace) callItem(fn *funcNode) (item CallHierarchyItem, ok bool) {
+	text, err := p.content(fn.file)
+	if err != nil {
+		return
+	}
+	f := p.fset.File(fn.pos)
+	if f == nil {
+		return
+	}
+	item = CallHierarchyItem{
+		Name:  fn.name,
+		Kind:  SymbolFunction,
+		URI:   uriOf(fn.file),
+		Range: Range{Start: positionOf(text, f.Offset(fn.pos)), End: positionOf(text, f.Offset(fn.end))},
+	}
+	if fn.namePos.IsValid() {
+		off := f.Offset(fn.namePos)
+		item.SelectionRange = Range{Start: positionOf(text, off), End: positionOf(text, off+len(fn.name))}
+	} else {
+		item.SelectionRange = Range{Start: item.Range.Start, End: item.Range.Start}
+	}
+	if fn.gopVar != nil || fn.goVar != nil {
+		item.Kind = SymbolVariable
+	}
+	if fn.obj != nil {
+		if sig, ok := fn.obj.Type().(*types.Signature); ok && sig.Recv() != nil {
+			item.Kind = SymbolMethod
+		}
+		item.Detail = objectString(fn.obj.Pkg(), fn.obj)
+	}
+	return item, true
+}
+
+// declOf returns the function declaration of sym.
+func (p *workspace) declOf(sym *symbol) (pkg *Package, fn *funcNode) {
+	pkg, err := p.check(sym.dir)
+	if err != nil {
+		return
+	}
+	for _, r := range refsOf(pkg, sym) {
+		if !r.def {
+			continue
+		}
+		if f := p.fset.File(r.pos); f != nil {
+			if fn = enclosingFunc(pkg, f.Name(), r.pos); fn != nil && fn.namePos == r.pos {
+				return
+			}
+		}
+	}
+	return pkg, nil
+}
+
+// funcSymbolAt returns the symbol of the function at pos of uri.
+func (p *workspace) funcSymbolAt(uri DocumentURI, pos Position) (sym *symbol, err error) {
+	_, sym, err = p.symbolAt(uri, pos)
+	if err != nil {
+		if err == ErrNoIdent {
+			err = nil
+		}
+		return
+	}
+	if _, ok := sym.obj.(*types.Func); !ok {
+		return nil, nil
+	}
+	return
+}
+
+// PrepareCallHierarchy returns the function at pos as a call hierarchy item.
+func (p *workspace) PrepareCallHierarchy(uri DocumentURI, pos Position) (ret []CallHierarchyItem, err error) {
+	sym, err := p.funcSymbolAt(uri, pos)
+	if err != nil || sym == nil {
+		return
+	}
+	if _, fn := p.declOf(sym); fn != nil {
+		if item, ok := p.callItem(fn); ok {
+			ret = append(ret, item)
+		}
+	}
+	return
+}
+
+// IncomingCalls returns the functions which call the function of item.
+func (p *workspace) IncomingCalls(item CallHierarchyItem) (ret []CallHierarchyIncomingCall, err error) {
+	sym, err := p.funcSymbolAt(item.URI, item.SelectionRange.Start)
+	if err != nil || sym == nil {
+		return
+	}
+	refs, err := p.references(sym)
+	if err != nil {
+		return
+	}
+	callers := make(map[token.Pos]int)              // position of the caller => index of ret
+	sites := make(map[token.Pos]map[token.Pos]bool) // position of the caller => its callSites
+	for _, file := range sortedFiles(refs) {
+		pkg, e := p.check(filepath.Dir(file))
+		if e != nil {
+			continue
+		}
+		for _, r := range refs[file] {
+			if r.def {
+				continue
+			}
+			fn := enclosingFunc(pkg, file, r.pos)
+			if fn == nil {
+				continue
+			}
+			calls, ok := sites[fn.pos]
+			if !ok {
+				calls = callSites(pkg, fn)
+				sites[fn.pos] = calls
+			}
+			if !calls[r.pos] { // eg. a function value, not a call
+				continue
+			}
+			loc, ok := p.locationOf(p.fset, r.pos, len(r.name))
+			if !ok {
+				continue
+			}
+			i, ok := callers[fn.pos]
+			if !ok {
+				from, ok := p.callItem(fn)
+				if !ok {
+					continue
+				}
+				i = len(ret)
+				callers[fn.pos] = i
+				ret = append(ret, CallHierarchyIncomingCall{From: from})
+			}
+			ret[i].FromRanges = append(ret[i].FromRanges, loc.Range)
+		}
+	}
+	return
+}
+
+// OutgoingCalls returns the functions called by the function of item.
+func (p *workspace) OutgoingCalls(item CallHierarchyItem) (ret []CallHierarchyOutgoingCall, err error) {
+	sym, err := p.funcSymbolAt(item.URI, item.SelectionRange.Start)
+	if err != nil || sym == nil {
+		return
+	}
+	pkg, fn := p.declOf(sym)
+	if fn == nil {
+		return
+	}
+	type call struct {
+		id  string // name of the callee
+		pos token.Pos
+		obj types.Object
+	}
+	var calls []call
+	if fn.gop != nil {
+		gopCallees(pkg, fn.gop.Body, func(id *ast.Ident, obj types.Object) {
+			calls = append(calls, call{id.Name, id.Pos(), obj})
+		})
+	} else {
+		goCallees(fn.gof.Body, func(id *goast.Ident) {
+			calls = append(calls, call{id.Name, id.Pos(), pkg.GoInfo.Uses[id]})
+		})
+	}
+	callees := make(map[string]int) // key of the callee => index of ret
+	for _, c := range calls {
+		if _, ok := c.obj.(*types.Func); !ok {
+			continue
+		}
+		key := keyOf(c.obj)
+		if key == "" {
+			continue
+		}
+		loc, ok := p.locationOf(p.fset, c.pos, len(c.id))
+		if !ok {
+			continue
+		}
+		i, ok := callees[key]
+		if !ok {
+			to, ok := p.calleeItem(pkg, c.obj)
+			if !ok {
+				continue
+			}
+			i = len(ret)
+			callees[key] = i
+			ret = append(ret, CallHierarchyOutgoingCall{To: to})
+		}
+		ret[i].FromRanges = append(ret[i].FromRanges, loc.Range)
+	}
+	return
+}
+
+// calleeItem returns the call hierarchy item of a function used by pkg.
+func (p *workspace) calleeItem(pkg *Package, obj types.Object) (item CallHierarchyItem, ok bool) {
+	sym, err := p.symbolOf(pkg, obj)
+	if err == nil {
+		if _, fn := p.declOf(sym); fn != nil {
+			return p.callItem(fn)
+		}
+	}
+	// the function is declared outside the module
+	loc, ok := p.locationOf(p.fset, obj.Pos(), len(obj.Name()))
+	if !ok {
+		return
+	}
+	item = CallHierarchyItem{
+		Name: obj.Name(), Kind: SymbolFunction, Detail: objectString(nil, obj),
+		URI: loc.URI, Range: loc.Range, SelectionRange: loc.Range,
+	}
+	if sig, ok := obj.Type().(*types.Signature); ok && sig.Recv() != nil {
+		item.Kind = SymbolMethod
+	}
+	return item, true
+}
+
+// callSites returns the positions of the callee identifiers of the calls in
+// the body or the initializer of fn.
+func callSites(pkg *Package, fn *funcNode) map[token.Pos]bool {
+	ret := make(map[token.Pos]bool)
+	addGop := func(id *ast.Ident, _ types.Object) {
+		ret[id.Pos()] = true
+	}
+	addGo := func(id *goast.Ident) {
+		ret[id.Pos()] = true
+	}
+	switch {
+	case fn.gop != nil:
+		gopCallees(pkg, fn.gop.Body, addGop)
+	case fn.gopVar != nil:
+		gopCallees(pkg, fn.gopVar, addGop)
+	case fn.gof != nil:
+		goCallees(fn.gof.Body, addGo)
+	case fn.goVar != nil:
+		goCallees(fn.goVar, addGo)
+	}
+	return ret
+}
+
+// gopCallees calls f with the callee identifier and its object of each call
+// in node, including a command-style call without arguments, eg. `foo` as a
+// statement.
+func gopCallees(pkg *Package, node ast.Node, f func(id *ast.Ident, obj types.Object)) {
+	objOf := func(id *ast.Ident) types.Object {
+		if o, _ := pkg.Info.OverloadOf(id); o != nil {
+			return o
+		}
+		return pkg.Info.Uses[id]
+	}
+	ast.Inspect(node, func(n ast.Node) bool {
+		switch v := n.(type) {
+		case *ast.CallExpr:
+			if id := calleeIdent(v.Fun); id != nil {
+				f(id, objOf(id))
+			}
+		case *ast.ExprStmt:
+			switch x := v.X.(type) {
+			case *ast.Ident, *ast.SelectorExpr:
+				id := calleeIdent(x)
+				if obj := objOf(id); obj != nil {
+					if _, ok := obj.(*types.Func); ok {
+						f(id, obj)
+					}
+				}
+			}
+		}
+		return true
+	})
+}
+
+// goCallees calls f with the callee identifier of each call in node.
+func goCallees(node goast.Node, f func(id *goast.Ident)) {
+	goast.Inspect(node, func(n goast.Node) bool {
+		if c, ok := n.(*goast.CallExpr); ok {
+			if id := goCalleeIdent(c.Fun); id != nil {
+				f(id)
+			}
+		}
+		return true
+	})
+}
+
+func calleeIdent(fun ast.Expr) *ast.Ident {
+	switch v := fun.(type) {
+	case *ast.Ident:
+		return v
+	case *ast.SelectorExpr:
+		return v.Sel
+	case *ast.IndexExpr:
+		return calleeIdent(v.X)
+	case *ast.IndexListExpr:
+		return calleeIdent(v.X)
+	case *ast.ParenExpr:
+		return calleeIdent(v.X)
+	}
+	return nil
+}
+
+func goCalleeIdent(fun goast.Expr) *goast.Ident {
+	switch v := fun.(type) {
+	case *goast.Ident:
+		return v
+	case *goast.SelectorExpr:
+		return v.Sel
+	case *goast.IndexExpr:
+		return goCalleeIdent(v.X)
+	case *goast.IndexListExpr:
+		return goCalleeIdent(v.X)
+	case *goast.ParenExpr:
+		return goCalleeIdent(v.X)
+	}
+	return nil
+}
+
+// -----------------------------------------------------------------------------
@@ -0,0 +1,137 @@
+package langserver
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestCallHierarchy(t *testing.T) {
+	dir := writeTestFiles(t, map[string]string{
+		"go.mod": "module example.com/foo\n\ngo 1.18\n",
+		"foo.go": `package foo
+
+func Hello() string {
+	return helper()
+}
+
+func helper() string {
+	return "hi"
+}
+
+func helperFunc() func() string {
+	return helper
+}
+`,
+		"app/main.gop": `import "example.com/foo"
+
+func greet() {
+	echo foo.hello()
+}
+
+greet
+echo foo.Hello()
+`,
+	})
+	idx := NewIndex()
+	foo := uriOf(filepath.Join(dir, "foo.go"))
+	main := uriOf(filepath.Join(dir, "app", "main.gop"))
+
+	locs, err := idx.References(foo, Position{Line: 2, Character: 6}, true)
+	if err != nil || len(locs) != 3 {
+		t.Fatal("References:", locs, err)
+	}
+	if locs[0].URI != main || locs[0].Range.Start != (Position{Line: 3, Character: 10}) || locs[2].URI != foo {
+		t.Fatal("References:", locs)
+	}
+	if locs, err = idx.References(foo, Position{Line: 2, Character: 6}, false); err != nil || len(locs) != 2 {
+		t.Fatal("References:", locs, err)
+	}
+
+	items, err := idx.PrepareCallHierarchy(main, Position{Line: 3, Character: 11})
+	if err != nil || len(items) != 1 || items[0].Name != "Hello" || items[0].URI != foo {
+		t.Fatal("PrepareCallHierarchy:", items, err)
+	}
+	in, err := idx.IncomingCalls(items[0])
+	if err != nil || len(in) != 2 || in[0].From.Name != "greet" || in[1].From.Name != "main" {
+		t.Fatal("IncomingCalls:", in, err)
+	}
+	out, err := idx.OutgoingCalls(items[0])
+	if err != nil || len(out) != 1 || out[0].To.Name != "helper" || len(out[0].FromRanges) != 1 {
+		t.Fatal("OutgoingCalls:", out, err)
+	}
+	out, err = idx.OutgoingCalls(in[0].From)
+	if err != nil || len(out) != 1 || out[0].To.Name != "Hello" {
+		t.Fatal("OutgoingCalls:", out, err)
+	}
+
+	// a function value is not a call
+	items, err = idx.PrepareCallHierarchy(foo, Position{Line: 6, Character: 6})
+	if err != nil || len(items) != 1 || items[0].Name != "helper" {
+		t.Fatal("PrepareCallHierarchy:", items, err)
+	}
+	if in, err = idx.IncomingCalls(items[0]); err != nil || len(in) != 1 || in[0].From.Name != "Hello" {
+		t.Fatal("IncomingCalls:", in, err)
+	}
+}
+
+func TestCallHierarchyCommandAndVar(t *testing.T) {
+	dir := writeTestFiles(t, map[string]string{
+		"go.mod": "module example.com/foo\n\ngo 1.18\n",
+		"foo.go": `package foo
+
+var Greeting = Hello()
+
+func Hello() string {
+	return "hi"
+}
+`,
+		"app/main.gop": `import "example.com/foo"
+
+var msg = foo.Hello()
+
+func greet() {
+	echo msg
+}
+
+greet
+foo.Hello
+`,
+	})
+	idx := NewIndex()
+	foo := uriOf(filepath.Join(dir, "foo.go"))
+	main := uriOf(filepath.Join(dir, "app", "main.gop"))
+
+	// a command-style call without arguments
+	items, err := idx.PrepareCallHierarchy(main, Position{Line: 4, Character: 6})
+	if err != nil || len(items) != 1 || items[0].Name != "greet" {
+		t.Fatal("PrepareCallHierarchy:", items, err)
+	}
+	in, err := idx.IncomingCalls(items[0])
+	if err != nil || len(in) != 1 || in[0].From.Name != "main" ||
+		len(in[0].FromRanges) != 1 || in[0].FromRanges[0].Start != (Position{Line: 8, Character: 0}) {
+		t.Fatal("IncomingCalls:", in, err)
+	}
+
+	// calls in the initializers of package-level variables
+	items, err = idx.PrepareCallHierarchy(foo, Position{Line: 4, Character: 6})
+	if err != nil || len(items) != 1 || items[0].Name != "Hello" {
+		t.Fatal("PrepareCallHierarchy:", items, err)
+	}
+	in, err = idx.IncomingCalls(items[0])
+	if err != nil || len(in) != 3 {
+		t.Fatal("IncomingCalls:", in, err)
+	}
+	names := make(map[string]CallHierarchyItem)
+	for _, c := range in {
+		names[c.From.Name] = c.From
+	}
+	if v, ok := names["msg"]; !ok || v.Kind != SymbolVariable || v.URI != main {
+		t.Fatal("IncomingCalls:", in)
+	}
+	if v, ok := names["Greeting"]; !ok || v.Kind != SymbolVariable || v.URI != foo {
+		t.Fatal("IncomingCalls:", in)
+	}
+	if v, ok := names["main"]; !ok || v.URI != main { // foo.Hello
+		t.Fatal("IncomingCalls:", in)
+	}
+}
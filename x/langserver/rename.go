@@ -0,0 +1,627 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package langserver
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	goast "go/ast"
+	"go/types"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/goplus/gop/ast"
+	"github.com/goplus/gop/token"
+)
+
+var (
+	ErrNoIdent = errors.New("no identifier found at the position")
+)
+
+// -----------------------------------------------------------------------------
+
+// symbol identifies an object across packages which are type-checked
+// separately, so the same object may be represented by different
+// types.Object values.
+type symbol struct {
+	key   string       // "pkgPath.[Recv.]name" of a package-level object, a method or a field
+	local types.Object // object declared in a function, if key is empty
+	name  string       // name of the object, without the overload suffix
+	dir   string       // directory of the package which declares the object
+	obj   types.Object // the object as seen by the package where it's looked up
+}
+
+// ref is a reference to a symbol.
+type ref struct {
+	pos  token.Pos
+	name string // text of the identifier
+	def  bool   // if set, the reference is the declaration of the symbol
+}
+
+// symbolAt returns the symbol denoted by the identifier at pos of uri.
+func (p *workspace) symbolAt(uri DocumentURI, pos Position) (pkg *Package, sym *symbol, err error) {
+	file := FilenameOf(uri)
+	text, err := p.content(file)
+	if err != nil {
+		return
+	}
+	pkg, err = p.check(filepath.Dir(file))
+	if err != nil {
+		return
+	}
+	obj := objectAt(pkg, file, offsetOf(text, pos))
+	if obj == nil {
+		return nil, nil, ErrNoIdent
+	}
+	sym, err = p.symbolOf(pkg, obj)
+	return
+}
+
+// objectAt returns the object denoted by the identifier at offset off of file,
+// which is a Go or Go+ file of pkg.
+func objectAt(pkg *Package, file string, off int) (obj types.Object) {
+	if f, ok := pkg.Files[file]; ok {
+		tpos := tokenPos(pkg, file, off)
+		ast.Inspect(f, func(n ast.Node) bool {
+			if n == nil || tpos < n.Pos() || tpos > n.End() {
+				return false
+			}
+			if id, ok := n.(*ast.Ident); ok {
+				if o, _ := pkg.Info.OverloadOf(id); o != nil {
+					obj = o
+				} else {
+					obj = pkg.Info.ObjectOf(id)
+				}
+			}
+			return true
+		})
+	} else if f, ok := pkg.GoFiles[file]; ok {
+		tfile := pkg.Fset.File(f.Pos())
+		if tfile == nil || off > tfile.Size() {
+			return
+		}
+		tpos := tfile.Pos(off)
+		goast.Inspect(f, func(n goast.Node) bool {
+			if n == nil || tpos < n.Pos() || tpos > n.End() {
+				return false
+			}
+			if id, ok := n.(*goast.Ident); ok {
+				obj = pkg.GoInfo.ObjectOf(id)
+			}
+			return true
+		})
+	}
+	return
+}
+
+// symbolOf returns the symbol of obj, which is used by pkg.
+func (p *workspace) symbolOf(pkg *Package, obj types.Object) (sym *symbol, err error) {
+	if obj.Pkg() == nil {
+		return nil, fmt.Errorf("%s is predeclared", obj.Name())
+	}
+	sym = &symbol{key: keyOf(obj), name: baseName(obj.Name()), dir: pkg.Dir, obj: obj}
+	if sym.key == "" {
+		sym.local = obj
+		return
+	}
+	if path := obj.Pkg().Path(); path != pkg.Types.Path() {
+		if sym.dir = p.dirOfPkg(pkg.Dir, path); sym.dir == "" {
+			return nil, fmt.Errorf("%s is declared in package %s, which is outside the module", obj.Name(), path)
+		}
+	}
+	return
+}
+
+// keyOf returns the key of a package-level object, a method or a field.
+// It returns "" for other objects.
+func keyOf(obj types.Object) string {
+	pkg := obj.Pkg()
+	if pkg == nil {
+		return ""
+	}
+	name := baseName(obj.Name())
+	switch v := obj.(type) {
+	case *types.PkgName:
+		return ""
+	case *types.Func:
+		if sig, ok := v.Type().(*types.Signature); ok && sig.Recv() != nil {
+			if recv := typeNameOf(sig.Recv().Type()); recv != "" {
+				return pkg.Path() + "." + recv + "." + name
+			}
+			return ""
+		}
+	case *types.Var:
+		if v.IsField() {
+			if owner := fieldOwner(v); owner != "" {
+				return pkg.Path() + "." + owner + "." + name
+			}
+			return ""
+		}
+	}
+	if obj.Parent() == pkg.Scope() {
+		return pkg.Path() + "." + name
+	}
+	return ""
+}
+
+// typeNameOf returns the name of a named type or a pointer to a named type.
+func typeNameOf(typ types.Type) string {
+	if t, ok := typ.(*types.Pointer); ok {
+		typ = t.Elem()
+	}
+	if t, ok := typ.(*types.Named); ok {
+		return t.Obj().Name()
+	}
+	return ""
+}
+
+// fieldOwner returns the name of the package-level struct type which declares
+// the field v.
+func fieldOwner(v *types.Var) string {
+	scope := v.Pkg().Scope()
+	for _, name := range scope.Names() {
+		tn, ok := scope.Lookup(name).(*types.TypeName)
+		if !ok {
+			continue
+		}
+		if t, ok := tn.Type().Underlying().(*types.Struct); ok {
+			for i, n := 0, t.NumFields(); i < n; i++ {
+				if t.Field(i) == v {
+					return name
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// baseName strips the overload suffix `__N` of a function name.
+func baseName(name string) string {
+	if pos := strings.LastIndex(name, "__"); pos > 0 {
+		if suffix := name[pos+2:]; suffix != "" && strings.Trim(suffix, "0123456789") == "" {
+			return name[:pos]
+		}
+	}
+	return name
+}
+
+// dirOfPkg returns the directory of the package path in the module which dir
+// belongs to, or "" if the package isn't in the module.
+func (p *workspace) dirOfPkg(dir, path string) string {
+	p.mutex.Lock()
+	mod, err := p.loadMod(dir)
+	p.mutex.Unlock()
+	if err != nil || !mod.HasModfile() {
+		return ""
+	}
+	modPath := mod.Path()
+	if path == modPath {
+		return mod.Root()
+	}
+	if strings.HasPrefix(path, modPath+"/") {
+		return filepath.Join(mod.Root(), filepath.FromSlash(path[len(modPath)+1:]))
+	}
+	return ""
+}
+
+// moduleDirs returns all directories of the module which dir belongs to,
+// excluding testdata, hidden directories and nested modules.
+func (p *workspace) moduleDirs(dir string) (dirs []string) {
+	p.mutex.Lock()
+	mod, err := p.loadMod(dir)
+	p.mutex.Unlock()
+	if err != nil || !mod.HasModfile() {
+		return []string{dir}
+	}
+	root := mod.Root()
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+		if path != root {
+			name := d.Name()
+			if name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") {
+				return filepath.SkipDir
+			}
+			if isModRoot(path) {
+				return filepath.SkipDir
+			}
+		}
+		dirs = append(dirs, path)
+		return nil
+	})
+	return
+}
+
+func isModRoot(dir string) bool {
+	for _, name := range []string{"go.mod", "gop.mod"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// refDirs returns the directories of the packages which may refer to sym.
+func (p *workspace) refDirs(sym *symbol) (dirs []string) {
+	if sym.local != nil {
+		return []string{sym.dir}
+	}
+	for _, dir := range p.moduleDirs(sym.dir) {
+		if dir == sym.dir {
+			dirs = append(dirs, dir)
+			continue
+		}
+		for _, dep := range p.localDeps(dir) {
+			if dep == sym.dir {
+				dirs = append(dirs, dir)
+				break
+			}
+		}
+	}
+	return
+}
+
+// refsOf returns all references to sym in the Go and Go+ files of pkg.
+func refsOf(pkg *Package, sym *symbol) (refs []ref) {
+	if sym.local == nil {
+		return pkg.refIndex()[sym.key]
+	}
+	for id, obj := range pkg.Info.Defs {
+		if obj == sym.local {
+			refs = append(refs, ref{pos: id.Pos(), name: id.Name, def: true})
+		}
+	}
+	for id, obj := range pkg.Info.Uses {
+		if obj == sym.local {
+			refs = append(refs, ref{pos: id.Pos(), name: id.Name})
+		}
+	}
+	for id, obj := range pkg.GoInfo.Defs {
+		if obj == sym.local {
+			refs = append(refs, ref{pos: id.Pos(), name: id.Name, def: true})
+		}
+	}
+	for id, obj := range pkg.GoInfo.Uses {
+		if obj == sym.local {
+			refs = append(refs, ref{pos: id.Pos(), name: id.Name})
+		}
+	}
+	sortRefs(refs)
+	return
+}
+
+// refIndex returns the references to package-level objects, methods and
+// fields in pkg, keyed by keyOf. It is built on first use.
+func (pkg *Package) refIndex() map[string][]ref {
+	pkg.refOnce.Do(func() {
+		refs := make(map[string][]ref)
+		add := func(obj types.Object, r ref) {
+			if obj == nil {
+				return
+			}
+			if key := keyOf(obj); key != "" {
+				refs[key] = append(refs[key], r)
+			}
+		}
+		for id, obj := range pkg.Info.Defs {
+			add(obj, ref{pos: id.Pos(), name: id.Name, def: true})
+		}
+		for id, obj := range pkg.Info.Uses {
+			add(obj, ref{pos: id.Pos(), name: id.Name})
+		}
+		for id, obj := range pkg.GoInfo.Defs {
+			add(obj, ref{pos: id.Pos(), name: id.Name, def: true})
+		}
+		for id, obj := range pkg.GoInfo.Uses {
+			add(obj, ref{pos: id.Pos(), name: id.Name})
+		}
+		for _, list := range refs {
+			sortRefs(list)
+		}
+		pkg.refs = refs
+	})
+	return pkg.refs
+}
+
+func sortRefs(refs []ref) {
+	sort.Slice(refs, func(i, j int) bool {
+		return refs[i].pos < refs[j].pos
+	})
+}
+
+// references returns all references to sym in the module, grouped by file.
+// References which are synthesized by the compiler (eg. names generated for
+// classfiles) are skipped.
+func (p *workspace) references(sym *symbol) (ret map[string][]ref, err error) {
+	ret = make(map[string][]ref)
+	seen := make(map[token.Pos]bool)
+	for _, dir := range p.refDirs(sym) {
+		pkg, e := p.check(dir)
+		if e != nil {
+			if dir == sym.dir {
+				return nil, e
+			}
+			continue
+		}
+		for _, r := range refsOf(pkg, sym) {
+			if seen[r.pos] {
+				continue
+			}
+			seen[r.pos] = true
+			f := pkg.Fset.File(r.pos)
+			if f == nil || !filepath.IsAbs(f.Name()) {
+				continue
+			}
+			file := f.Name()
+			text, e := p.content(file)
+			if e != nil {
+				continue
+			}
+			off := f.Offset(r.pos)
+			if off+len(r.name) > len(text) || string(text[off:off+len(r.name)]) != r.name {
+				continue // not in the source
+			}
+			ret[file] = append(ret[file], r)
+		}
+	}
+	return
+}
+
+// -----------------------------------------------------------------------------
+
+// Rename renames the identifier at pos and all references to it in the module.
+func (p *workspace) Rename(uri DocumentURI, pos Position, newName string) (ret *WorkspaceEdit, err error) {
+	pkg, sym, err := p.symbolAt(uri, pos)
+	if err != nil {
+		return
+	}
+	newBase := baseName(newName)
+	if !token.IsIdentifier(newBase) || newBase == "_" {
+		return nil, fmt.Errorf("invalid identifier: %s", newName)
+	}
+	if newBase == sym.name {
+		return &WorkspaceEdit{Changes: map[DocumentURI][]TextEdit{}}, nil
+	}
+	if err = p.checkConflict(pkg, sym, newBase); err != nil {
+		return
+	}
+	refs, err := p.references(sym)
+	if err != nil {
+		return
+	}
+	declared := false
+	for _, list := range refs {
+		for _, r := range list {
+			if r.def {
+				declared = true
+			}
+		}
+	}
+	if !declared {
+		return nil, fmt.Errorf("cannot rename %s: it is generated by the compiler, eg. the class of a classfile", sym.name)
+	}
+	ret = &WorkspaceEdit{Changes: make(map[DocumentURI][]TextEdit, len(refs))}
+	for file, list := range refs {
+		text, e := p.content(file)
+		if e != nil {
+			return nil, e
+		}
+		f := pkg.Fset.File(list[0].pos)
+		if f == nil { // the package is dropped by a change meanwhile
+			return nil, fmt.Errorf("%s is changed while renaming", file)
+		}
+		edits := make([]TextEdit, 0, len(list))
+		for _, r := range list {
+			off := f.Offset(r.pos)
+			edits = append(edits, TextEdit{
+				Range:   Range{Start: positionOf(text, off), End: positionOf(text, off+len(r.name))},
+				NewText: renameIdent(r.name, sym.name, newBase),
+			})
+		}
+		ret.Changes[uriOf(file)] = edits
+	}
+	return
+}
+
+// checkConflict reports an error if renaming sym to newName makes it conflict
+// with another object.
+func (p *workspace) checkConflict(pkg *Package, sym *symbol, newName string) error {
+	if sym.local != nil {
+		return checkLocalConflict(pkg, sym, newName)
+	}
+	declPkg := pkg
+	if sym.dir != pkg.Dir {
+		var err error
+		if declPkg, err = p.check(sym.dir); err != nil {
+			return err
+		}
+	}
+	key := strings.TrimPrefix(sym.key, declPkg.Types.Path()+".")
+	scope := declPkg.Types.Scope()
+	if pos := strings.IndexByte(key, '.'); pos > 0 { // method or field
+		if tn, ok := scope.Lookup(key[:pos]).(*types.TypeName); ok {
+			if obj, _, _ := types.LookupFieldOrMethod(tn.Type(), true, declPkg.Types, newName); obj != nil {
+				return fmt.Errorf("cannot rename %s: %s.%s is already declared", sym.name, key[:pos], newName)
+			}
+		}
+		return nil
+	}
+	if scope.Lookup(newName) != nil {
+		return fmt.Errorf("cannot rename %s: %s is already declared in package %s", sym.name, newName, declPkg.Types.Name())
+	}
+	return nil
+}
+
+// checkLocalConflict reports an error if renaming the local object of sym to
+// newName conflicts with a declaration in its block, shadows it in an inner
+// block, or captures a reference to another object named newName.
+func checkLocalConflict(pkg *Package, sym *symbol, newName string) error {
+	scope := sym.local.Parent()
+	if scope == nil {
+		return nil
+	}
+	if scope.Lookup(newName) != nil {
+		return fmt.Errorf("cannot rename %s: %s is already declared in this block", sym.name, newName)
+	}
+	if declaredInner(scope, newName) {
+		return fmt.Errorf("cannot rename %s: %s is declared in an inner block", sym.name, newName)
+	}
+	start, end := scope.Pos(), scope.End()
+	if !start.IsValid() {
+		node := scopeNode(pkg, scope)
+		if node == nil {
+			return nil
+		}
+		start, end = node.Pos(), node.End()
+	}
+	captured := func(pos token.Pos, obj types.Object) bool {
+		return obj != nil && obj.Parent() != nil && start <= pos && pos < end &&
+			!scopeWithin(obj.Parent(), scope)
+	}
+	for id, obj := range pkg.Info.Uses {
+		if id.Name == newName && captured(id.Pos(), obj) {
+			return fmt.Errorf("cannot rename %s: it would capture the reference to %s", sym.name, newName)
+		}
+	}
+	for id, obj := range pkg.GoInfo.Uses {
+		if id.Name == newName && captured(id.Pos(), obj) {
+			return fmt.Errorf("cannot rename %s: it would capture the reference to %s", sym.name, newName)
+		}
+	}
+	return nil
+}
+
+// declaredInner reports whether name is declared in a scope nested in scope.
+func declaredInner(scope *types.Scope, name string) bool {
+	for i, n := 0, scope.NumChildren(); i < n; i++ {
+		child := scope.Child(i)
+		if child.Lookup(name) != nil || declaredInner(child, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// scopeWithin reports whether s is scope or a scope nested in it.
+func scopeWithin(s, scope *types.Scope) bool {
+	for ; s != nil; s = s.Parent() {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// scopeNode returns the syntax node of pkg which defines scope, or nil.
+func scopeNode(pkg *Package, scope *types.Scope) ast.Node {
+	for node, s := range pkg.Info.Scopes {
+		if s == scope {
+			return node
+		}
+	}
+	for node, s := range pkg.GoInfo.Scopes {
+		if s == scope {
+			return node
+		}
+	}
+	return nil
+}
+
+// renameIdent returns the new text of an identifier referring to an object
+// named oldName. The identifier may be an overload `oldName__N`, or refer to
+// an exported Go object by the lowercased name, as Go+ allows.
+func renameIdent(ident, oldName, newName string) string {
+	switch {
+	case ident == oldName:
+		return newName
+	case strings.HasPrefix(ident, oldName+"__"):
+		return newName + ident[len(oldName):]
+	case ident == lowerFirst(oldName):
+		return lowerFirst(newName)
+	case strings.HasPrefix(ident, lowerFirst(oldName)+"__"):
+		return lowerFirst(newName) + ident[len(oldName):]
+	}
+	return newName
+}
+
+func lowerFirst(name string) string {
+	r, n := utf8.DecodeRuneInString(name)
+	return string(unicode.ToLower(r)) + name[n:]
+}
+
+// -----------------------------------------------------------------------------
+
+// Rename renames the identifier at line:column (1-based, column in bytes)
+// of file and all references to it in the module, without starting a
+// LangServer.
+func Rename(file string, line, column int, newName string) (ret *WorkspaceEdit, err error) {
+	file, err = filepath.Abs(file)
+	if err != nil {
+		return
+	}
+	text, err := os.ReadFile(file)
+	if err != nil {
+		return
+	}
+	off := 0
+	for i := 1; i < line; i++ {
+		pos := bytes.IndexByte(text[off:], '\n')
+		if pos < 0 {
+			return nil, fmt.Errorf("%s: invalid line %d", file, line)
+		}
+		off += pos + 1
+	}
+	off += column - 1
+	if column < 1 || off > len(text) {
+		return nil, fmt.Errorf("%s:%d: invalid column %d", file, line, column)
+	}
+	return newWorkspace().Rename(uriOf(file), positionOf(text, off), newName)
+}
+
+// ApplyEdits applies edits to text and returns the result.
+// The edits must not overlap.
+func ApplyEdits(text []byte, edits []TextEdit) []byte {
+	type span struct {
+		start, end int
+		newText    string
+	}
+	spans := make([]span, len(edits))
+	for i, e := range edits {
+		spans[i] = span{offsetOf(text, e.Range.Start), offsetOf(text, e.Range.End), e.NewText}
+	}
+	sort.Slice(spans, func(i, j int) bool {
+		return spans[i].start < spans[j].start
+	})
+	var b bytes.Buffer
+	last := 0
+	for _, s := range spans {
+		b.Write(text[last:s.start])
+		b.WriteString(s.newText)
+		last = s.end
+	}
+	b.Write(text[last:])
+	return b.Bytes()
+}
+
+// -----------------------------------------------------------------------------
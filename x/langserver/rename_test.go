@@ -0,0 +1,172 @@
+package langserver
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTestFiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, src := range files {
+		file := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(file), 0755)
+		if err := os.WriteFile(file, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func applyWorkspaceEdit(t *testing.T, ret *WorkspaceEdit) map[string]string {
+	files := make(map[string]string)
+	for uri, edits := range ret.Changes {
+		file := FilenameOf(uri)
+		text, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[filepath.Base(file)] = string(ApplyEdits(text, edits))
+	}
+	return files
+}
+
+func TestRename(t *testing.T) {
+	dir := writeTestFiles(t, map[string]string{
+		"go.mod": "module example.com/foo\n\ngo 1.18\n",
+		"foo.go": `package foo
+
+const GopPackage = true
+
+type T struct {
+	Name string
+}
+
+func (p *T) Hello() string {
+	return "Hello, " + p.Name
+}
+
+func Add__0(a, b int) int {
+	return a + b
+}
+
+func Add__1(a, b string) string {
+	return a + b
+}
+`,
+		"bar.gop": `package foo
+
+func Run(t *T) string {
+	n := Add(1, 2)
+	println n
+	return t.hello() + Add("a", "b")
+}
+`,
+	})
+	ws := newWorkspace()
+	bar := uriOf(filepath.Join(dir, "bar.gop"))
+
+	ret, err := ws.Rename(bar, Position{Line: 3, Character: 6}, "Sum")
+	if err != nil {
+		t.Fatal("Rename:", err)
+	}
+	files := applyWorkspaceEdit(t, ret)
+	if !strings.Contains(files["foo.go"], "func Sum__0(") || !strings.Contains(files["foo.go"], "func Sum__1(") {
+		t.Fatal("Rename foo.go:", files["foo.go"])
+	}
+	if !strings.Contains(files["bar.gop"], "n := Sum(1, 2)") || !strings.Contains(files["bar.gop"], `Sum("a", "b")`) {
+		t.Fatal("Rename bar.gop:", files["bar.gop"])
+	}
+
+	ret, err = ws.Rename(bar, Position{Line: 5, Character: 10}, "Greet")
+	if err != nil {
+		t.Fatal("Rename:", err)
+	}
+	files = applyWorkspaceEdit(t, ret)
+	if !strings.Contains(files["foo.go"], "func (p *T) Greet() string") || !strings.Contains(files["bar.gop"], "t.greet()") {
+		t.Fatal("Rename method:", files)
+	}
+
+	ret, err = ws.Rename(bar, Position{Line: 4, Character: 9}, "m")
+	if err != nil {
+		t.Fatal("Rename:", err)
+	}
+	files = applyWorkspaceEdit(t, ret)
+	if len(files) != 1 || !strings.Contains(files["bar.gop"], "m := Add(1, 2)\n\tprintln m\n") {
+		t.Fatal("Rename local:", files)
+	}
+
+	if _, err = ws.Rename(bar, Position{Line: 4, Character: 9}, "t"); err == nil {
+		t.Fatal("Rename: no conflict error")
+	}
+	if _, err = ws.Rename(bar, Position{Line: 4, Character: 9}, "1x"); err == nil {
+		t.Fatal("Rename: no invalid identifier error")
+	}
+}
+
+func TestRenameLocalConflict(t *testing.T) {
+	dir := writeTestFiles(t, map[string]string{
+		"go.mod": "module example.com/foo\n\ngo 1.18\n",
+		"foo.gop": `package foo
+
+var g = 1
+
+func F(a int) int {
+	x := a
+	if a > 0 {
+		y := 2
+		return x + y
+	}
+	return x + g
+}
+`,
+	})
+	ws := newWorkspace()
+	foo := uriOf(filepath.Join(dir, "foo.gop"))
+	for _, c := range []struct{ name, err string }{
+		{"a", "already declared"},
+		{"y", "inner block"},
+		{"g", "capture"},
+	} {
+		if _, err := ws.Rename(foo, Position{Line: 5, Character: 1}, c.name); err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Fatal("Rename:", c.name, err)
+		}
+	}
+	ret, err := ws.Rename(foo, Position{Line: 5, Character: 1}, "z")
+	if err != nil {
+		t.Fatal("Rename:", err)
+	}
+	if files := applyWorkspaceEdit(t, ret); !strings.Contains(files["foo.gop"], "return z + g") {
+		t.Fatal("Rename local:", files)
+	}
+}
+
+func TestRenameIdent(t *testing.T) {
+	for _, c := range []struct{ ident, old, new, ret string }{
+		{"Foo", "Foo", "Bar", "Bar"},
+		{"Foo__1", "Foo", "Bar", "Bar__1"},
+		{"foo", "Foo", "Bar", "bar"},
+	} {
+		if ret := renameIdent(c.ident, c.old, c.new); ret != c.ret {
+			t.Fatal("renameIdent:", c, ret)
+		}
+	}
+}
+
+func TestRenameCrossPackage(t *testing.T) {
+	dir := writeTestFiles(t, map[string]string{
+		"go.mod":       "module example.com/foo\n\ngo 1.18\n",
+		"foo.go":       "package foo\n\nfunc Hello() string { return \"hi\" }\n",
+		"app/main.gop": "import \"example.com/foo\"\n\necho foo.hello()\necho foo.Hello()\n",
+	})
+	ret, err := Rename(filepath.Join(dir, "foo.go"), 3, 6, "Greet")
+	if err != nil {
+		t.Fatal("Rename:", err)
+	}
+	files := applyWorkspaceEdit(t, ret)
+	if !strings.Contains(files["foo.go"], "func Greet()") ||
+		files["main.gop"] != "import \"example.com/foo\"\n\necho foo.greet()\necho foo.Greet()\n" {
+		t.Fatal("Rename:", files)
+	}
+}
@@ -0,0 +1,269 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package langserver
+
+import (
+	"context"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+)
+
+// -----------------------------------------------------------------------------
+
+const (
+	defaultDebounce    = time.Second / 5
+	defaultMaxDebounce = 2 * time.Second
+)
+
+// task is the work to do on a dirty directory.
+type task struct {
+	dir   string
+	flags dirtyFlags
+	deps  []string  // directories of the local packages this one imports
+	since time.Time // when the directory became dirty
+	due   time.Time // when the task can be started
+	start time.Time // when the task was started
+}
+
+// scheduler runs tasks on dirty directories. Bursts of changes to the same
+// directory are debounced into one task, dependencies are processed before
+// their dependents, and independent directories are processed in parallel.
+type scheduler struct {
+	mutex   sync.Mutex
+	pending map[string]*task
+	running map[string]*task
+	wake    chan none // 1-buffered
+
+	debounce    time.Duration
+	maxDebounce time.Duration
+	workers     int
+
+	depsOf func(dir string) []string
+	run    func(dir string, flags dirtyFlags)
+}
+
+func newScheduler(debounce time.Duration, workers int, depsOf func(string) []string, run func(string, dirtyFlags)) *scheduler {
+	if debounce <= 0 {
+		debounce = defaultDebounce
+	}
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	maxDebounce := defaultMaxDebounce
+	if maxDebounce < debounce {
+		maxDebounce = debounce
+	}
+	return &scheduler{
+		pending:     make(map[string]*task),
+		running:     make(map[string]*task),
+		wake:        make(chan none, 1),
+		debounce:    debounce,
+		maxDebounce: maxDebounce,
+		workers:     workers,
+		depsOf:      depsOf,
+		run:         run,
+	}
+}
+
+// add marks dir dirty. The task of dir is postponed until no change happens
+// to it for the debounce duration, but no longer than maxDebounce since it
+// became dirty.
+func (p *scheduler) add(dir string, flags dirtyFlags) {
+	now := time.Now()
+	p.mutex.Lock()
+	t, ok := p.pending[dir]
+	if !ok {
+		t = &task{dir: dir, since: now}
+		p.pending[dir] = t
+	}
+	t.flags |= flags
+	t.due = now.Add(p.debounce)
+	if limit := t.since.Add(p.maxDebounce); t.due.After(limit) {
+		t.due = limit
+	}
+	p.mutex.Unlock()
+	if !ok && p.depsOf != nil {
+		deps := p.depsOf(dir)
+		p.mutex.Lock()
+		t.deps = deps
+		p.mutex.Unlock()
+	}
+	p.notify()
+}
+
+func (p *scheduler) notify() {
+	select {
+	case p.wake <- none{}:
+	default:
+	}
+}
+
+// loop starts tasks when they are ready, until ctx is done. The tasks already
+// started aren't canceled.
+func (p *scheduler) loop(ctx context.Context) {
+	for {
+		p.mutex.Lock()
+		next := p.schedule(time.Now())
+		p.mutex.Unlock()
+
+		var timer *time.Timer
+		var timeout <-chan time.Time
+		if next > 0 {
+			timer = time.NewTimer(next)
+			timeout = timer.C
+		}
+		select {
+		case <-p.wake:
+		case <-timeout:
+		case <-ctx.Done():
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// schedule starts the ready tasks and returns the duration until the next
+// pending task becomes due (0 if there is nothing to wait for).
+// The mutex must be held.
+func (p *scheduler) schedule(now time.Time) (next time.Duration) {
+	var ready []*task
+	for _, t := range p.pending {
+		if wait := t.due.Sub(now); wait > 0 {
+			if next == 0 || wait < next {
+				next = wait
+			}
+			continue
+		}
+		if _, ok := p.running[t.dir]; ok {
+			continue // wait for the current run of the same directory
+		}
+		ready = append(ready, t)
+	}
+	sort.Slice(ready, func(i, j int) bool {
+		return ready[i].since.Before(ready[j].since)
+	})
+	started := false
+	for _, t := range ready {
+		if len(p.running) >= p.workers {
+			break
+		}
+		if p.blocked(t) {
+			continue
+		}
+		p.start(t, now)
+		started = true
+	}
+	if !started && next == 0 && len(p.running) == 0 && len(ready) > 0 {
+		// All tasks are ready but waiting for each other: there is an import
+		// cycle, so break it by starting the oldest one.
+		p.start(ready[0], now)
+	}
+	return
+}
+
+// blocked reports whether t depends on a directory which is waiting to be
+// processed or is being processed.
+func (p *scheduler) blocked(t *task) bool {
+	for _, dep := range t.deps {
+		if dep == t.dir {
+			continue
+		}
+		if _, ok := p.pending[dep]; ok {
+			return true
+		}
+		if _, ok := p.running[dep]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *scheduler) start(t *task, now time.Time) {
+	delete(p.pending, t.dir)
+	t.start = now
+	p.running[t.dir] = t
+	go func() {
+		defer func() {
+			p.mutex.Lock()
+			delete(p.running, t.dir)
+			p.mutex.Unlock()
+			p.notify()
+		}()
+		p.run(t.dir, t.flags)
+	}()
+}
+
+// -----------------------------------------------------------------------------
+
+// TaskStatus describes a task of the LangServer.
+type TaskStatus struct {
+	Dir   string    `json:"dir"`
+	GenGo bool      `json:"gengo,omitempty"`
+	Check bool      `json:"check,omitempty"`
+	Since time.Time `json:"since"`          // when the directory became dirty
+	Start time.Time `json:"start"`          // when the task was started
+	Deps  []string  `json:"deps,omitempty"` // local packages to process first
+}
+
+// Status is the result of the `status` request.
+type Status struct {
+	Queued  []TaskStatus `json:"queued"`
+	Running []TaskStatus `json:"running"`
+}
+
+func (p *scheduler) status() (ret *Status) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	ret = &Status{
+		Queued:  make([]TaskStatus, 0, len(p.pending)),
+		Running: make([]TaskStatus, 0, len(p.running)),
+	}
+	for _, t := range p.pending {
+		ret.Queued = append(ret.Queued, t.status())
+	}
+	for _, t := range p.running {
+		ret.Running = append(ret.Running, t.status())
+	}
+	sortTasks(ret.Queued)
+	sortTasks(ret.Running)
+	return
+}
+
+func (t *task) status() TaskStatus {
+	return TaskStatus{
+		Dir:   t.dir,
+		GenGo: t.flags&dirtyGenGo != 0,
+		Check: t.flags&dirtyCheck != 0,
+		Since: t.since,
+		Start: t.start,
+		Deps:  t.deps,
+	}
+}
+
+func sortTasks(tasks []TaskStatus) {
+	sort.Slice(tasks, func(i, j int) bool {
+		return tasks[i].Dir < tasks[j].Dir
+	})
+}
+
+// -----------------------------------------------------------------------------
@@ -0,0 +1,133 @@
+package langserver
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+type schedRecorder struct {
+	mutex sync.Mutex
+	runs  []string
+	flags map[string]dirtyFlags
+	done  chan string
+}
+
+func newSchedRecorder() *schedRecorder {
+	return &schedRecorder{flags: make(map[string]dirtyFlags), done: make(chan string, 16)}
+}
+
+func (p *schedRecorder) run(dir string, flags dirtyFlags) {
+	p.mutex.Lock()
+	p.runs = append(p.runs, dir)
+	p.flags[dir] |= flags
+	p.mutex.Unlock()
+	p.done <- dir
+}
+
+func (p *schedRecorder) wait(t *testing.T, n int) {
+	for i := 0; i < n; i++ {
+		select {
+		case <-p.done:
+		case <-time.After(10 * time.Second):
+			t.Fatal("scheduler: timeout")
+		}
+	}
+}
+
+func TestSchedulerDebounce(t *testing.T) {
+	rec := newSchedRecorder()
+	sched := newScheduler(50*time.Millisecond, 2, nil, rec.run)
+	go sched.loop(context.Background())
+	sched.add("/a", dirtyCheck)
+	sched.add("/a", dirtyGenGo)
+	sched.add("/a", dirtyCheck)
+	if st := sched.status(); len(st.Queued) != 1 || !st.Queued[0].GenGo || !st.Queued[0].Check {
+		t.Fatal("status:", st)
+	}
+	rec.wait(t, 1)
+	select {
+	case dir := <-rec.done:
+		t.Fatal("scheduler: unexpected run of", dir)
+	case <-time.After(100 * time.Millisecond):
+	}
+	if len(rec.runs) != 1 || rec.flags["/a"] != dirtyGenGo|dirtyCheck {
+		t.Fatal("scheduler:", rec.runs, rec.flags)
+	}
+}
+
+func TestSchedulerOrder(t *testing.T) {
+	rec := newSchedRecorder()
+	deps := map[string][]string{
+		"/app": {"/lib", "/util"},
+		"/lib": {"/util"},
+	}
+	sched := newScheduler(10*time.Millisecond, 4, func(dir string) []string {
+		return deps[dir]
+	}, rec.run)
+	go sched.loop(context.Background())
+	sched.add("/app", dirtyCheck)
+	sched.add("/lib", dirtyCheck)
+	sched.add("/util", dirtyCheck)
+	rec.wait(t, 3)
+	if rec.runs[0] != "/util" || rec.runs[1] != "/lib" || rec.runs[2] != "/app" {
+		t.Fatal("scheduler:", rec.runs)
+	}
+}
+
+func TestSchedulerCycle(t *testing.T) {
+	rec := newSchedRecorder()
+	deps := map[string][]string{
+		"/a": {"/b"},
+		"/b": {"/a"},
+	}
+	sched := newScheduler(10*time.Millisecond, 4, func(dir string) []string {
+		return deps[dir]
+	}, rec.run)
+	go sched.loop(context.Background())
+	sched.add("/a", dirtyCheck)
+	sched.add("/b", dirtyCheck)
+	rec.wait(t, 2)
+}
+
+func TestSchedulerParallel(t *testing.T) {
+	started := make(chan string, 2)
+	release := make(chan none)
+	sched := newScheduler(10*time.Millisecond, 2, nil, func(dir string, flags dirtyFlags) {
+		started <- dir
+		<-release
+	})
+	go sched.loop(context.Background())
+	sched.add("/a", dirtyCheck)
+	sched.add("/b", dirtyCheck)
+	for i := 0; i < 2; i++ {
+		select {
+		case <-started:
+		case <-time.After(10 * time.Second):
+			t.Fatal("scheduler: not run in parallel")
+		}
+	}
+	if st := sched.status(); len(st.Running) != 2 || len(st.Queued) != 0 {
+		t.Fatal("status:", st)
+	}
+	close(release)
+}
+
+func TestSchedulerStop(t *testing.T) {
+	rec := newSchedRecorder()
+	sched := newScheduler(50*time.Millisecond, 2, nil, rec.run)
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan none)
+	go func() {
+		sched.loop(ctx)
+		close(stopped)
+	}()
+	sched.add("/a", dirtyCheck)
+	cancel()
+	select {
+	case <-stopped:
+	case <-time.After(10 * time.Second):
+		t.Fatal("scheduler: loop isn't stopped")
+	}
+}
@@ -0,0 +1,455 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package langserver
+
+import (
+	"bytes"
+	"go/types"
+	"path/filepath"
+	"sort"
+	"unicode/utf8"
+
+	"github.com/goplus/gop/ast"
+	"github.com/goplus/gop/cl"
+	"github.com/goplus/gop/token"
+	tplscanner "github.com/goplus/gop/tpl/scanner"
+	tpltoken "github.com/goplus/gop/tpl/token"
+)
+
+// -----------------------------------------------------------------------------
+
+// semType is the type of a semantic token. Its value is the index of
+// semTokenTypes.
+type semType uint32
+
+const (
+	semNamespace semType = iota
+	semType_
+	semClass
+	semStruct
+	semInterface
+	semTypeParameter
+	semParameter
+	semVariable
+	semProperty
+	semFunction
+	semMethod
+	semMacro
+	semKeyword
+	semComment
+	semString
+	semNumber
+	semRegexp
+	semOperator
+)
+
+var semTokenTypes = []string{
+	"namespace", "type", "class", "struct", "interface", "typeParameter",
+	"parameter", "variable", "property", "function", "method", "macro",
+	"keyword", "comment", "string", "number", "regexp", "operator",
+}
+
+// semModifiers is a set of semantic token modifiers. The bit i stands for
+// semTokenModifiers[i].
+type semModifiers uint32
+
+const (
+	semDeclaration semModifiers = 1 << iota
+	semReadonly
+	semDefaultLibrary
+)
+
+var semTokenModifiers = []string{"declaration", "readonly", "defaultLibrary"}
+
+func semanticTokensLegend() SemanticTokensLegend {
+	return SemanticTokensLegend{TokenTypes: semTokenTypes, TokenModifiers: semTokenModifiers}
+}
+
+// -----------------------------------------------------------------------------
+
+type semToken struct {
+	off  int // byte offset in the file
+	n    int // length in bytes
+	typ  semType
+	mods semModifiers
+}
+
+// semClassifier classifies the tokens of a Go+ file.
+type semClassifier struct {
+	pkg    *Package
+	f      *ast.File
+	text   []byte
+	tfile  *token.File
+	toks   []semToken
+	params map[types.Object]bool
+}
+
+// SemanticTokens returns the semantic tokens of uri, which highlight Go+
+// constructs like domain text literals (including the grammar in tpl`...`),
+// numbers with units, environment variables, error wrapping, lambdas and the
+// receivers of classfile methods.
+func (p *workspace) SemanticTokens(uri DocumentURI) (ret *SemanticTokens, err error) {
+	file := FilenameOf(uri)
+	text, err := p.content(file)
+	if err != nil {
+		return
+	}
+	pkg, err := p.check(filepath.Dir(file))
+	if err != nil {
+		return
+	}
+	ret = &SemanticTokens{Data: []uint32{}}
+	f, ok := pkg.Files[file]
+	if !ok {
+		return
+	}
+	tfile := pkg.Fset.File(f.Pos())
+	if tfile == nil || tfile.Size() != len(text) {
+		return
+	}
+	c := &semClassifier{pkg: pkg, f: f, text: text, tfile: tfile, params: make(map[types.Object]bool)}
+	c.file(f)
+	ret.Data = encodeSemTokens(text, c.toks)
+	return
+}
+
+func (p *semClassifier) add(pos token.Pos, n int, typ semType, mods semModifiers) {
+	if !pos.IsValid() || n <= 0 {
+		return
+	}
+	if base := p.tfile.Base(); int(pos) < base || int(pos)-base+n > p.tfile.Size() {
+		return
+	}
+	p.addOff(p.tfile.Offset(pos), n, typ, mods)
+}
+
+// addOff adds a token at the byte offset off. A token spanning multiple lines
+// is split into one token per line, as required by LSP.
+func (p *semClassifier) addOff(off, n int, typ semType, mods semModifiers) {
+	for n > 0 {
+		i := bytes.IndexByte(p.text[off:off+n], '\n')
+		if i < 0 {
+			p.toks = append(p.toks, semToken{off, n, typ, mods})
+			return
+		}
+		if i > 0 {
+			p.toks = append(p.toks, semToken{off, i, typ, mods})
+		}
+		off, n = off+i+1, n-i-1
+	}
+}
+
+func (p *semClassifier) file(f *ast.File) {
+	for _, cg := range f.Comments {
+		for _, c := range cg.List {
+			p.add(c.Pos(), len(c.Text), semComment, 0)
+		}
+	}
+	ast.Inspect(f, p.visit)
+}
+
+func (p *semClassifier) visit(n ast.Node) bool {
+	switch v := n.(type) {
+	case *ast.Ident:
+		p.ident(v)
+	case *ast.BasicLit:
+		p.basicLit(v)
+		return false
+	case *ast.NumberUnitLit:
+		p.add(v.ValuePos, len(v.Value), semNumber, 0)
+		p.add(v.ValuePos+token.Pos(len(v.Value)), len(v.Unit), semMacro, 0)
+	case *ast.DomainTextLit:
+		p.domainTextLit(v)
+		return false
+	case *ast.EnvExpr:
+		if v.Default != nil {
+			p.add(v.TokPos, int(v.Default.Pos()-v.TokPos), semMacro, semReadonly)
+			ast.Inspect(v.Default, p.visit)
+			return false
+		}
+		p.add(v.TokPos, int(v.End()-v.TokPos), semMacro, semReadonly)
+		return false
+	case *ast.ErrWrapExpr:
+		p.add(v.TokPos, 1, semOperator, 0)
+	case *ast.LambdaExpr:
+		p.lambdaParams(v.Lhs)
+		p.add(v.Rarrow, 2, semOperator, 0)
+	case *ast.LambdaExpr2:
+		p.lambdaParams(v.Lhs)
+		p.add(v.Rarrow, 2, semOperator, 0)
+	case *ast.ForPhrase:
+		p.add(v.For, 3, semKeyword, 0)
+		p.add(v.TokPos, 2, semKeyword, 0) // in
+		if v.Cond != nil && v.IfPos.IsValid() && p.textAt(v.IfPos, 2) == "if" {
+			p.add(v.IfPos, 2, semKeyword, 0)
+		}
+	case *ast.FuncType:
+		if v.Params != nil {
+			for _, field := range v.Params.List {
+				for _, name := range field.Names {
+					if obj := p.pkg.Info.Defs[name]; obj != nil {
+						p.params[obj] = true
+					}
+				}
+			}
+		}
+	case *ast.FuncDecl:
+		if v.Recv != nil {
+			for _, field := range v.Recv.List {
+				for _, name := range field.Names {
+					if obj := p.pkg.Info.Defs[name]; obj != nil {
+						p.params[obj] = true
+					}
+				}
+			}
+		}
+		if v.IsClass { // the receiver `this` of a classfile method is implicit
+			if fn, ok := p.pkg.Info.Defs[v.Name].(*types.Func); ok {
+				if recv := fn.Type().(*types.Signature).Recv(); recv != nil {
+					p.params[recv] = true
+				}
+			}
+		}
+	}
+	return true
+}
+
+func (p *semClassifier) textAt(pos token.Pos, n int) string {
+	off := p.tfile.Offset(pos)
+	if off+n > len(p.text) {
+		return ""
+	}
+	return string(p.text[off : off+n])
+}
+
+func (p *semClassifier) lambdaParams(params []*ast.Ident) {
+	for _, id := range params {
+		if obj := p.pkg.Info.ObjectOf(id); obj != nil {
+			p.params[obj] = true
+		}
+	}
+}
+
+func (p *semClassifier) ident(id *ast.Ident) {
+	if p.textAt(id.NamePos, len(id.Name)) != id.Name {
+		return // synthesized by the parser or the compiler
+	}
+	info := p.pkg.Info
+	obj := info.Defs[id]
+	var mods semModifiers
+	if obj != nil {
+		mods = semDeclaration
+	} else if obj = info.Uses[id]; obj == nil {
+		return
+	}
+	var typ semType
+	switch v := obj.(type) {
+	case *types.PkgName:
+		typ = semNamespace
+	case *types.TypeName:
+		switch t := v.Type().(type) {
+		case *types.TypeParam:
+			typ = semTypeParameter
+		default:
+			switch t.Underlying().(type) {
+			case *types.Struct:
+				typ = semStruct
+			case *types.Interface:
+				typ = semInterface
+			default:
+				typ = semType_
+			}
+		}
+	case *types.Var:
+		switch {
+		case v.IsField():
+			typ = semProperty
+		case p.params[obj]:
+			typ = semParameter
+		default:
+			typ = semVariable
+		}
+	case *types.Const:
+		typ, mods = semVariable, mods|semReadonly
+	case *types.Func:
+		if sig, ok := v.Type().(*types.Signature); ok && sig.Recv() != nil {
+			typ = semMethod
+		} else {
+			typ = semFunction
+		}
+	case *types.Builtin:
+		typ, mods = semFunction, mods|semDefaultLibrary
+	default:
+		return
+	}
+	if obj.Pkg() == nil {
+		mods |= semDefaultLibrary
+	}
+	p.add(id.NamePos, len(id.Name), typ, mods)
+}
+
+func (p *semClassifier) basicLit(v *ast.BasicLit) {
+	switch v.Kind {
+	case token.STRING, token.CSTRING, token.PYSTRING, token.CHAR:
+		if ex := v.Extra; ex != nil {
+			p.stringLitEx(v, ex)
+			return
+		}
+		p.add(v.ValuePos, len(v.Value), semString, 0)
+	default:
+		p.add(v.ValuePos, len(v.Value), semNumber, 0)
+	}
+}
+
+// stringLitEx classifies a string literal with embedded expressions, eg.
+// "Hello, ${name}!".
+func (p *semClassifier) stringLitEx(v *ast.BasicLit, ex *ast.StringLitEx) {
+	pos := v.ValuePos
+	for _, part := range ex.Parts {
+		if x, ok := part.(ast.Expr); ok {
+			p.add(pos, int(x.Pos()-pos), semString, 0)
+			ast.Inspect(x, p.visit)
+			pos = x.End()
+		}
+	}
+	p.add(pos, int(v.End()-pos), semString, 0)
+}
+
+func (p *semClassifier) domainTextLit(v *ast.DomainTextLit) {
+	p.add(v.Domain.NamePos, len(v.Domain.Name), semMacro, 0)
+	switch p.domainPath(v.Domain.Name) {
+	case tplPkgPath:
+		p.tplText(v)
+	case tplPkgPath + "/encoding/regexp", tplPkgPath + "/encoding/regexposix":
+		p.add(v.ValuePos, len(v.Value), semRegexp, 0)
+	default:
+		p.add(v.ValuePos, len(v.Value), semString, 0)
+	}
+}
+
+const tplPkgPath = "github.com/goplus/gop/tpl"
+
+// domainPath returns the package path of the text domain name, resolved as
+// cl does: an imported package, a domain of gop.mod, or a builtin domain.
+func (p *semClassifier) domainPath(name string) string {
+	if scope := p.pkg.Info.Scopes[p.f]; scope != nil {
+		if pkgName, ok := scope.Lookup(name).(*types.PkgName); ok {
+			return pkgName.Imported().Path()
+		}
+	}
+	path, _ := cl.DomainPath(p.pkg.domains, name)
+	return path
+}
+
+// tplText classifies the grammar in tpl`...` by tpl/scanner.
+func (p *semClassifier) tplText(v *ast.DomainTextLit) {
+	start := p.tfile.Offset(v.ValuePos)
+	end := start + len(v.Value)
+	if end > len(p.text) || len(v.Value) < 2 {
+		return
+	}
+	p.addOff(start, 1, semString, 0) // `
+	p.addOff(end-1, 1, semString, 0) // `
+	src := p.text[start+1 : end-1]
+	fset := tpltoken.NewFileSet()
+	file := fset.AddFile("", -1, len(src))
+	var s tplscanner.Scanner
+	s.Init(file, src, nil, tplscanner.ScanComments)
+	for {
+		t := s.Scan()
+		if t.Tok == tpltoken.EOF {
+			break
+		}
+		n := len(t.Lit)
+		if n == 0 {
+			n = t.Tok.Len()
+		}
+		var typ semType
+		switch t.Tok {
+		case tpltoken.IDENT:
+			if isUpperName(t.Lit) {
+				typ = semType_ // token class, eg. INT, STRING
+			} else {
+				typ = semVariable // rule
+			}
+		case tpltoken.STRING, tpltoken.CHAR:
+			typ = semString
+		case tpltoken.INT, tpltoken.FLOAT, tpltoken.IMAG, tpltoken.RAT, tpltoken.UNIT:
+			typ = semNumber
+		case tpltoken.COMMENT:
+			typ = semComment
+		case tpltoken.SEMICOLON:
+			continue
+		default:
+			typ = semOperator
+		}
+		off := file.Offset(t.Pos)
+		if off+n > len(src) {
+			continue
+		}
+		p.addOff(start+1+off, n, typ, 0)
+	}
+}
+
+func isUpperName(name string) bool {
+	for _, c := range name {
+		if c >= 'a' && c <= 'z' {
+			return false
+		}
+	}
+	return true
+}
+
+// encodeSemTokens encodes tokens into the relative format of LSP.
+func encodeSemTokens(text []byte, toks []semToken) []uint32 {
+	sort.SliceStable(toks, func(i, j int) bool {
+		return toks[i].off < toks[j].off
+	})
+	data := make([]uint32, 0, len(toks)*5)
+	var line, char uint32 // position of the previous token
+	lineStart, curLine, last := 0, uint32(0), 0
+	for _, t := range toks {
+		if t.off < last {
+			continue // overlapped
+		}
+		for i := lineStart; i < t.off; i++ {
+			if text[i] == '\n' {
+				curLine++
+				lineStart = i + 1
+			}
+		}
+		col := utf16Count(text[lineStart:t.off])
+		deltaChar := col
+		if curLine == line {
+			deltaChar = col - char
+		}
+		data = append(data, curLine-line, deltaChar, utf16Count(text[t.off:t.off+t.n]), uint32(t.typ), uint32(t.mods))
+		line, char, last = curLine, col, t.off+t.n
+	}
+	return data
+}
+
+func utf16Count(b []byte) (n uint32) {
+	for len(b) > 0 {
+		r, size := utf8.DecodeRune(b)
+		n += utf16Len(r)
+		b = b[size:]
+	}
+	return
+}
+
+// -----------------------------------------------------------------------------
@@ -0,0 +1,176 @@
+package langserver
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+const testSemanticSrc = `import "time"
+
+func apply(fn func(int) int) {
+}
+
+// wait waits for a while.
+func wait(d time.Duration) {
+}
+
+wait 10ms
+apply x => x * 2
+ys := [x for x in [1, 2, 3] if x > 1]
+echo "home: ${ys}"
+g := tpl` + "`expr = INT % \"+\"`" + `
+`
+
+type decodedToken struct {
+	line, char, n uint32
+	typ           string
+}
+
+func decodeSemTokens(data []uint32) (ret []decodedToken) {
+	var line, char uint32
+	for i := 0; i+5 <= len(data); i += 5 {
+		if data[i] != 0 {
+			line += data[i]
+			char = data[i+1]
+		} else {
+			char += data[i+1]
+		}
+		ret = append(ret, decodedToken{line, char, data[i+2], semTokenTypes[data[i+3]]})
+	}
+	return
+}
+
+func TestSemanticTokens(t *testing.T) {
+	dir := t.TempDir()
+	uri := uriOf(filepath.Join(dir, "main.gop"))
+	ws := newWorkspace()
+	ws.open(FilenameOf(uri), &document{uri: uri, text: []byte(testSemanticSrc)})
+	ret, err := ws.SemanticTokens(uri)
+	if err != nil {
+		t.Fatal("SemanticTokens:", err)
+	}
+	toks := decodeSemTokens(ret.Data)
+	expected := []decodedToken{
+		{0, 7, 6, "string"},     // "time"
+		{2, 11, 2, "parameter"}, // fn
+		{5, 0, 26, "comment"},   // // wait waits ...
+		{6, 12, 4, "namespace"}, // time
+		{9, 5, 2, "number"},     // 10
+		{9, 7, 2, "macro"},      // ms
+		{10, 6, 1, "parameter"}, // x
+		{10, 8, 2, "operator"},  // =>
+		{11, 9, 3, "keyword"},   // for
+		{11, 15, 2, "keyword"},  // in
+		{11, 28, 2, "keyword"},  // if
+		{12, 5, 9, "string"},    // "home: ${
+		{12, 14, 2, "variable"}, // ys
+		{13, 5, 3, "macro"},     // tpl
+		{13, 9, 4, "variable"},  // expr
+		{13, 16, 3, "type"},     // INT
+		{13, 20, 1, "operator"}, // %
+		{13, 22, 3, "string"},   // "+"
+	}
+	for _, e := range expected {
+		found := false
+		for _, tok := range toks {
+			if tok == e {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Error("SemanticTokens: not found -", e)
+		}
+	}
+	if t.Failed() {
+		t.Log(toks)
+	}
+}
+
+func TestSemanticTokensClassfile(t *testing.T) {
+	dir := t.TempDir()
+	uri := uriOf(filepath.Join(dir, "Rect.gox"))
+	ws := newWorkspace()
+	ws.open(FilenameOf(uri), &document{uri: uri, text: []byte(`var (
+	Width, Height int
+)
+
+func Area() int {
+	return Width * this.Height
+}
+
+func Double() int {
+	return 2 * Area()
+}
+`)})
+	ret, err := ws.SemanticTokens(uri)
+	if err != nil {
+		t.Fatal("SemanticTokens:", err)
+	}
+	toks := decodeSemTokens(ret.Data)
+	expected := []decodedToken{
+		{1, 1, 5, "property"},   // Width
+		{4, 5, 4, "method"},     // Area
+		{5, 8, 5, "property"},   // Width
+		{5, 16, 4, "parameter"}, // this
+		{5, 21, 6, "property"},  // Height
+		{9, 12, 4, "method"},    // Area
+	}
+	for _, e := range expected {
+		found := false
+		for _, tok := range toks {
+			if tok == e {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Error("SemanticTokens: not found -", e)
+		}
+	}
+	if t.Failed() {
+		t.Log(toks)
+	}
+}
+
+func TestSemanticTokensDomains(t *testing.T) {
+	dir := writeTestFiles(t, map[string]string{
+		"go.mod":  "module example.com/foo\n\ngo 1.18\n",
+		"gop.mod": "gop 1.2\n\ndomain rx github.com/goplus/gop/tpl/encoding/regexp\n",
+		"main.gop": "a := regexp`^a+$`\n" +
+			"b := regexposix`^b+$`\n" +
+			"c := rx`^c+$`\n" +
+			"d := json`{}`\n",
+	})
+	ws := newWorkspace()
+	uri := uriOf(filepath.Join(dir, "main.gop"))
+	ret, err := ws.SemanticTokens(uri)
+	if err != nil {
+		t.Fatal("SemanticTokens:", err)
+	}
+	toks := decodeSemTokens(ret.Data)
+	expected := []decodedToken{
+		{0, 5, 6, "macro"},   // regexp
+		{0, 11, 6, "regexp"}, // `^a+$`
+		{1, 5, 10, "macro"},  // regexposix
+		{1, 15, 6, "regexp"}, // `^b+$`
+		{2, 5, 2, "macro"},   // rx
+		{2, 7, 6, "regexp"},  // `^c+$`
+		{3, 9, 4, "string"},  // `{}`
+	}
+	for _, e := range expected {
+		found := false
+		for _, tok := range toks {
+			if tok == e {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Error("SemanticTokens: not found -", e)
+		}
+	}
+	if t.Failed() {
+		t.Log(toks)
+	}
+}
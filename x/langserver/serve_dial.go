@@ -81,6 +81,17 @@ func tooOld(d fs.DirEntry) bool {
 	return false
 }
 
+// gopDirOf returns ~/.gop/ where the LangServer logfiles save to.
+func gopDirOf() (gopDir string, err error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return
+	}
+	gopDir = home + "/.gop/"
+	err = os.MkdirAll(gopDir, 0755)
+	return
+}
+
 // ServeAndDial executes a command as a LangServer, makes a new connection to it
 // and returns a client of the LangServer based on the connection.
 func ServeAndDial(conf *ServeAndDialConfig, gopCmd string, args ...string) Client {
@@ -92,12 +103,7 @@ func ServeAndDial(conf *ServeAndDialConfig, gopCmd string, args ...string) Clien
 		onErr = fatal
 	}
 
-	home, err := os.UserHomeDir()
-	if err != nil {
-		onErr(err)
-	}
-	gopDir := home + "/.gop/"
-	err = os.MkdirAll(gopDir, 0755)
+	gopDir, err := gopDirOf()
 	if err != nil {
 		onErr(err)
 	}
@@ -114,6 +120,9 @@ func ServeAndDial(conf *ServeAndDialConfig, gopCmd string, args ...string) Clien
 					continue
 				}
 				if fname := fi.Name(); isLog(fname) && tooOld(fi) {
+					if _, ok := daemonOf(gopDir, fname); ok {
+						continue // a LangServer listening on a socket
+					}
 					os.Remove(gopDir + fname)
 					killByPid(pidByName(fname))
 				}
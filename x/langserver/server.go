@@ -18,11 +18,12 @@ package langserver
 
 import (
 	"context"
-	"encoding/json"
+	"log"
 	"path/filepath"
 	"sync"
 	"time"
 
+	"github.com/goplus/gop/env"
 	"github.com/goplus/gop/tool"
 	"github.com/goplus/gop/x/gopprojs"
 	"github.com/goplus/gop/x/jsonrpc2"
@@ -41,22 +42,41 @@ type Config struct {
 	// Framer allows control over the message framing and encoding.
 	// If nil, HeaderFramer will be used.
 	Framer jsonrpc2.Framer
+
+	// Debounce is how long the server waits for changes to a directory to
+	// settle down before processing it. If zero, 200ms will be used.
+	Debounce time.Duration
+
+	// Workers is the maximum number of directories processed in parallel.
+	// If zero, runtime.NumCPU() will be used.
+	Workers int
+
+	// MaxErrors is the maximum number of errors reported of compiling the
+	// Go+ files of a package. If zero, all errors are reported.
+	MaxErrors int
 }
 
-// NewServer creates a new LangServer and returns it.
+// NewServer creates a new LangServer and returns it. The LangServer stops
+// processing changes when ctx is done or the server shuts down.
 func NewServer(ctx context.Context, listener Listener, conf *Config) (ret *Server) {
-	h := newHandle()
+	ctx, cancel := context.WithCancel(ctx)
+	h := newHandle(conf)
 	ret = jsonrpc2.NewServer(ctx, listener, jsonrpc2.BinderFunc(
 		func(ctx context.Context, c *jsonrpc2.Connection) (ret jsonrpc2.ConnectionOptions) {
 			if conf != nil {
 				ret.Framer = conf.Framer
 			}
-			ret.Handler = h
+			ret.Handler = h.newSession(c)
+			ret.CancelRequests = true
 			// ret.OnInternalError = h.OnInternalError
 			return
 		}))
 	h.server = ret
-	go h.runLoop()
+	go h.sched.loop(ctx)
+	go func() {
+		ret.Wait()
+		cancel()
+	}()
 	return
 }
 
@@ -64,17 +84,51 @@ func NewServer(ctx context.Context, listener Listener, conf *Config) (ret *Serve
 
 type none = struct{}
 
+// dirtyFlags tells what to do with a dirty directory.
+type dirtyFlags int
+
+const (
+	dirtyGenGo dirtyFlags = 1 << iota // regenerate gop_autogen.go files
+	dirtyCheck                        // publish diagnostics to clients
+)
+
 type handler struct {
-	mutex sync.Mutex
-	dirty map[string]none
+	mutex    sync.Mutex
+	sessions map[*session]none
 
+	ws     *workspace
+	sched  *scheduler
+	router *jsonrpc2.Router
 	server *Server
 }
 
-func newHandle() *handler {
-	return &handler{
-		dirty: make(map[string]none),
+func newHandle(conf *Config) *handler {
+	if conf == nil {
+		conf = new(Config)
+	}
+	p := &handler{
+		sessions: make(map[*session]none),
+		ws:       newWorkspace(),
 	}
+	p.ws.maxErrors = conf.MaxErrors
+	p.sched = newScheduler(conf.Debounce, conf.Workers, p.ws.localDeps, p.process)
+	p.router = p.newRouter()
+	return p
+}
+
+func (p *handler) newSession(c *jsonrpc2.Connection) *session {
+	s := &session{handler: p, conn: c}
+	s.router = s.newRouter()
+	p.mutex.Lock()
+	p.sessions[s] = none{}
+	p.mutex.Unlock()
+	return s
+}
+
+func (p *handler) removeSession(s *session) {
+	p.mutex.Lock()
+	delete(p.sessions, s)
+	p.mutex.Unlock()
 }
 
 /*
@@ -83,57 +137,231 @@ func (p *handler) OnInternalError(err error) {
 }
 */
 
-func (p *handler) runLoop() {
-	const (
-		duration = time.Second / 100
-	)
-	for {
-		var dir string
-		p.mutex.Lock()
-		for dir = range p.dirty {
-			delete(p.dirty, dir)
-			break
+// process regenerates and/or type-checks a dirty directory. If dir changed
+// on disk, the packages importing it are type-checked again.
+func (p *handler) process(dir string, flags dirtyFlags) {
+	if flags&dirtyGenGo != 0 {
+		if _, _, err := tool.GenGoEx(dir, nil, true, tool.GenFlagPrompt); err != nil {
+			log.Println("GenGo", dir, "failed:", err)
 		}
-		p.mutex.Unlock()
-		if dir == "" {
-			time.Sleep(duration)
-			continue
+		p.ws.invalidateImports(dir)
+		for _, d := range p.ws.dependents(dir, p.ws.docDirs()) {
+			p.sched.add(d, dirtyCheck)
 		}
-		tool.GenGoEx(dir, nil, true, tool.GenFlagPrompt)
+	}
+	if flags&dirtyCheck != 0 {
+		p.publishDiagnostics(dir)
 	}
 }
 
-func (p *handler) Changed(files []string) {
+// publishDiagnostics type-checks the package in dir and pushes the result to
+// all clients.
+func (p *handler) publishDiagnostics(dir string) {
 	p.mutex.Lock()
-	defer p.mutex.Unlock()
+	sessions := make([]*session, 0, len(p.sessions))
+	for s := range p.sessions {
+		sessions = append(sessions, s)
+	}
+	p.mutex.Unlock()
+	if len(sessions) == 0 {
+		return
+	}
+	diags, err := p.ws.Diagnostics(dir)
+	if err != nil {
+		if !tool.NotFound(err) {
+			log.Println("Diagnostics", dir, "failed:", err)
+		}
+		return
+	}
+	ctx := context.Background()
+	for _, s := range sessions {
+		for _, params := range diags {
+			if err := s.conn.Notify(ctx, methodPublishDiagnostics, params); err != nil {
+				// the connection is broken or closing
+				p.removeSession(s)
+				break
+			}
+		}
+	}
+}
+
+func (p *handler) markDirty(dir string, flags dirtyFlags) {
+	p.ws.invalidate(dir)
+	p.sched.add(dir, flags)
+}
 
+func (p *handler) Changed(files []string) {
 	for _, file := range files {
-		dir := filepath.Dir(file)
-		p.dirty[dir] = none{}
+		switch filepath.Base(file) {
+		case "go.mod", "gop.mod":
+			p.ws.resetMods()
+		}
+		p.markDirty(filepath.Dir(file), dirtyGenGo|dirtyCheck)
 	}
 }
 
-func (p *handler) Handle(ctx context.Context, req *jsonrpc2.Request) (result any, err error) {
-	switch req.Method {
-	case methodChanged:
-		var files []string
-		err = json.Unmarshal(req.Params, &files)
-		if err != nil {
-			return
-		}
+// Status returns the queued and in-flight work of the LangServer.
+func (p *handler) Status() *Status {
+	return p.sched.status()
+}
+
+// newRouter returns the router of the methods served to all clients.
+func (p *handler) newRouter() *jsonrpc2.Router {
+	r := jsonrpc2.NewRouter()
+	jsonrpc2.RegisterNotify(r, methodChanged, func(ctx context.Context, files []string) error {
 		p.Changed(files)
-	case methodGenGo:
-		var pattern []string
-		err = json.Unmarshal(req.Params, &pattern)
-		if err != nil {
-			return
+		return nil
+	})
+	jsonrpc2.RegisterNotify(r, methodGenGo, func(ctx context.Context, params GenGoParams) error {
+		return genGo(ctx, params.GenTestPkg, params.Pattern...)
+	})
+	jsonrpc2.Register(r, methodStatus, func(ctx context.Context, _ struct{}) (*Status, error) {
+		return p.Status(), nil
+	})
+	return r
+}
+
+// session is the handler of a connection to the LangServer.
+type session struct {
+	*handler
+	conn   *jsonrpc2.Connection
+	router *jsonrpc2.Router
+	root   string // root directory of the client's workspace, if any
+}
+
+func (p *session) Handle(ctx context.Context, req *jsonrpc2.Request) (result any, err error) {
+	return p.router.Handle(ctx, req)
+}
+
+// newRouter returns the router of a session, which serves the LSP methods
+// and falls back to the methods of the LangServer.
+func (p *session) newRouter() *jsonrpc2.Router {
+	r := jsonrpc2.NewRouter()
+	r.Fallback = p.handler.router
+	r.Use(jsonrpc2.Recover(logPanic))
+	jsonrpc2.Register(r, methodInitialize, p.initialize)
+	jsonrpc2.RegisterNotify(r, methodInitialized, func(ctx context.Context, _ struct{}) error {
+		return nil
+	})
+	jsonrpc2.RegisterNotify(r, methodShutdown, func(ctx context.Context, _ struct{}) error {
+		return nil
+	})
+	jsonrpc2.RegisterNotify(r, methodExit, func(ctx context.Context, _ struct{}) error {
+		p.removeSession(p)
+		go p.conn.Close()
+		return nil
+	})
+	jsonrpc2.RegisterNotify(r, methodDidOpen, func(ctx context.Context, params DidOpenTextDocumentParams) error {
+		doc := params.TextDocument
+		file := FilenameOf(doc.URI)
+		p.ws.open(file, &document{
+			uri: doc.URI, version: doc.Version, text: []byte(doc.Text),
+		})
+		p.markDirty(filepath.Dir(file), dirtyCheck)
+		return nil
+	})
+	jsonrpc2.RegisterNotify(r, methodDidChange, func(ctx context.Context, params DidChangeTextDocumentParams) error {
+		doc := params.TextDocument
+		file := FilenameOf(doc.URI)
+		p.ws.change(file, doc.Version, params.ContentChanges)
+		p.markDirty(filepath.Dir(file), dirtyCheck)
+		return nil
+	})
+	jsonrpc2.RegisterNotify(r, methodDidClose, func(ctx context.Context, params DidCloseTextDocumentParams) error {
+		file := FilenameOf(params.TextDocument.URI)
+		p.ws.close(file)
+		p.markDirty(filepath.Dir(file), dirtyCheck)
+		return nil
+	})
+	jsonrpc2.RegisterNotify(r, methodDidSave, func(ctx context.Context, params DidSaveTextDocumentParams) error {
+		p.Changed([]string{FilenameOf(params.TextDocument.URI)})
+		return nil
+	})
+	jsonrpc2.Register(r, methodHover, func(ctx context.Context, params TextDocumentPositionParams) (*Hover, error) {
+		return p.ws.Hover(params.TextDocument.URI, params.Position)
+	})
+	jsonrpc2.Register(r, methodDefinition, func(ctx context.Context, params TextDocumentPositionParams) ([]Location, error) {
+		return p.ws.Definition(params.TextDocument.URI, params.Position)
+	})
+	jsonrpc2.Register(r, methodCompletion, func(ctx context.Context, params TextDocumentPositionParams) (*CompletionList, error) {
+		return p.ws.Completion(params.TextDocument.URI, params.Position)
+	})
+	jsonrpc2.Register(r, methodRename, func(ctx context.Context, params RenameParams) (*WorkspaceEdit, error) {
+		return p.ws.Rename(params.TextDocument.URI, params.Position, params.NewName)
+	})
+	jsonrpc2.Register(r, methodReferences, func(ctx context.Context, params ReferenceParams) ([]Location, error) {
+		return p.ws.References(params.TextDocument.URI, params.Position, params.Context.IncludeDeclaration)
+	})
+	jsonrpc2.Register(r, methodSignatureHelp, func(ctx context.Context, params TextDocumentPositionParams) (*SignatureHelp, error) {
+		return p.ws.SignatureHelp(params.TextDocument.URI, params.Position)
+	})
+	jsonrpc2.Register(r, methodInlayHint, func(ctx context.Context, params InlayHintParams) ([]InlayHint, error) {
+		return p.ws.InlayHints(params.TextDocument.URI, params.Range)
+	})
+	jsonrpc2.Register(r, methodSemanticTokensFull, func(ctx context.Context, params SemanticTokensParams) (*SemanticTokens, error) {
+		return p.ws.SemanticTokens(params.TextDocument.URI)
+	})
+	jsonrpc2.Register(r, methodDocumentSymbol, func(ctx context.Context, params DocumentSymbolParams) ([]DocumentSymbol, error) {
+		return p.ws.DocumentSymbols(params.TextDocument.URI)
+	})
+	jsonrpc2.Register(r, methodWorkspaceSymbol, func(ctx context.Context, params WorkspaceSymbolParams) ([]SymbolInformation, error) {
+		dirs := p.ws.docDirs()
+		if p.root != "" {
+			dirs = []string{p.root}
 		}
-		err = GenGo(pattern...)
+		return p.ws.WorkspaceSymbols(params.Query, dirs...)
+	})
+	jsonrpc2.Register(r, methodPrepareCallHierarchy, func(ctx context.Context, params CallHierarchyPrepareParams) ([]CallHierarchyItem, error) {
+		return p.ws.PrepareCallHierarchy(params.TextDocument.URI, params.Position)
+	})
+	jsonrpc2.Register(r, methodIncomingCalls, func(ctx context.Context, params CallHierarchyIncomingCallsParams) ([]CallHierarchyIncomingCall, error) {
+		return p.ws.IncomingCalls(params.Item)
+	})
+	jsonrpc2.Register(r, methodOutgoingCalls, func(ctx context.Context, params CallHierarchyOutgoingCallsParams) ([]CallHierarchyOutgoingCall, error) {
+		return p.ws.OutgoingCalls(params.Item)
+	})
+	return r
+}
+
+func logPanic(method string, v any, stack []byte) {
+	log.Printf("%s panicked: %v\n%s", method, v, stack)
+}
+
+func (p *session) initialize(ctx context.Context, params InitializeParams) (*InitializeResult, error) {
+	if params.RootURI != "" {
+		p.root = FilenameOf(params.RootURI)
 	}
-	return
+	return &InitializeResult{
+		Capabilities: ServerCapabilities{
+			TextDocumentSync:   SyncIncremental,
+			HoverProvider:      true,
+			DefinitionProvider: true,
+			CompletionProvider: &CompletionOptions{TriggerCharacters: []string{"."}},
+			RenameProvider:     true,
+			ReferencesProvider: true,
+
+			CallHierarchyProvider: true,
+
+			SignatureHelpProvider: &SignatureHelpOptions{TriggerCharacters: []string{"(", ","}},
+			InlayHintProvider:     true,
+
+			SemanticTokensProvider: &SemanticTokensOptions{Legend: semanticTokensLegend(), Full: true},
+
+			DocumentSymbolProvider:  true,
+			WorkspaceSymbolProvider: true,
+		},
+		ServerInfo: &ServerInfo{Name: "gop serve", Version: env.Version()},
+	}, nil
 }
 
 func GenGo(pattern ...string) (err error) {
+	return genGo(context.Background(), true, pattern...)
+}
+
+// genGo is like GenGo, but stops when ctx is done, generates the test
+// packages only if genTestPkg is set, and reports its progress with
+// jsonrpc2.Progress.
+func genGo(ctx context.Context, genTestPkg bool, pattern ...string) (err error) {
 	projs, err := gopprojs.ParseAll(pattern...)
 	if err != nil {
 		return
@@ -142,16 +370,22 @@ func GenGo(pattern ...string) (err error) {
 	if conf != nil {
 		defer conf.UpdateCache()
 	}
-	for _, proj := range projs {
+	for i, proj := range projs {
+		if err = ctx.Err(); err != nil {
+			return
+		}
+		var name string
 		switch v := proj.(type) {
 		case *gopprojs.DirProj:
-			tool.GenGoEx(v.Dir, conf, true, 0)
+			name = v.Dir
+			tool.GenGoEx(v.Dir, conf, genTestPkg, 0)
 		case *gopprojs.PkgPathProj:
-			if v.Path == "builtin" {
-				continue
+			name = v.Path
+			if v.Path != "builtin" {
+				tool.GenGoPkgPathEx("", v.Path, conf, genTestPkg, 0)
 			}
-			tool.GenGoPkgPathEx("", v.Path, conf, true, 0)
 		}
+		jsonrpc2.Progress(ctx, &GenGoProgress{Proj: name, Done: i + 1, Total: len(projs)})
 	}
 	return
 }
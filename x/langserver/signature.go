@@ -0,0 +1,200 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package langserver
+
+import (
+	"go/types"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/goplus/gop/ast"
+	"github.com/goplus/gop/token"
+)
+
+// -----------------------------------------------------------------------------
+
+// SignatureHelp returns the signatures of the function being called at pos.
+// For an overloaded function, every overload is listed and the active one is
+// the overload chosen by the compiler.
+func (p *workspace) SignatureHelp(uri DocumentURI, pos Position) (ret *SignatureHelp, err error) {
+	at, err := p.locate(uri, pos)
+	if err != nil || at == nil {
+		return
+	}
+	off := offsetOf(at.text, pos)
+	if off > at.tfile.Size() {
+		return
+	}
+	tpos := at.tfile.Pos(off)
+	var call *ast.CallExpr
+	ast.Inspect(at.file, func(n ast.Node) bool {
+		if n == nil || tpos < n.Pos() || tpos > n.End() {
+			return false
+		}
+		if c, ok := n.(*ast.CallExpr); ok && inCallArgs(c, tpos) {
+			call = c
+		}
+		return true
+	})
+	if call == nil {
+		return
+	}
+	info, this := at.pkg.Info, at.pkg.Types
+	ret = new(SignatureHelp)
+	var sig *types.Signature
+	if id := calleeIdent(call.Fun); id != nil {
+		if _, objs := info.OverloadOf(id); objs != nil {
+			chosen := info.OverloadChosen(id)
+			for _, o := range objs {
+				s, ok := o.Type().(*types.Signature)
+				if !ok {
+					continue
+				}
+				if o == chosen {
+					ret.ActiveSignature = uint32(len(ret.Signatures))
+					sig = s
+				}
+				ret.Signatures = append(ret.Signatures, signatureInfo(this, id.Name, s))
+			}
+		} else if obj := info.ObjectOf(id); obj != nil {
+			if s, ok := obj.Type().(*types.Signature); ok {
+				sig = s
+				ret.Signatures = append(ret.Signatures, signatureInfo(this, id.Name, s))
+			}
+		}
+	}
+	if ret.Signatures == nil {
+		s, ok := info.TypeOf(call.Fun).(*types.Signature)
+		if !ok {
+			return nil, nil
+		}
+		sig = s
+		ret.Signatures = append(ret.Signatures, signatureInfo(this, "func", s))
+	}
+	for _, arg := range call.Args {
+		if arg.End() < tpos {
+			ret.ActiveParameter++
+		}
+	}
+	if sig != nil && sig.Variadic() {
+		if n := uint32(sig.Params().Len()); ret.ActiveParameter >= n {
+			ret.ActiveParameter = n - 1
+		}
+	}
+	return
+}
+
+// inCallArgs reports whether pos is in the arguments of call.
+func inCallArgs(call *ast.CallExpr, pos token.Pos) bool {
+	if call.Lparen.IsValid() {
+		return call.Lparen < pos && (!call.Rparen.IsValid() || pos <= call.Rparen)
+	}
+	return pos > call.Fun.End() // command-style call, eg. `println x, y`
+}
+
+func signatureInfo(this *types.Package, name string, sig *types.Signature) SignatureInformation {
+	qf := types.RelativeTo(this)
+	params := sig.Params()
+	info := SignatureInformation{
+		Label:      name + strings.TrimPrefix(types.TypeString(sig, qf), "func"),
+		Parameters: make([]ParameterInformation, params.Len()),
+	}
+	for i, n := 0, params.Len(); i < n; i++ {
+		v := params.At(i)
+		typ := types.TypeString(v.Type(), qf)
+		if sig.Variadic() && i == n-1 {
+			if t, ok := v.Type().(*types.Slice); ok {
+				typ = "..." + types.TypeString(t.Elem(), qf)
+			}
+		}
+		label := typ
+		if v.Name() != "" {
+			label = v.Name() + " " + typ
+		}
+		info.Parameters[i] = ParameterInformation{Label: label}
+	}
+	return info
+}
+
+// -----------------------------------------------------------------------------
+
+// InlayHints returns the hints in rg of uri: the overload chosen by the
+// compiler at each call of an overloaded function, and the inferred types of
+// lambda parameters.
+func (p *workspace) InlayHints(uri DocumentURI, rg Range) (ret []InlayHint, err error) {
+	file := FilenameOf(uri)
+	text, err := p.content(file)
+	if err != nil {
+		return
+	}
+	pkg, err := p.check(filepath.Dir(file))
+	if err != nil {
+		return
+	}
+	f, ok := pkg.Files[file]
+	if !ok {
+		return
+	}
+	tfile := pkg.Fset.File(f.Pos())
+	if tfile == nil {
+		return
+	}
+	start, end := offsetOf(text, rg.Start), offsetOf(text, rg.End)
+	info, qf := pkg.Info, types.RelativeTo(pkg.Types)
+	hintAt := func(pos token.Pos) (Position, bool) {
+		off := tfile.Offset(pos)
+		return positionOf(text, off), off >= start && off <= end
+	}
+	lambdaParams := func(params []*ast.Ident) {
+		for _, id := range params {
+			obj := info.ObjectOf(id)
+			if obj == nil {
+				continue
+			}
+			if pos, ok := hintAt(id.End()); ok {
+				ret = append(ret, InlayHint{
+					Position: pos, Label: types.TypeString(obj.Type(), qf), Kind: InlayHintType, PaddingLeft: true,
+				})
+			}
+		}
+	}
+	ast.Inspect(f, func(n ast.Node) bool {
+		switch v := n.(type) {
+		case *ast.Ident:
+			if chosen := info.OverloadChosen(v); chosen != nil {
+				if pos, ok := hintAt(v.End()); ok {
+					ret = append(ret, InlayHint{
+						Position: pos, Label: chosen.Name(), Tooltip: objectString(pkg.Types, chosen), PaddingLeft: true,
+					})
+				}
+			}
+		case *ast.LambdaExpr:
+			lambdaParams(v.Lhs)
+		case *ast.LambdaExpr2:
+			lambdaParams(v.Lhs)
+		}
+		return true
+	})
+	sort.SliceStable(ret, func(i, j int) bool {
+		a, b := ret[i].Position, ret[j].Position
+		return a.Line < b.Line || (a.Line == b.Line && a.Character < b.Character)
+	})
+	return
+}
+
+// -----------------------------------------------------------------------------
@@ -0,0 +1,83 @@
+package langserver
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+const testOverloadGo = `package foo
+
+const GopPackage = true
+
+func Add__0(a, b int) int {
+	return a + b
+}
+
+func Add__1(a, b string) string {
+	return a + b
+}
+`
+
+const testOverloadGop = `package foo
+
+func Run() {
+	echo Add("a", "b"), Add(1, 2)
+	apply x => x * 2
+}
+
+func apply(fn func(int) int) {
+}
+`
+
+func TestSignatureHelp(t *testing.T) {
+	dir := writeTestFiles(t, map[string]string{
+		"go.mod":  "module example.com/foo\n\ngo 1.18\n",
+		"foo.go":  testOverloadGo,
+		"bar.gop": testOverloadGop,
+	})
+	ws := newWorkspace()
+	uri := uriOf(filepath.Join(dir, "bar.gop"))
+
+	ret, err := ws.SignatureHelp(uri, Position{Line: 3, Character: 28})
+	if err != nil || ret == nil || len(ret.Signatures) != 2 {
+		t.Fatal("SignatureHelp:", ret, err)
+	}
+	if ret.Signatures[0].Label != "Add(a int, b int) int" || ret.ActiveSignature != 0 || ret.ActiveParameter != 1 {
+		t.Fatal("SignatureHelp:", ret)
+	}
+	if ret, err = ws.SignatureHelp(uri, Position{Line: 3, Character: 10}); err != nil || ret.ActiveSignature != 1 || ret.ActiveParameter != 0 {
+		t.Fatal("SignatureHelp:", ret, err)
+	}
+	if p := ret.Signatures[1].Parameters; len(p) != 2 || p[1].Label != "b string" {
+		t.Fatal("SignatureHelp:", p)
+	}
+	if ret, err = ws.SignatureHelp(uri, Position{Line: 4, Character: 8}); err != nil || ret.Signatures[0].Label != "apply(fn func(int) int)" {
+		t.Fatal("SignatureHelp:", ret, err)
+	}
+}
+
+func TestInlayHints(t *testing.T) {
+	dir := writeTestFiles(t, map[string]string{
+		"go.mod":  "module example.com/foo\n\ngo 1.18\n",
+		"foo.go":  testOverloadGo,
+		"bar.gop": testOverloadGop,
+	})
+	ws := newWorkspace()
+	uri := uriOf(filepath.Join(dir, "bar.gop"))
+	hints, err := ws.InlayHints(uri, Range{End: Position{Line: 10}})
+	if err != nil || len(hints) != 3 {
+		t.Fatal("InlayHints:", hints, err)
+	}
+	if h := hints[0]; h.Label != "Add__1" || h.Position != (Position{Line: 3, Character: 9}) {
+		t.Fatal("InlayHints:", h)
+	}
+	if h := hints[1]; h.Label != "Add__0" {
+		t.Fatal("InlayHints:", h)
+	}
+	if h := hints[2]; h.Label != "int" || h.Kind != InlayHintType || h.Position != (Position{Line: 4, Character: 8}) {
+		t.Fatal("InlayHints:", h)
+	}
+	if hints, err = ws.InlayHints(uri, Range{Start: Position{Line: 4}, End: Position{Line: 10}}); err != nil || len(hints) != 1 {
+		t.Fatal("InlayHints:", hints, err)
+	}
+}
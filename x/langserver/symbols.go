@@ -0,0 +1,553 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package langserver
+
+import (
+	goast "go/ast"
+	"go/types"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/goplus/gop/ast"
+	"github.com/goplus/gop/cl"
+	"github.com/goplus/gop/cl/outline"
+	"github.com/goplus/gop/token"
+)
+
+const (
+	maxWorkspaceSymbols = 100
+)
+
+// -----------------------------------------------------------------------------
+
+// pkgOutline is the outline of a package computed by cl/outline, which loads
+// the declarations of a package without compiling function bodies.
+type pkgOutline struct {
+	all     *outline.All
+	decls   map[token.Pos][2]token.Pos // position of a name => range of its declaration
+	classes map[*types.TypeName]string // class type => the classfile declaring it
+	entries map[token.Pos]bool         // entries of scripts, which are hidden
+	tfiles  []*token.File              // files added to the FileSet by the outline
+}
+
+// outline returns the outline of the package in dir, returning the cached
+// result if the directory hasn't changed since the last call. Like check, the
+// package is loaded without holding the mutex.
+func (p *workspace) outline(dir string) (out *pkgOutline) {
+	p.mutex.Lock()
+	if out, ok := p.outs[dir]; ok {
+		p.mutex.Unlock()
+		return out
+	}
+	if c, ok := p.outlining[dir]; ok {
+		p.mutex.Unlock()
+		<-c.done
+		return c.out
+	}
+	c := &flight{done: make(chan struct{})}
+	p.outlining[dir] = c
+	mod, err := p.loadMod(dir)
+	var fs overlayFS
+	var imp *importer
+	if err == nil {
+		fs, imp = p.overlay(), p.importer(mod)
+	}
+	p.mutex.Unlock()
+
+	out = new(pkgOutline)
+	defer func() {
+		p.mutex.Lock()
+		if p.outlining[dir] == c {
+			delete(p.outlining, dir)
+			p.outs[dir] = out
+		} else { // dropped meanwhile
+			removeFiles(p.fset, out.tfiles)
+		}
+		p.mutex.Unlock()
+		c.out = out
+		close(c.done)
+	}()
+	if err != nil {
+		return
+	}
+	var pkgs map[string]*ast.Package
+	p.addFiles(&out.tfiles, func() {
+		pkgs, _, err = parseDir(p.fset, fs, dir, mod)
+	})
+	if err != nil {
+		return
+	}
+	astPkg := mainPkgOf(pkgs)
+	if astPkg == nil {
+		return
+	}
+	// errors are reported by diagnostics, so they are ignored here
+	var ret outline.Package
+	func() {
+		imp.mu.Lock()
+		defer imp.mu.Unlock()
+		ret, _ = outline.NewPackage(pkgPathOf(mod, dir), astPkg, &outline.Config{
+			Fset:        p.fset,
+			LookupClass: mod.LookupClass,
+			Importer:    imp,
+		})
+	}()
+	if !ret.Valid() {
+		return out
+	}
+	out.all = ret.Outline(true)
+	out.decls = make(map[token.Pos][2]token.Pos)
+	out.classes = make(map[*types.TypeName]string)
+	out.entries = make(map[token.Pos]bool)
+	scope := ret.Pkg().Scope()
+	for file, f := range astPkg.Files {
+		out.addDecls(f)
+		if f.IsClass {
+			name, _ := cl.GetFileClassType(f, file, mod.LookupClass)
+			if o, ok := scope.Lookup(name).(*types.TypeName); ok {
+				out.classes[o] = file
+			}
+		}
+	}
+	for _, f := range astPkg.GoFiles {
+		out.addGoDecls(f)
+	}
+	return out
+}
+
+func (p *pkgOutline) addDecls(f *ast.File) {
+	for _, decl := range f.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if d.Shadow {
+				p.entries[d.Name.Pos()] = true
+			} else {
+				p.decls[d.Name.Pos()] = [2]token.Pos{d.Pos(), d.End()}
+			}
+		case *ast.OverloadFuncDecl:
+			p.decls[d.Name.Pos()] = [2]token.Pos{d.Pos(), d.End()}
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				node := ast.Node(spec)
+				if len(d.Specs) == 1 {
+					node = d
+				}
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					p.decls[s.Name.Pos()] = [2]token.Pos{node.Pos(), node.End()}
+					if st, ok := s.Type.(*ast.StructType); ok {
+						for _, fld := range st.Fields.List {
+							for _, name := range fld.Names {
+								p.decls[name.Pos()] = [2]token.Pos{fld.Pos(), fld.End()}
+							}
+						}
+					}
+				case *ast.ValueSpec:
+					for _, name := range s.Names {
+						p.decls[name.Pos()] = [2]token.Pos{node.Pos(), node.End()}
+					}
+				}
+			}
+		}
+	}
+}
+
+func (p *pkgOutline) addGoDecls(f *goast.File) {
+	for _, decl := range f.Decls {
+		switch d := decl.(type) {
+		case *goast.FuncDecl:
+			p.decls[d.Name.Pos()] = [2]token.Pos{d.Pos(), d.End()}
+		case *goast.GenDecl:
+			for _, spec := range d.Specs {
+				node := goast.Node(spec)
+				if len(d.Specs) == 1 {
+					node = d
+				}
+				switch s := spec.(type) {
+				case *goast.TypeSpec:
+					p.decls[s.Name.Pos()] = [2]token.Pos{node.Pos(), node.End()}
+					if st, ok := s.Type.(*goast.StructType); ok {
+						for _, fld := range st.Fields.List {
+							for _, name := range fld.Names {
+								p.decls[name.Pos()] = [2]token.Pos{fld.Pos(), fld.End()}
+							}
+						}
+					}
+				case *goast.ValueSpec:
+					for _, name := range s.Names {
+						p.decls[name.Pos()] = [2]token.Pos{node.Pos(), node.End()}
+					}
+				}
+			}
+		}
+	}
+}
+
+// -----------------------------------------------------------------------------
+
+// outSymbol is a declaration in the outline of a package.
+type outSymbol struct {
+	name     string
+	id       string // identifier at pos
+	detail   string
+	kind     SymbolKind
+	file     string
+	pos      token.Pos // position of the identifier; NoPos for a class
+	start    token.Pos // range of the declaration
+	end      token.Pos
+	group    bool // overloads without an explicit overload declaration
+	children []*outSymbol
+}
+
+// symbols returns the declarations of the package. Overloads of a function
+// are grouped as children of the function, and the methods and fields of a
+// class are children of the class.
+func (p *pkgOutline) symbols(fset *token.FileSet) (ret []*outSymbol) {
+	out := p.all
+	if out == nil {
+		return
+	}
+	this := out.Pkg()
+	qf := types.RelativeTo(this)
+	newSym := func(obj types.Object, name string, kind SymbolKind) *outSymbol {
+		f := fset.File(obj.Pos())
+		if f == nil {
+			return nil
+		}
+		sym := &outSymbol{name: name, id: obj.Name(), kind: kind, file: f.Name(), pos: obj.Pos(), start: obj.Pos()}
+		sym.end = sym.pos + token.Pos(len(sym.id))
+		if r, ok := p.decls[obj.Pos()]; ok {
+			sym.start, sym.end = r[0], r[1]
+		}
+		switch obj.(type) {
+		case *types.Func, *types.Var, *types.Const:
+			sym.detail = types.TypeString(obj.Type(), qf)
+		}
+		return sym
+	}
+	overloads := make(map[string]*outSymbol)
+	funcs := func(fns []outline.Func) {
+		for _, fn := range fns {
+			if p.entries[fn.Pos()] {
+				continue
+			}
+			name, _, isOverload := outline.CheckOverload(fn.Func)
+			if !isOverload {
+				name = fn.Name()
+			}
+			group := overloads[name]
+			if group == nil {
+				group = &outSymbol{name: name, kind: SymbolFunction}
+				overloads[name] = group
+			}
+			if sym := newSym(fn.Func, fn.Name(), SymbolFunction); sym != nil {
+				if isOverload {
+					group.children = append(group.children, sym)
+				} else {
+					sym.children = group.children
+					*group = *sym
+				}
+			}
+		}
+	}
+	for _, o := range out.Consts {
+		if strings.HasPrefix(o.Name(), "Gopo_") { // overload declaration
+			continue
+		}
+		if sym := newSym(o.Const, o.Name(), SymbolConstant); sym != nil {
+			ret = append(ret, sym)
+		}
+	}
+	for _, o := range out.Vars {
+		if sym := newSym(o.Var, o.Name(), SymbolVariable); sym != nil {
+			ret = append(ret, sym)
+		}
+	}
+	funcs(out.Funcs)
+	for _, t := range out.Types {
+		funcs(t.Creators)
+		funcs(t.GoptFuncs)
+		funcs(t.Helpers)
+		for _, o := range t.Consts {
+			if sym := newSym(o.Const, o.Name(), SymbolConstant); sym != nil {
+				ret = append(ret, sym)
+			}
+		}
+		ret = append(ret, p.typeSymbols(t, newSym)...)
+	}
+	for _, group := range overloads {
+		if group.file == "" { // no explicit overload declaration
+			if len(group.children) == 0 {
+				continue
+			}
+			first := group.children[0]
+			group.id, group.file, group.pos, group.start, group.end = first.id, first.file, first.pos, first.start, first.end
+			group.group = true
+		}
+		ret = append(ret, group)
+	}
+	sortSymbols(ret)
+	return
+}
+
+// newSymbolFunc creates the symbol of obj, or returns nil if obj isn't
+// declared in a source file.
+type newSymbolFunc = func(obj types.Object, name string, kind SymbolKind) *outSymbol
+
+// typeSymbols returns the symbols of type t and its methods.
+func (p *pkgOutline) typeSymbols(t *outline.TypeName, newSym newSymbolFunc) (ret []*outSymbol) {
+	o := t.TypeName
+	kind := SymbolClass
+	switch o.Type().Underlying().(type) {
+	case *types.Struct:
+		kind = SymbolStruct
+	case *types.Interface:
+		kind = SymbolInterface
+	}
+	file, isClass := p.classes[o]
+	var sym *outSymbol
+	if isClass {
+		sym = &outSymbol{name: o.Name(), detail: filepath.Base(file), kind: SymbolClass, file: file}
+	} else if sym = newSym(o, o.Name(), kind); sym == nil {
+		return
+	}
+	ret = append(ret, sym)
+	switch u := o.Type().Underlying().(type) {
+	case *types.Struct:
+		for i, n := 0, u.NumFields(); i < n; i++ {
+			if fld := u.Field(i); !fld.Embedded() {
+				if c := newSym(fld, fld.Name(), SymbolField); c != nil {
+					sym.children = append(sym.children, c)
+				}
+			}
+		}
+	case *types.Interface:
+		for i, n := 0, u.NumExplicitMethods(); i < n; i++ {
+			fn := u.ExplicitMethod(i)
+			if c := newSym(fn, fn.Name(), SymbolMethod); c != nil {
+				sym.children = append(sym.children, c)
+			}
+		}
+	}
+	if o.IsAlias() {
+		return
+	}
+	named, ok := t.Type().CheckNamed(p.all.Package)
+	if !ok {
+		return
+	}
+	for _, fn := range named.Methods() {
+		if isClass {
+			if c := newSym(fn.Func, fn.Name(), SymbolMethod); c != nil {
+				sym.children = append(sym.children, c)
+			}
+			continue
+		}
+		recv := o.Name()
+		if _, ok := fn.Type().(*types.Signature).Recv().Type().(*types.Pointer); ok {
+			recv = "*" + recv
+		}
+		if c := newSym(fn.Func, "("+recv+")."+fn.Name(), SymbolMethod); c != nil {
+			ret = append(ret, c)
+		}
+	}
+	return
+}
+
+func sortSymbols(syms []*outSymbol) {
+	sort.Slice(syms, func(i, j int) bool {
+		a, b := syms[i], syms[j]
+		if a.file != b.file {
+			return a.file < b.file
+		}
+		return a.pos < b.pos
+	})
+	for _, sym := range syms {
+		sortSymbols(sym.children)
+	}
+}
+
+// -----------------------------------------------------------------------------
+
+// DocumentSymbols returns the declarations of uri.
+func (p *workspace) DocumentSymbols(uri DocumentURI) (ret []DocumentSymbol, err error) {
+	file := FilenameOf(uri)
+	text, err := p.content(file)
+	if err != nil {
+		return
+	}
+	out := p.outline(filepath.Dir(file))
+	var conv func(sym *outSymbol) (DocumentSymbol, bool)
+	conv = func(sym *outSymbol) (ret DocumentSymbol, ok bool) {
+		if sym.file != file {
+			return
+		}
+		ret = DocumentSymbol{Name: sym.name, Detail: sym.detail, Kind: sym.kind}
+		if sym.pos.IsValid() {
+			f := p.fset.File(sym.pos)
+			if f == nil {
+				return
+			}
+			off := f.Offset(sym.pos)
+			ret.Range = Range{Start: positionOf(text, f.Offset(sym.start)), End: positionOf(text, f.Offset(sym.end))}
+			ret.SelectionRange = Range{Start: positionOf(text, off), End: positionOf(text, off+len(sym.id))}
+		} else { // a class is the whole classfile
+			ret.Range = Range{End: positionOf(text, len(text))}
+		}
+		for _, c := range sym.children {
+			if child, ok := conv(c); ok {
+				ret.Children = append(ret.Children, child)
+			}
+		}
+		return ret, true
+	}
+	for _, sym := range out.symbols(p.fset) {
+		if s, ok := conv(sym); ok {
+			ret = append(ret, s)
+		} else if sym.group {
+			// overloads of a function may be declared in different files
+			for _, c := range sym.children {
+				if s, ok := conv(c); ok {
+					ret = append(ret, s)
+				}
+			}
+		}
+	}
+	return
+}
+
+// -----------------------------------------------------------------------------
+
+// WorkspaceSymbols returns the declarations in the modules of dirs which
+// match query fuzzily, the best matches first.
+func (p *workspace) WorkspaceSymbols(query string, dirs ...string) (ret []SymbolInformation, err error) {
+	type match struct {
+		sym   *outSymbol
+		name  string
+		pkg   string
+		score int
+	}
+	var matches []match
+	seen := make(map[string]bool)
+	for _, root := range dirs {
+		for _, dir := range p.moduleDirs(root) {
+			if seen[dir] {
+				continue
+			}
+			seen[dir] = true
+			out := p.outline(dir)
+			if out.all == nil {
+				continue
+			}
+			pkgPath := out.all.Pkg().Path()
+			var walk func(syms []*outSymbol, parent string)
+			walk = func(syms []*outSymbol, parent string) {
+				for _, sym := range syms {
+					name := sym.name
+					if strings.HasPrefix(name, "(") { // (T).Method => T.Method
+						name = strings.NewReplacer("(", "", ")", "", "*", "").Replace(name)
+					} else if parent != "" && sym.kind != SymbolFunction {
+						name = parent + "." + name
+					}
+					score := fuzzyMatch(query, name)
+					if sym.kind == SymbolClass && sym.detail != "" { // classfile
+						if s := fuzzyMatch(query, sym.detail); s > score {
+							score = s
+						}
+					}
+					if score >= 0 {
+						matches = append(matches, match{sym, name, pkgPath, score})
+					}
+					next := parent
+					if sym.kind == SymbolClass {
+						next = sym.name
+					}
+					walk(sym.children, next)
+				}
+			}
+			walk(out.symbols(p.fset), "")
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		if a.score != b.score {
+			return a.score > b.score
+		}
+		return a.name < b.name
+	})
+	for _, m := range matches {
+		if len(ret) == maxWorkspaceSymbols {
+			break
+		}
+		info := SymbolInformation{Name: m.name, Kind: m.sym.kind, ContainerName: m.pkg}
+		if m.sym.pos.IsValid() {
+			loc, ok := p.locationOf(p.fset, m.sym.pos, len(m.sym.id))
+			if !ok {
+				continue
+			}
+			info.Location = loc
+		} else {
+			info.Location = Location{URI: uriOf(m.sym.file)}
+		}
+		ret = append(ret, info)
+	}
+	return
+}
+
+// fuzzyMatch reports how well name matches pattern, or -1 if it doesn't. The
+// characters of pattern must appear in name in order, ignoring case.
+// Consecutive characters and characters at the start of a word score higher.
+func fuzzyMatch(pattern, name string) (score int) {
+	if pattern == "" {
+		return 0
+	}
+	prev, last := rune(0), -2 // last is the index of the last matched character
+	i := 0
+	for j, r := range name {
+		if i == len(pattern) {
+			break
+		}
+		pr, size := utf8.DecodeRuneInString(pattern[i:])
+		if unicode.ToLower(r) == unicode.ToLower(pr) {
+			score++
+			if j == last+1 {
+				score += 2
+			}
+			if j == 0 || prev == '_' || prev == '.' || unicode.IsUpper(r) && unicode.IsLower(prev) {
+				score += 3
+			}
+			if r == pr {
+				score++
+			}
+			last = j + utf8.RuneLen(r) - 1
+			i += size
+		}
+		prev = r
+	}
+	if i < len(pattern) {
+		return -1
+	}
+	if len(pattern) == len(name) {
+		score += 5
+	}
+	return
+}
+
+// -----------------------------------------------------------------------------
@@ -0,0 +1,133 @@
+package langserver
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+const testSymbolsGop = `package foo
+
+type T struct {
+	X int
+}
+
+func (t *T) Get() int {
+	return t.X
+}
+
+func NewT() *T {
+	return nil
+}
+
+func mulInt(a, b int) int { return a * b }
+
+func mulStr(a string, b int) string { return a }
+
+func mul = (
+	mulInt
+	mulStr
+)
+
+const C = 1
+`
+
+const testSymbolsGox = `var (
+	W, H int
+)
+
+func Area() int {
+	return W * H
+}
+`
+
+func TestDocumentSymbols(t *testing.T) {
+	dir := writeTestFiles(t, map[string]string{
+		"go.mod":          "module example.com/foo\n\ngo 1.18\n",
+		"foo.go":          testOverloadGo,
+		"bar.gop":         testSymbolsGop,
+		"shapes/Rect.gox": testSymbolsGox,
+	})
+	ws := newWorkspace()
+
+	ret, err := ws.DocumentSymbols(uriOf(filepath.Join(dir, "bar.gop")))
+	if err != nil {
+		t.Fatal("DocumentSymbols:", err)
+	}
+	names := make(map[string]DocumentSymbol)
+	for _, sym := range ret {
+		names[sym.Name] = sym
+	}
+	if len(ret) != 7 {
+		t.Fatal("DocumentSymbols:", ret)
+	}
+	if sym := names["T"]; sym.Kind != SymbolStruct || len(sym.Children) != 1 || sym.Children[0].Name != "X" ||
+		sym.Range.Start.Line != 2 || sym.Range.End.Line != 4 || sym.SelectionRange.Start.Character != 5 {
+		t.Fatal("DocumentSymbols T:", sym)
+	}
+	if sym := names["(*T).Get"]; sym.Kind != SymbolMethod || sym.SelectionRange.Start != (Position{Line: 6, Character: 12}) {
+		t.Fatal("DocumentSymbols (*T).Get:", sym)
+	}
+	if sym := names["mul"]; sym.Kind != SymbolFunction || sym.Range.Start.Line != 18 || sym.Range.End.Line != 21 {
+		t.Fatal("DocumentSymbols mul:", sym)
+	}
+	if sym := names["C"]; sym.Kind != SymbolConstant || sym.Detail != "untyped int" {
+		t.Fatal("DocumentSymbols C:", sym)
+	}
+
+	ret, err = ws.DocumentSymbols(uriOf(filepath.Join(dir, "foo.go")))
+	if err != nil || len(ret) != 1 {
+		t.Fatal("DocumentSymbols foo.go:", ret, err)
+	}
+	if sym := ret[0]; sym.Name != "Add" || len(sym.Children) != 2 || sym.Children[1].Name != "Add__1" {
+		t.Fatal("DocumentSymbols Add:", sym)
+	}
+
+	ret, err = ws.DocumentSymbols(uriOf(filepath.Join(dir, "shapes", "Rect.gox")))
+	if err != nil || len(ret) != 1 {
+		t.Fatal("DocumentSymbols Rect.gox:", ret, err)
+	}
+	if sym := ret[0]; sym.Name != "Rect" || sym.Kind != SymbolClass || sym.Detail != "Rect.gox" ||
+		len(sym.Children) != 3 || sym.Children[2].Name != "Area" || sym.Range.End.Line != 7 {
+		t.Fatal("DocumentSymbols Rect:", sym)
+	}
+}
+
+func TestWorkspaceSymbols(t *testing.T) {
+	dir := writeTestFiles(t, map[string]string{
+		"go.mod":          "module example.com/foo\n\ngo 1.18\n",
+		"foo.go":          testOverloadGo,
+		"bar.gop":         testSymbolsGop,
+		"shapes/Rect.gox": testSymbolsGox,
+	})
+	ws := newWorkspace()
+
+	ret, err := ws.WorkspaceSymbols("rcar", dir)
+	if err != nil || len(ret) != 1 {
+		t.Fatal("WorkspaceSymbols:", ret, err)
+	}
+	if sym := ret[0]; sym.Name != "Rect.Area" || sym.Kind != SymbolMethod || sym.ContainerName != "example.com/foo/shapes" {
+		t.Fatal("WorkspaceSymbols:", sym)
+	}
+
+	ret, err = ws.WorkspaceSymbols("rect.gox", dir)
+	if err != nil || len(ret) == 0 || ret[0].Name != "Rect" || filepath.Base(string(ret[0].Location.URI)) != "Rect.gox" {
+		t.Fatal("WorkspaceSymbols rect.gox:", ret, err)
+	}
+
+	ret, err = ws.WorkspaceSymbols("mul", dir)
+	if err != nil || len(ret) != 3 || ret[0].Name != "mul" {
+		t.Fatal("WorkspaceSymbols mul:", ret, err)
+	}
+}
+
+func TestFuzzyMatch(t *testing.T) {
+	if fuzzyMatch("", "Foo") != 0 || fuzzyMatch("xyz", "Foo") >= 0 || fuzzyMatch("oof", "Foo") >= 0 {
+		t.Fatal("fuzzyMatch: unexpected")
+	}
+	if fuzzyMatch("nt", "NewT") <= fuzzyMatch("nt", "Int") {
+		t.Fatal("fuzzyMatch: word starts should score higher")
+	}
+	if fuzzyMatch("get", "Get") <= fuzzyMatch("get", "GetX") {
+		t.Fatal("fuzzyMatch: exact match should score higher")
+	}
+}
@@ -0,0 +1,809 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package langserver
+
+import (
+	"bytes"
+	goast "go/ast"
+	goparser "go/parser"
+	"go/types"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/goplus/gop/ast"
+	"github.com/goplus/gop/cl"
+	"github.com/goplus/gop/parser"
+	"github.com/goplus/gop/parser/fsx"
+	"github.com/goplus/gop/scanner"
+	"github.com/goplus/gop/token"
+	"github.com/goplus/gop/tool"
+	"github.com/goplus/gop/x/gopenv"
+	"github.com/goplus/gop/x/typesutil"
+	"github.com/goplus/mod/gopmod"
+)
+
+// -----------------------------------------------------------------------------
+
+// document is a text document opened by a client.
+type document struct {
+	uri     DocumentURI
+	version int32
+	text    []byte
+}
+
+// Package is the result of type-checking a directory.
+type Package struct {
+	Dir     string
+	Fset    *token.FileSet
+	Types   *types.Package
+	Info    *typesutil.Info
+	GoInfo  *types.Info
+	Files   map[string]*ast.File   // Go+ files keyed by absolute filename
+	GoFiles map[string]*goast.File // Go files keyed by absolute filename
+	Errors  []Error                // syntax and type errors
+
+	tfiles  []*token.File     // files added to Fset by the check
+	domains map[string]string // text domains declared by gop.mod
+	refOnce sync.Once
+	refs    map[string][]ref // references to package-level objects, methods and fields
+}
+
+// An Error describes a problem found while parsing or type-checking a file.
+// If Pos.Line is 0, the error is not associated with a specific file.
+type Error struct {
+	Pos  token.Position
+	Msg  string
+	Soft bool // if set, the error is "soft" and may be reported as a warning
+}
+
+// workspace holds the documents opened by clients and caches the results
+// of type-checking their directories.
+//
+// The mutex guards the maps of the workspace only, so that packages are
+// parsed and type-checked without holding it. Adding files to the FileSet is
+// serialized by fsetMu instead, see addFiles. If both are held, fsetMu is
+// locked first.
+type workspace struct {
+	mutex     sync.Mutex
+	docs      map[string]*document      // keyed by absolute filename
+	pkgs      map[string]*Package       // keyed by directory
+	outs      map[string]*pkgOutline    // keyed by directory
+	mods      map[string]*gopmod.Module // keyed by directory
+	imps      map[string]*importer      // keyed by module root
+	checking  map[string]*flight        // checks in flight, keyed by directory
+	outlining map[string]*flight        // outlines in flight, keyed by directory
+	dropped   []*importer               // dropped importers whose files aren't removed yet
+	fsetMu    sync.Mutex
+	fset      *token.FileSet
+
+	maxErrors int // see Config.MaxErrors
+}
+
+func newWorkspace() *workspace {
+	return &workspace{
+		docs: make(map[string]*document),
+		pkgs: make(map[string]*Package),
+		outs: make(map[string]*pkgOutline),
+		mods: make(map[string]*gopmod.Module),
+		imps: make(map[string]*importer),
+		fset: token.NewFileSet(),
+
+		checking:  make(map[string]*flight),
+		outlining: make(map[string]*flight),
+	}
+}
+
+// flight is a check or an outline of a directory in flight. Its result is
+// shared by the callers which ask for the directory meanwhile.
+type flight struct {
+	done chan struct{}
+	pkg  *Package
+	out  *pkgOutline
+	err  error
+}
+
+func (p *workspace) open(file string, doc *document) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.docs[file] = doc
+	p.drop(filepath.Dir(file))
+}
+
+func (p *workspace) close(file string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	delete(p.docs, file)
+	p.drop(filepath.Dir(file))
+}
+
+func (p *workspace) change(file string, version int32, changes []TextDocumentContentChangeEvent) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	doc, ok := p.docs[file]
+	if !ok {
+		return
+	}
+	for _, c := range changes {
+		if c.Range == nil {
+			doc.text = []byte(c.Text)
+			continue
+		}
+		start := offsetOf(doc.text, c.Range.Start)
+		end := offsetOf(doc.text, c.Range.End)
+		text := make([]byte, 0, len(doc.text)-(end-start)+len(c.Text))
+		text = append(text, doc.text[:start]...)
+		text = append(text, c.Text...)
+		doc.text = append(text, doc.text[end:]...)
+	}
+	doc.version = version
+	p.drop(filepath.Dir(file))
+}
+
+// invalidate drops the cached type information of dir.
+func (p *workspace) invalidate(dir string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.drop(dir)
+}
+
+// drop drops the cached results of dir, and removes their files from the
+// FileSet. The results of the check and outline of dir in flight won't be
+// cached. The mutex must be held.
+func (p *workspace) drop(dir string) {
+	delete(p.checking, dir)
+	delete(p.outlining, dir)
+	if pkg, ok := p.pkgs[dir]; ok {
+		removeFiles(p.fset, pkg.tfiles)
+		delete(p.pkgs, dir)
+	}
+	if out, ok := p.outs[dir]; ok {
+		removeFiles(p.fset, out.tfiles)
+		delete(p.outs, dir)
+	}
+}
+
+// addFiles calls add, which adds files to the FileSet, and appends the files
+// it added to *to. The mutex must not be held.
+func (p *workspace) addFiles(to *[]*token.File, add func()) {
+	p.fsetMu.Lock()
+	defer p.fsetMu.Unlock()
+	p.mutex.Lock()
+	p.removeDropped()
+	p.mutex.Unlock()
+	base := p.fset.Base()
+	add()
+	*to = append(*to, filesSince(p.fset, base)...)
+}
+
+// removeDropped removes the files of the dropped importers from the FileSet.
+// Both fsetMu and the mutex must be held.
+func (p *workspace) removeDropped() {
+	for _, imp := range p.dropped {
+		removeFiles(p.fset, imp.tfiles)
+		imp.tfiles = nil
+	}
+	p.dropped = nil
+}
+
+// filesSince returns the files added to fset since its base was base.
+func filesSince(fset *token.FileSet, base int) (files []*token.File) {
+	fset.Iterate(func(f *token.File) bool {
+		if f.Base() >= base {
+			files = append(files, f)
+		}
+		return true
+	})
+	return
+}
+
+// docDirs returns the directories of the opened documents.
+func (p *workspace) docDirs() (dirs []string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	seen := make(map[string]bool)
+	for file := range p.docs {
+		if dir := filepath.Dir(file); !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+	sort.Strings(dirs)
+	return
+}
+
+// content returns the content of file, preferring the opened document.
+func (p *workspace) content(file string) ([]byte, error) {
+	p.mutex.Lock()
+	doc, ok := p.docs[file]
+	var text []byte
+	if ok { // copied under the mutex, as change writes doc.text
+		text = append([]byte(nil), doc.text...)
+	}
+	p.mutex.Unlock()
+	if ok {
+		return text, nil
+	}
+	return os.ReadFile(file)
+}
+
+// check type-checks the package in dir, returning the cached result if the
+// directory hasn't changed since the last check. The package is parsed and
+// type-checked without holding the mutex, and concurrent checks of dir share
+// the same result.
+func (p *workspace) check(dir string) (pkg *Package, err error) {
+	p.mutex.Lock()
+	if pkg, ok := p.pkgs[dir]; ok {
+		p.mutex.Unlock()
+		return pkg, nil
+	}
+	if c, ok := p.checking[dir]; ok {
+		p.mutex.Unlock()
+		<-c.done
+		return c.pkg, c.err
+	}
+	c := &flight{done: make(chan struct{})}
+	p.checking[dir] = c
+	mod, err := p.loadMod(dir)
+	var fs overlayFS
+	var imp *importer
+	if err == nil {
+		fs, imp = p.overlay(), p.importer(mod)
+	}
+	p.mutex.Unlock()
+
+	defer func() {
+		p.mutex.Lock()
+		if p.checking[dir] == c {
+			delete(p.checking, dir)
+			if err == nil {
+				p.pkgs[dir] = pkg
+			}
+		} else if pkg != nil { // dropped meanwhile
+			removeFiles(p.fset, pkg.tfiles)
+		}
+		p.mutex.Unlock()
+		c.pkg, c.err = pkg, err
+		close(c.done)
+	}()
+	if err != nil {
+		return
+	}
+	return p.doCheck(dir, mod, fs, imp)
+}
+
+func (p *workspace) doCheck(dir string, mod *gopmod.Module, fs overlayFS, imp *importer) (pkg *Package, err error) {
+	var pkgs map[string]*ast.Package
+	var errs []Error
+	var tfiles []*token.File
+	p.addFiles(&tfiles, func() {
+		pkgs, errs, err = parseDir(p.fset, fs, dir, mod)
+	})
+	if err != nil {
+		removeFiles(p.fset, tfiles)
+		return
+	}
+	astPkg := mainPkgOf(pkgs)
+	if astPkg == nil {
+		removeFiles(p.fset, tfiles)
+		return nil, tool.ErrNotFound
+	}
+	pkg = &Package{
+		Dir:     dir,
+		Fset:    p.fset,
+		Types:   types.NewPackage(pkgPathOf(mod, dir), astPkg.Name),
+		Info:    newInfo(),
+		GoInfo:  newGoInfo(),
+		Files:   astPkg.Files,
+		GoFiles: astPkg.GoFiles,
+		Errors:  errs,
+		tfiles:  tfiles,
+		domains: cl.ModDomains(mod.Opt),
+	}
+	conf := &types.Config{
+		Importer: imp,
+		Error: func(err error) {
+			switch e := err.(type) {
+			case types.Error:
+				pkg.Errors = append(pkg.Errors, Error{Pos: e.Fset.Position(e.Pos), Msg: e.Msg, Soft: e.Soft})
+			default:
+				pkg.Errors = append(pkg.Errors, Error{Pos: token.Position{Filename: dir}, Msg: err.Error()})
+			}
+		},
+	}
+	chk := typesutil.NewChecker(conf, &typesutil.Config{
+		Types:     pkg.Types,
+		Fset:      p.fset,
+		Mod:       mod,
+		MaxErrors: p.maxErrors,
+	}, pkg.GoInfo, pkg.Info)
+	gofiles := make([]*goast.File, 0, len(astPkg.GoFiles))
+	for _, f := range sortedFiles(astPkg.GoFiles) {
+		gofiles = append(gofiles, astPkg.GoFiles[f])
+	}
+	files := make([]*ast.File, 0, len(astPkg.Files))
+	for _, f := range sortedFiles(astPkg.Files) {
+		files = append(files, astPkg.Files[f])
+	}
+	imp.mu.Lock()
+	defer imp.mu.Unlock()
+	chk.Files(gofiles, files)
+	return
+}
+
+// parseDir parses all Go and Go+ files in dir. Unlike parser.ParseFSDir, it
+// doesn't stop at the first file with syntax errors, and it returns the
+// errors of all files.
+func parseDir(fset *token.FileSet, fs overlayFS, dir string, mod *gopmod.Module) (pkgs map[string]*ast.Package, errs []Error, err error) {
+	list, err := fs.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	addErr := func(e error) {
+		if list, ok := e.(scanner.ErrorList); ok {
+			for _, e := range list {
+				errs = append(errs, Error{Pos: e.Pos, Msg: e.Msg})
+			}
+		} else if e != nil {
+			errs = append(errs, Error{Pos: token.Position{Filename: dir}, Msg: e.Error()})
+		}
+	}
+	pkgs = make(map[string]*ast.Package)
+	conf := parser.Config{
+		ClassKind: mod.ClassKind,
+		Mode:      parser.ParseComments | parser.AllErrors | parser.RecoverErrors,
+	}
+	for _, d := range list {
+		fname := d.Name()
+		if d.IsDir() || strings.HasPrefix(fname, "_") {
+			continue
+		}
+		if fi, e := d.Info(); e != nil || !tool.FilterNoTestFiles(fi) {
+			continue
+		}
+		filename := filepath.Join(dir, fname)
+		if filepath.Ext(fname) == ".go" {
+			if strings.HasPrefix(fname, "gop_autogen") {
+				continue
+			}
+			src, e := fs.ReadFile(filename)
+			if e != nil {
+				addErr(e)
+				continue
+			}
+			f, e := goparser.ParseFile(fset, filename, src, goparser.ParseComments|goparser.AllErrors)
+			addErr(e)
+			if f != nil && f.Name != nil {
+				pkg := reqPkg(pkgs, f.Name.Name)
+				if pkg.GoFiles == nil {
+					pkg.GoFiles = make(map[string]*goast.File)
+				}
+				pkg.GoFiles[filename] = f
+			}
+			continue
+		}
+		f, e := parser.ParseFSEntry(fset, fs, filename, nil, conf)
+		if e == parser.ErrUnknownFileKind {
+			continue
+		}
+		addErr(e)
+		if f != nil && f.Name != nil {
+			reqPkg(pkgs, f.Name.Name).Files[filename] = f
+		}
+	}
+	return
+}
+
+// mainPkgOf returns the package which isn't an external test package.
+func mainPkgOf(pkgs map[string]*ast.Package) *ast.Package {
+	for name, v := range pkgs {
+		if !strings.HasSuffix(name, "_test") {
+			return v
+		}
+	}
+	return nil
+}
+
+func reqPkg(pkgs map[string]*ast.Package, name string) *ast.Package {
+	pkg, ok := pkgs[name]
+	if !ok {
+		pkg = &ast.Package{Name: name, Files: make(map[string]*ast.File)}
+		pkgs[name] = pkg
+	}
+	return pkg
+}
+
+// loadMod loads the module which dir belongs to. The mutex must be held.
+func (p *workspace) loadMod(dir string) (mod *gopmod.Module, err error) {
+	if mod, ok := p.mods[dir]; ok {
+		return mod, nil
+	}
+	if mod, err = tool.LoadMod(dir); err == nil {
+		p.mods[dir] = mod
+	}
+	return
+}
+
+// resetMods drops all loaded modules, eg. when a go.mod or gop.mod changed.
+func (p *workspace) resetMods() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.mods = make(map[string]*gopmod.Module)
+	for root := range p.imps {
+		p.dropImporter(root)
+	}
+	for dir := range p.pkgs {
+		p.drop(dir)
+	}
+	for dir := range p.outs {
+		p.drop(dir)
+	}
+}
+
+// invalidateImports drops the importer of the module which dir belongs to, as
+// it caches the packages imported before dir changed on disk. The results of
+// all packages of the module are dropped too, since they refer to the objects
+// of the importer.
+func (p *workspace) invalidateImports(dir string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	mod, err := p.loadMod(dir)
+	if err != nil {
+		return
+	}
+	root := mod.Root()
+	if _, ok := p.imps[root]; !ok {
+		return
+	}
+	p.dropImporter(root)
+	for d := range p.pkgs {
+		if inDir(d, root) {
+			p.drop(d)
+		}
+	}
+	for d := range p.outs {
+		if inDir(d, root) {
+			p.drop(d)
+		}
+	}
+}
+
+// dropImporter drops the importer of the module root, and removes the files
+// it added from the FileSet. If the FileSet is busy, the files are removed
+// by the next addFiles. The mutex must be held.
+func (p *workspace) dropImporter(root string) {
+	if imp, ok := p.imps[root]; ok {
+		delete(p.imps, root)
+		p.dropped = append(p.dropped, imp)
+		if p.fsetMu.TryLock() {
+			p.removeDropped()
+			p.fsetMu.Unlock()
+		}
+	}
+}
+
+// dependents returns the directories of dirs which import the package in dir,
+// directly or indirectly.
+func (p *workspace) dependents(dir string, dirs []string) (ret []string) {
+	deps := make(map[string][]string)
+	var imports func(d string, seen map[string]bool) bool
+	imports = func(d string, seen map[string]bool) bool {
+		if seen[d] {
+			return false
+		}
+		seen[d] = true
+		list, ok := deps[d]
+		if !ok {
+			list = p.localDeps(d)
+			deps[d] = list
+		}
+		for _, dep := range list {
+			if dep == dir || imports(dep, seen) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, d := range dirs {
+		if d != dir && imports(d, make(map[string]bool)) {
+			ret = append(ret, d)
+		}
+	}
+	return
+}
+
+// inDir reports whether file is dir or inside it.
+func inDir(file, dir string) bool {
+	return file == dir || strings.HasPrefix(file, dir+string(filepath.Separator))
+}
+
+// localDeps returns the directories of the packages in the same module which
+// the package in dir imports.
+func (p *workspace) localDeps(dir string) (deps []string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	mod, err := p.loadMod(dir)
+	if err != nil || !mod.HasModfile() {
+		return
+	}
+	fs := p.overlay()
+	list, err := fs.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	fset := token.NewFileSet()
+	modPath, seen := mod.Path(), make(map[string]bool)
+	for _, d := range list {
+		fname := d.Name()
+		switch filepath.Ext(fname) {
+		case ".go":
+			if strings.HasPrefix(fname, "gop_autogen") {
+				continue
+			}
+		case ".gop", ".gox":
+		default:
+			if _, ok := mod.ClassKind(fname); !ok {
+				continue
+			}
+		}
+		f, _ := parser.ParseFSFile(fset, fs, filepath.Join(dir, fname), nil, parser.ImportsOnly)
+		if f == nil {
+			continue
+		}
+		for _, imp := range f.Imports {
+			path, err := strconv.Unquote(imp.Path.Value)
+			if err != nil || seen[path] {
+				continue
+			}
+			seen[path] = true
+			if path == modPath {
+				deps = append(deps, mod.Root())
+			} else if strings.HasPrefix(path, modPath+"/") {
+				deps = append(deps, filepath.Join(mod.Root(), filepath.FromSlash(path[len(modPath)+1:])))
+			}
+		}
+	}
+	sort.Strings(deps)
+	return
+}
+
+// importer imports the packages of a module for type-checking. The packages
+// sharing an importer are checked one at a time, see mu, while the packages
+// of different modules are checked concurrently.
+type importer struct {
+	*tool.Importer
+	ws     *workspace
+	mu     sync.Mutex    // held while checking a package, as gogen initializes the imported packages
+	tfiles []*token.File // files added to the FileSet by the imports, guarded by fsetMu
+}
+
+func (p *importer) Import(pkgPath string) (pkg *types.Package, err error) {
+	p.ws.addFiles(&p.tfiles, func() {
+		pkg, err = p.Importer.Import(pkgPath)
+	})
+	return
+}
+
+// importer returns the importer of mod, which is shared by the packages of
+// the module. The mutex must be held.
+func (p *workspace) importer(mod *gopmod.Module) *importer {
+	root := mod.Root()
+	imp, ok := p.imps[root]
+	if !ok {
+		imp = &importer{Importer: tool.NewImporter(mod, gopenv.Get(), p.fset), ws: p}
+		imp.Flags = 0
+		p.imps[root] = imp
+	}
+	return imp
+}
+
+func pkgPathOf(mod *gopmod.Module, dir string) string {
+	if mod.HasModfile() {
+		if rel, err := filepath.Rel(mod.Root(), dir); err == nil {
+			if rel == "." {
+				return mod.Path()
+			}
+			return mod.Path() + "/" + filepath.ToSlash(rel)
+		}
+	}
+	return "main"
+}
+
+func sortedFiles[T any](files map[string]T) []string {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func newInfo() *typesutil.Info {
+	return &typesutil.Info{
+		Types:      make(map[ast.Expr]types.TypeAndValue),
+		Defs:       make(map[*ast.Ident]types.Object),
+		Uses:       make(map[*ast.Ident]types.Object),
+		Implicits:  make(map[ast.Node]types.Object),
+		Selections: make(map[*ast.SelectorExpr]*types.Selection),
+		Scopes:     make(map[ast.Node]*types.Scope),
+		Overloads:  make(map[*ast.Ident]types.Object),
+	}
+}
+
+func newGoInfo() *types.Info {
+	return &types.Info{
+		Types:      make(map[goast.Expr]types.TypeAndValue),
+		Defs:       make(map[*goast.Ident]types.Object),
+		Uses:       make(map[*goast.Ident]types.Object),
+		Implicits:  make(map[goast.Node]types.Object),
+		Selections: make(map[*goast.SelectorExpr]*types.Selection),
+		Scopes:     make(map[goast.Node]*types.Scope),
+	}
+}
+
+// -----------------------------------------------------------------------------
+
+// overlayFS is a parser.FileSystem which reads opened documents from a
+// snapshot of the workspace instead of the local disk.
+type overlayFS struct {
+	docs map[string][]byte // keyed by absolute filename
+}
+
+// overlay returns a snapshot of the opened documents. The mutex must be held.
+func (p *workspace) overlay() overlayFS {
+	docs := make(map[string][]byte, len(p.docs))
+	for file, doc := range p.docs {
+		docs[file] = doc.text // change replaces doc.text rather than modifying it
+	}
+	return overlayFS{docs}
+}
+
+func (p overlayFS) ReadDir(dirname string) ([]fs.DirEntry, error) {
+	list, err := os.ReadDir(dirname)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	exists := make(map[string]bool, len(list))
+	for _, d := range list {
+		exists[d.Name()] = true
+	}
+	for file, text := range p.docs {
+		if filepath.Dir(file) != dirname {
+			continue
+		}
+		if name := filepath.Base(file); !exists[name] {
+			list = append(list, docEntry{name, int64(len(text))})
+		}
+	}
+	return list, nil
+}
+
+func (p overlayFS) ReadFile(filename string) ([]byte, error) {
+	if text, ok := p.docs[filename]; ok {
+		return text, nil
+	}
+	return os.ReadFile(filename)
+}
+
+func (p overlayFS) Join(elem ...string) string {
+	return fsx.Local.Join(elem...)
+}
+
+func (p overlayFS) Base(filename string) string {
+	return fsx.Local.Base(filename)
+}
+
+func (p overlayFS) Abs(path string) (string, error) {
+	return fsx.Local.Abs(path)
+}
+
+// docEntry is a fs.DirEntry of a document that doesn't exist on the disk.
+type docEntry struct {
+	name string
+	size int64
+}
+
+func (p docEntry) Name() string               { return p.name }
+func (p docEntry) IsDir() bool                { return false }
+func (p docEntry) Type() fs.FileMode          { return 0 }
+func (p docEntry) Info() (fs.FileInfo, error) { return p, nil }
+func (p docEntry) Size() int64                { return p.size }
+func (p docEntry) Mode() fs.FileMode          { return 0644 }
+func (p docEntry) ModTime() time.Time         { return time.Time{} }
+func (p docEntry) Sys() any                   { return nil }
+
+// -----------------------------------------------------------------------------
+
+const fileScheme = "file://"
+
+// FilenameOf converts a document URI into an absolute filename.
+func FilenameOf(uri DocumentURI) string {
+	s := string(uri)
+	if !strings.HasPrefix(s, fileScheme) {
+		return filepath.Clean(s)
+	}
+	if u, err := url.Parse(s); err == nil {
+		s = u.Path
+	} else {
+		s = s[len(fileScheme):]
+	}
+	return filepath.FromSlash(s)
+}
+
+// uriOf converts an absolute filename into a document URI.
+func uriOf(file string) DocumentURI {
+	u := url.URL{Scheme: "file", Path: filepath.ToSlash(file)}
+	return DocumentURI(u.String())
+}
+
+// offsetOf converts a LSP position into a byte offset of text.
+func offsetOf(text []byte, pos Position) int {
+	off := 0
+	for line := uint32(0); line < pos.Line; line++ {
+		i := bytes.IndexByte(text[off:], '\n')
+		if i < 0 {
+			return len(text)
+		}
+		off += i + 1
+	}
+	for col := uint32(0); col < pos.Character && off < len(text); {
+		r, n := utf8.DecodeRune(text[off:])
+		if r == '\n' {
+			break
+		}
+		col += utf16Len(r)
+		off += n
+	}
+	return off
+}
+
+// positionOf converts a byte offset of text into a LSP position.
+func positionOf(text []byte, off int) (pos Position) {
+	if off > len(text) {
+		off = len(text)
+	}
+	lineStart := 0
+	for i := 0; i < off; i++ {
+		if text[i] == '\n' {
+			pos.Line++
+			lineStart = i + 1
+		}
+	}
+	for i := lineStart; i < off; {
+		r, n := utf8.DecodeRune(text[i:])
+		pos.Character += utf16Len(r)
+		i += n
+	}
+	return
+}
+
+// utf16Len returns the number of UTF-16 code units required to encode r.
+func utf16Len(r rune) uint32 {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
+
+// -----------------------------------------------------------------------------
@@ -0,0 +1,169 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package sourcemap maps the Go code generated from Go+ sources back to them,
+// and the reverse.
+//
+// A source map is built from the //line comments of a generated Go file, and
+// is written in JSON next to it, as gop_autogen.go.map for gop_autogen.go.
+package sourcemap
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"reflect"
+	"sort"
+)
+
+// Version is the version of the source map format.
+const Version = 1
+
+// Ext is the extension of a source map file, appended to the Go file name.
+const Ext = ".map"
+
+// A Segment maps a range of the Go code to the Go+ source line it is generated
+// from. Columns aren't mapped: the //line comments generated by the Go+
+// compiler specify lines only (their columns are of the Go code).
+type Segment struct {
+	Start int    `json:"start"` // byte offset of the Go code
+	End   int    `json:"end"`   // byte offset just after the Go code
+	File  string `json:"file"`  // Go+ file, as in the //line comments
+	Line  int    `json:"line"`  // 1-based line in File
+	Kind  string `json:"kind"`  // node kind, eg. "FuncDecl" or "AssignStmt"
+}
+
+// A Map is the source map of a generated Go file.
+type Map struct {
+	Version  int       `json:"version"`
+	GoFile   string    `json:"goFile"`
+	Lines    []int     `json:"lines"`    // byte offset of each line of the Go code
+	Segments []Segment `json:"segments"` // sorted by Start, then by End descending
+}
+
+// -----------------------------------------------------------------------------
+
+// Build builds the source map of the generated Go file goFile, whose content
+// is src. The declarations, specs and statements after a //line comment are
+// mapped to the position it specifies.
+func Build(goFile string, src []byte) (*Map, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, goFile, src, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	tf := fset.File(f.Pos())
+	m := &Map{Version: Version, GoFile: goFile, Lines: tf.Lines(), Segments: []Segment{}}
+	ast.Inspect(f, func(n ast.Node) bool {
+		switch n.(type) {
+		case ast.Decl, ast.Spec, ast.Stmt:
+		case nil:
+			return false
+		default:
+			return true
+		}
+		pos := fset.PositionFor(n.Pos(), true)
+		if pos.Filename == goFile || pos.Filename == "" {
+			return true
+		}
+		m.Segments = append(m.Segments, Segment{
+			Start: tf.Offset(n.Pos()),
+			End:   tf.Offset(n.End()),
+			File:  pos.Filename,
+			Line:  pos.Line,
+			Kind:  reflect.TypeOf(n).Elem().Name(),
+		})
+		return true
+	})
+	sort.SliceStable(m.Segments, func(i, j int) bool {
+		a, b := &m.Segments[i], &m.Segments[j]
+		return a.Start < b.Start || (a.Start == b.Start && a.End > b.End)
+	})
+	return m, nil
+}
+
+// Load loads a source map from file.
+func Load(file string) (*Map, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	m := new(Map)
+	if err = json.Unmarshal(b, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// WriteFile writes the source map to file in JSON.
+func (m *Map) WriteFile(file string) error {
+	b, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, b, 0666)
+}
+
+// -----------------------------------------------------------------------------
+
+// Offset returns the byte offset of the Go code at 1-based (line, col). It
+// returns -1 if line is out of range.
+func (m *Map) Offset(line, col int) int {
+	if line < 1 || line > len(m.Lines) {
+		return -1
+	}
+	return m.Lines[line-1] + col - 1
+}
+
+// Position returns the 1-based (line, col) of the Go code at byte offset.
+func (m *Map) Position(offset int) (line, col int) {
+	i := sort.Search(len(m.Lines), func(i int) bool { return m.Lines[i] > offset })
+	if i == 0 {
+		return 0, 0
+	}
+	return i, offset - m.Lines[i-1] + 1
+}
+
+// ToGop returns the innermost segment which contains the Go code at byte
+// offset.
+func (m *Map) ToGop(offset int) (seg *Segment, ok bool) {
+	for i := range m.Segments {
+		s := &m.Segments[i]
+		if s.Start > offset {
+			break
+		}
+		if offset < s.End {
+			seg, ok = s, true
+		}
+	}
+	return
+}
+
+// ToGo returns the segments generated from Go+ source file at line, from the
+// outermost to the innermost ones.
+func (m *Map) ToGo(file string, line int) []*Segment {
+	var ret []*Segment
+	for i := range m.Segments {
+		if s := &m.Segments[i]; s.File == file && s.Line == line {
+			ret = append(ret, s)
+		}
+	}
+	return ret
+}
+
+// -----------------------------------------------------------------------------
@@ -0,0 +1,122 @@
+/*
+ * Copyright (c) 2023 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package sourcemap_test
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/goplus/gop/x/sourcemap"
+)
+
+const autogen = `package main
+
+import "fmt"
+
+//line a.gop:1:1
+func add(a int, b int) int {
+//line a.gop:2:1
+	return a + b
+}
+//line a.gop:5
+func main() {
+//line a.gop:5:1
+	x := add(1, 2)
+//line a.gop:6:1
+	if x > 2 {
+//line a.gop:7:1
+		fmt.Println(x)
+	}
+}
+`
+
+func TestBuild(t *testing.T) {
+	m, err := sourcemap.Build("gop_autogen.go", []byte(autogen))
+	if err != nil {
+		t.Fatal("Build:", err)
+	}
+	if m.Version != sourcemap.Version || m.GoFile != "gop_autogen.go" {
+		t.Fatal("Build:", m.Version, m.GoFile)
+	}
+	for _, s := range m.Segments {
+		if s.File != "a.gop" {
+			t.Fatal("unexpected segment:", s)
+		}
+	}
+
+	off := strings.Index(autogen, "fmt.Println")
+	seg, ok := m.ToGop(off)
+	if !ok || seg.Line != 7 || seg.Kind != "ExprStmt" {
+		t.Fatal("ToGop:", seg, ok)
+	}
+	if line, col := m.Position(off); line != 17 || col != 3 || m.Offset(line, col) != off {
+		t.Fatal("Position:", line, col)
+	}
+	if _, ok := m.ToGop(strings.Index(autogen, "import")); ok {
+		t.Fatal("ToGop: import is not generated from Go+ code")
+	}
+
+	segs := m.ToGo("a.gop", 6)
+	if len(segs) != 2 || segs[0].Kind != "IfStmt" || segs[1].Kind != "BlockStmt" {
+		t.Fatal("ToGo:", segs)
+	}
+	if !strings.HasPrefix(autogen[segs[0].Start:segs[0].End], "if x > 2 {") {
+		t.Fatal("ToGo:", autogen[segs[0].Start:segs[0].End])
+	}
+	if m.Offset(100, 1) != -1 {
+		t.Fatal("Offset: expected -1")
+	}
+
+	segs = m.ToGo("a.gop", 5) // by "//line a.gop:5" and "//line a.gop:5:1"
+	if len(segs) != 3 || segs[0].Kind != "FuncDecl" || segs[2].Kind != "AssignStmt" {
+		t.Fatal("ToGo:", segs)
+	}
+	if autogen[segs[2].Start:segs[2].End] != "x := add(1, 2)" {
+		t.Fatal("ToGo:", autogen[segs[2].Start:segs[2].End])
+	}
+	b, _ := json.Marshal(segs[2])
+	if strings.Contains(string(b), "col") {
+		t.Fatal("Segment:", string(b)) // no column, see Segment
+	}
+}
+
+func TestLoad(t *testing.T) {
+	m, err := sourcemap.Build("gop_autogen.go", []byte(autogen))
+	if err != nil {
+		t.Fatal("Build:", err)
+	}
+	file := filepath.Join(t.TempDir(), "gop_autogen.go"+sourcemap.Ext)
+	if err = m.WriteFile(file); err != nil {
+		t.Fatal("WriteFile:", err)
+	}
+	ret, err := sourcemap.Load(file)
+	if err != nil {
+		t.Fatal("Load:", err)
+	}
+	if !reflect.DeepEqual(ret, m) {
+		t.Fatal("Load:", ret)
+	}
+	if _, err = sourcemap.Load(file + ".none"); err == nil {
+		t.Fatal("Load: expected an error")
+	}
+	if _, err = sourcemap.Build("bad.go", []byte("package")); err == nil {
+		t.Fatal("Build: expected an error")
+	}
+}
@@ -80,6 +80,10 @@ type Config struct {
 
 	// If UpdateGoTypesOverload is set, update go types overload data (optional).
 	UpdateGoTypesOverload bool
+
+	// MaxErrors is the maximum number of errors to report of compiling Go+
+	// files (optional, 0 means no limit). See cl.Config.MaxErrors.
+	MaxErrors int
 }
 
 // A Checker maintains the state of the type checker.
@@ -152,6 +156,8 @@ func (p *Checker) Files(goFiles []*goast.File, gopFiles []*ast.File) (err error)
 		NoAutoGenMain:  true,
 		NoSkipConstant: true,
 		Outline:        opts.IgnoreFuncBodies,
+		Domains:        cl.ModDomains(mod.Opt),
+		MaxErrors:      opts.MaxErrors,
 	})
 	if err != nil {
 		if onErr := conf.Error; onErr != nil {
@@ -71,6 +71,10 @@ func checkInfo(fset *token.FileSet, files []*ast.File, gofiles []*goast.File) (*
 		Fset:  fset,
 		Mod:   gopmod.Default,
 	}
+	return checkWith(conf, chkOpts, files, gofiles)
+}
+
+func checkWith(conf *types.Config, chkOpts *typesutil.Config, files []*ast.File, gofiles []*goast.File) (*typesutil.Info, *types.Info, error) {
 	info := &typesutil.Info{
 		Types:      make(map[ast.Expr]types.TypeAndValue),
 		Defs:       make(map[*ast.Ident]types.Object),
@@ -194,6 +198,32 @@ nums = append(nums, "NaN")
 	}
 }
 
+func TestCheckMaxErrors(t *testing.T) {
+	fset := token.NewFileSet()
+	files, _, err := loadFiles(fset, "main.gop", `
+echo a
+echo b
+echo c
+`, "", "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for max, expected := range map[int]int{0: 3, 2: 2} {
+		var n int
+		conf := &types.Config{Error: func(err error) { n++ }}
+		chkOpts := &typesutil.Config{
+			Types:     types.NewPackage("main", "main"),
+			Fset:      fset,
+			Mod:       gopmod.Default,
+			MaxErrors: max,
+		}
+		checkWith(conf, chkOpts, files, nil)
+		if n != expected {
+			t.Fatalf("MaxErrors %d: %d errors reported, expected %d", max, n, expected)
+		}
+	}
+}
+
 func TestBadFile(t *testing.T) {
 	conf := &types.Config{}
 	opt := &typesutil.Config{}
@@ -273,6 +303,9 @@ func init() {
 		if !found {
 			t.Fatal("bad overload", o)
 		}
+		if info.OverloadChosen(use) != o {
+			t.Fatal("bad overload chosen", o)
+		}
 	}
 	for use, o := range info.Uses {
 		declObj, ovObjs := info.OverloadOf(use)
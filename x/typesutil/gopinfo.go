@@ -180,6 +180,24 @@ func (info *Info) OverloadOf(id *ast.Ident) (types.Object, []types.Object) {
 	return nil, nil
 }
 
+// OverloadChosen returns the member of the overloaded function denoted by id
+// which the compiler chose at the call site. It returns nil if id doesn't
+// denote an overloaded function or no member matches the arguments.
+func (info *Info) OverloadChosen(id *ast.Ident) types.Object {
+	if _, objs := info.OverloadOf(id); objs != nil {
+		if obj := info.Uses[id]; obj != nil {
+			for _, o := range objs {
+				// the members may be represented by different objects in a
+				// package mixed with Go files, so match them by name too
+				if o == obj || (o.Name() == obj.Name() && o.Pkg() == obj.Pkg()) {
+					return o
+				}
+			}
+		}
+	}
+	return nil
+}
+
 // -----------------------------------------------------------------------------
 
 type gopRecorder struct {